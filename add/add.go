@@ -3,6 +3,7 @@ package add
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/nabeo/cli-tool-example/utils"
 
@@ -15,103 +16,306 @@ var Command = cli.Command{
   Aliases: []string{"a"},
   Usage: "add command",
   Action: doAdd,
-  Flags: []cli.Flag{
+  Flags: append([]cli.Flag{
     &cli.StringFlag{
       Name: "hostname",
       Usage: "hostname",
-      Required: true,
       Aliases: []string{"H"},
     },
+    &cli.StringFlag{
+      Name: "from-file",
+      Usage: "add the A or AAAA and PTR records of the hosts in a CSV (hostname,ip) or JSON file instead of --hostname",
+      Aliases: []string{"f"},
+    },
     &cli.StringFlag{
       Name: "ip",
-      Usage: "IP Address",
+      Usage: "IP Address (IPv4 or IPv6)",
       Aliases: []string{"i"},
     },
+    &cli.StringFlag{
+      Name: "pool",
+      Usage: "allocate the lowest free IP Address of the pool in the config file",
+      Aliases: []string{"p"},
+    },
     &cli.StringFlag{
       Name: "cname",
       Usage: "CNAME record",
       Aliases: []string{"c"},
     },
+    &cli.StringSliceFlag{
+      Name: "value",
+      Usage: "record value for TXT, MX, SRV, CAA and NS records (repeatable)",
+      Aliases: []string{"v"},
+    },
     &cli.StringFlag{
       Name: "type",
-      Usage: "A or CNAME",
+      Usage: "A, AAAA, CNAME, TXT, MX, SRV, CAA or NS",
       Aliases: []string{"t"},
     },
+    &cli.Int64Flag{
+      Name: "ttl",
+      Usage: "TTL of CNAME, TXT, MX, SRV, CAA and NS records",
+      Value: 600,
+    },
+    &cli.StringFlag{
+      Name: "alias-target",
+      Usage: "DNS name of the alias target (ELB, CloudFront, S3 or a record in the zone)",
+    },
+    &cli.StringFlag{
+      Name: "alias-zone-id",
+      Usage: "Hosted Zone ID of the alias target (default: the zone itself)",
+    },
+    &cli.BoolFlag{
+      Name: "evaluate-health",
+      Usage: "evaluate the health of the alias target",
+    },
     &cli.StringFlag{
       Name: "zone",
       Usage: "Hosted Zone name",
       Required: true,
       Aliases: []string{"z"},
     },
-  },
+  }, utils.RoutingPolicyFlags...),
 }
 
 type addData struct {
   hostname string
   ip net.IP
+  pool *utils.Pool
   cname string
+  values []string
+  aliasTarget string
+  ttl int64
   zonename string
   zoneID string
   rrType string
+  policy utils.RoutingPolicy
+}
+
+// genericTypes are the record types added from --value.
+var genericTypes = map[string]bool{
+  "TXT": true,
+  "MX": true,
+  "SRV": true,
+  "CAA": true,
+  "NS": true,
+}
+
+// aliasTypes are the record types added from --alias-target.
+var aliasTypes = map[string]bool{
+  "A": true,
+  "AAAA": true,
+  "CNAME": true,
 }
 
 func doAdd(c *cli.Context) (err error) {
-  if len(c.String("ip")) > 0 && len(c.String("cname")) > 0 {
-    return fmt.Errorf("choose ip or cname")
+  if c.IsSet("from-file") {
+    return doAddFromFile(c)
+  }
+  if !c.IsSet("hostname") {
+    return fmt.Errorf("either hostname or from-file is required")
   }
 
   var data addData
-  data.hostname = c.String("hostname")
-  
-  data.ip = net.ParseIP(c.String("ip"))
   data.zonename = c.String("zone")
+  data.hostname = utils.Fqdn(c.String("hostname"), data.zonename)
+  data.ip = net.ParseIP(c.String("ip"))
+  data.cname = c.String("cname")
+  data.values = c.StringSlice("value")
+  data.aliasTarget = c.String("alias-target")
+  data.ttl = c.Int64("ttl")
 
-  if len(c.String("ip")) > 0 {
-    data.rrType = "A"
-  } else if len(c.String("cname")) > 0 {
-    data.rrType = "CNAME"
-  } else {
-    return fmt.Errorf("choose ip or cname")
+  var confToml utils.ConfToml
+  err = utils.LoadConf(c.String("conf"), &confToml)
+  if err != nil {
+    return err
+  }
+  if c.IsSet("pool") {
+    pool, err := confToml.GetPool(c.String("pool"))
+    if err != nil {
+      return err
+    }
+    data.pool = &pool
   }
 
-  awsClient, err := utils.NewAWSClient(c)
+  data.rrType, err = detectRRType(c, data)
   if err != nil {
     return err
   }
-  data.zoneID, err = awsClient.GetHostedZoneID(data.zonename)
+
+  data.policy, err = utils.RoutingPolicyFromContext(c)
   if err != nil {
     return err
   }
+  if len(data.policy.SetIdentifier) > 0 && !data.policy.HasPolicy() {
+    return fmt.Errorf("set-id requires one of weight, failover, region or geolocation")
+  }
 
-  var confToml utils.ConfToml
-  err = utils.LoadConf(c.String("conf"), &confToml)
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  data.zoneID, err = awsClient.GetHostedZoneID(data.zonename)
   if err != nil {
     return err
   }
 
-  var rInfos utils.ReverseHostedZoneInfos
-  for _, p := range confToml.ReverseHostedZones {
-    rInfo, err := awsClient.CreateReverseHostedZoneInfo(p.NetworkCIDR, p.ZoneName)
-    if err != nil {
-      return err
+  if len(data.aliasTarget) > 0 {
+    aliasZoneID := c.String("alias-zone-id")
+    if len(aliasZoneID) == 0 {
+      if !utils.InZone(data.aliasTarget, data.zonename) {
+        return fmt.Errorf("alias-zone-id is required for targets outside %s", data.zonename)
+      }
+      aliasZoneID = data.zoneID
     }
-    rInfos.ReverseHostedZoneInfo[len(rInfos.ReverseHostedZoneInfo)] = rInfo
+    rrset := utils.NewAliasResourceRecordSet(utils.Fqdn(data.hostname, data.zonename), data.rrType, data.aliasTarget, aliasZoneID, c.Bool("evaluate-health"))
+    data.policy.Apply(rrset)
+    return awsClient.AddResourceRecordSet(rrset, data.zoneID)
   }
 
   switch data.rrType {
-  case "A":
-    err = awsClient.AddAResourceRecordSet(data.ip, data.hostname, data.zoneID, rInfos)
+  case "A", "AAAA":
+    rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+    if err != nil {
+      return err
+    }
+
+    if data.pool != nil {
+      used, err := awsClient.PoolUsedAddresses(*data.pool, data.zoneID, rInfos)
+      if err != nil {
+        return err
+      }
+      data.ip, err = data.pool.NextFree(used)
+      if err != nil {
+        return err
+      }
+    }
+
+    plan, err := awsClient.PlanAddAResourceRecordSet(data.ip, data.hostname, data.zoneID, rInfos, data.policy)
     if err != nil {
       return err
     }
-  case "CNAME":
-    err = awsClient.AddCnameResourceRecordSet(data.hostname, data.cname, data.zonename)
+    err = awsClient.ApplyChangePlan(plan)
     if err != nil {
       return err
     }
+    if data.pool != nil {
+      // the plan goes to the writer in dry-run mode, and the address is not
+      // allocated yet
+      w := c.App.Writer
+      if c.Bool("dry-run") {
+        w = c.App.ErrWriter
+      }
+      _, err = fmt.Fprintf(w, "%s\t%s\n", data.hostname, data.ip.String())
+      if err != nil {
+        return err
+      }
+    }
   default:
-    return fmt.Errorf("unknown rr type: %s", data.rrType)
+    if data.rrType == "CNAME" {
+      data.values = []string{data.cname}
+    }
+    rrset, err := utils.NewResourceRecordSet(data.hostname, data.rrType, data.ttl, data.values, data.zonename)
+    if err != nil {
+      return err
+    }
+    data.policy.Apply(rrset)
+    err = awsClient.AddResourceRecordSet(rrset, data.zoneID)
+    if err != nil {
+      return err
+    }
   }
 
   return nil
 }
+
+// doAddFromFile adds the hosts of --from-file with as few change batches
+// as possible, and waits for them concurrently.
+func doAddFromFile(c *cli.Context) (err error) {
+  for _, name := range []string{"hostname", "ip", "pool", "cname", "value", "type", "alias-target"} {
+    if c.IsSet(name) {
+      return fmt.Errorf("from-file can not be used with %s", name)
+    }
+  }
+  hosts, err := utils.ReadBulkHosts(c.String("from-file"))
+  if err != nil {
+    return err
+  }
+
+  var confToml utils.ConfToml
+  err = utils.LoadConf(c.String("conf"), &confToml)
+  if err != nil {
+    return err
+  }
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zoneName := c.String("zone")
+  zoneID, err := awsClient.GetHostedZoneID(zoneName)
+  if err != nil {
+    return err
+  }
+  rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return err
+  }
+
+  plan, err := awsClient.PlanBulkAdd(hosts, zoneID, utils.Fqdn("@", zoneName), rInfos)
+  if err != nil {
+    return err
+  }
+  return awsClient.ApplyChangePlanConcurrently(plan)
+}
+
+// detectRRType decides the record type from --type, --ip, --pool, --cname
+// and --value, and checks that they agree with each other.
+func detectRRType(c *cli.Context, data addData) (rrType string, err error) {
+  rrType = strings.ToUpper(c.String("type"))
+
+  given := 0
+  for _, name := range []string{"ip", "pool", "cname", "value", "alias-target"} {
+    if c.IsSet(name) {
+      given++
+    }
+  }
+  if given != 1 {
+    return "", fmt.Errorf("choose one of ip, pool, cname, value or alias-target")
+  }
+
+  switch {
+  case c.IsSet("alias-target"):
+    if len(rrType) == 0 {
+      return "A", nil
+    }
+    if !aliasTypes[rrType] {
+      return "", fmt.Errorf("--alias-target requires --type A, AAAA or CNAME")
+    }
+    return rrType, nil
+  case c.IsSet("ip"):
+    if data.ip == nil {
+      return "", fmt.Errorf("invalid ip: %s", c.String("ip"))
+    }
+    ipType := utils.AddressRecordType(data.ip)
+    if len(rrType) > 0 && rrType != ipType {
+      return "", fmt.Errorf("type %s does not match ip %s", rrType, data.ip.String())
+    }
+    return ipType, nil
+  case c.IsSet("pool"):
+    ipType := utils.AddressRecordType(data.pool.Network.IP)
+    if len(rrType) > 0 && rrType != ipType {
+      return "", fmt.Errorf("type %s does not match pool %s", rrType, data.pool.Name)
+    }
+    return ipType, nil
+  case c.IsSet("cname"):
+    if len(rrType) > 0 && rrType != "CNAME" {
+      return "", fmt.Errorf("type %s does not match cname", rrType)
+    }
+    return "CNAME", nil
+  default:
+    if !genericTypes[rrType] {
+      return "", fmt.Errorf("--value requires --type TXT, MX, SRV, CAA or NS")
+    }
+    return rrType, nil
+  }
+}
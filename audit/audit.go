@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "audit",
+  Usage: "check that address records and PTR records match",
+  Action: doAudit,
+  Flags: []cli.Flag{
+    &cli.StringFlag{
+      Name: "zone",
+      Usage: "Hosted Zone name",
+      Required: true,
+      Aliases: []string{"z"},
+    },
+    &cli.BoolFlag{
+      Name: "fix",
+      Usage: "repair missing, wrong, orphan and stale PTR records",
+    },
+    &cli.StringFlag{
+      Name: "output",
+      Usage: "output format (text or json)",
+      Value: "text",
+      Aliases: []string{"o"},
+    },
+  },
+}
+
+func doAudit(c *cli.Context) (err error) {
+  zonename := c.String("zone")
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zoneID, err := awsClient.GetHostedZoneID(zonename)
+  if err != nil {
+    return err
+  }
+
+  var confToml utils.ConfToml
+  err = utils.LoadConf(c.String("conf"), &confToml)
+  if err != nil {
+    return err
+  }
+
+  rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return err
+  }
+
+  issues, plan, err := awsClient.AuditReverseRecords(zoneID, zonename, rInfos)
+  if err != nil {
+    return err
+  }
+  err = utils.WriteAuditIssues(c.App.Writer, issues, c.String("output"))
+  if err != nil {
+    return err
+  }
+
+  if !c.Bool("fix") {
+    if len(issues) > 0 {
+      return fmt.Errorf("%d issues found", len(issues))
+    }
+    return nil
+  }
+  err = awsClient.ApplyChangePlan(plan)
+  if err != nil {
+    return err
+  }
+  unfixable := 0
+  for _, issue := range issues {
+    if !issue.Fixable {
+      unfixable++
+    }
+  }
+  if unfixable > 0 {
+    return fmt.Errorf("%d issues can not be fixed", unfixable)
+  }
+  return nil
+}
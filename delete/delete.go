@@ -1,7 +1,8 @@
 package delete
 
 import (
-  "net"
+  "fmt"
+  "strings"
 
 	"github.com/nabeo/cli-tool-example/utils"
 	"github.com/urfave/cli/v2"
@@ -16,27 +17,52 @@ var Command = cli.Command{
     &cli.StringFlag{
       Name: "hostname",
       Usage: "hostname",
-      Required: true,
       Aliases: []string{"H"},
     },
+    &cli.StringFlag{
+      Name: "from-file",
+      Usage: "delete the hosts in a CSV (hostname[,ip]) or JSON file instead of --hostname",
+      Aliases: []string{"f"},
+    },
     &cli.StringFlag{
       Name: "zone",
       Usage: "HostedZone Name",
       Required: true,
       Aliases: []string{"z"},
     },
+    &cli.StringFlag{
+      Name: "type",
+      Usage: "type of the record set to delete (default: the only record set at hostname)",
+      Aliases: []string{"t"},
+    },
+    &cli.StringFlag{
+      Name: "set-id",
+      Usage: "set identifier of the record set to delete",
+    },
   },
 }
 
 type delData struct {
   hostname string
   zoneName string
+  zoneID string
+  rrType string
+  setID string
 }
 
 func doDelete(c *cli.Context) (err error){
+  if c.IsSet("from-file") {
+    return doDeleteFromFile(c)
+  }
+  if !c.IsSet("hostname") {
+    return fmt.Errorf("either hostname or from-file is required")
+  }
+
   var data delData
-  data.hostname = c.String("hostname")
   data.zoneName = c.String("zone")
+  data.hostname = utils.Fqdn(c.String("hostname"), data.zoneName)
+  data.rrType = strings.ToUpper(c.String("type"))
+  data.setID = c.String("set-id")
 
   awsClient, err := utils.NewAWSClient(c)
   if err != nil {
@@ -49,29 +75,38 @@ func doDelete(c *cli.Context) (err error){
     return err
   }
 
-  var rInfos utils.ReverseHostedZoneInfos
-  for _, p := range confToml.ReverseHostedZones {
-    rInfo, err := awsClient.CreateReverseHostedZoneInfo(p.NetworkCIDR, p.ZoneName)
-    if err != nil {
-      return err
-    }
-    rInfos.ReverseHostedZoneInfo[len(rInfos.ReverseHostedZoneInfo)] = rInfo
+  rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return err
   }
 
-  rr, err := awsClient.GetResourceRecordSetByName(data.hostname, data.zoneName)
+  data.zoneID, err = awsClient.GetHostedZoneID(data.zoneName)
   if err != nil {
     return err
   }
 
+  rr, err := awsClient.FindResourceRecordSet(data.hostname, data.rrType, data.setID, data.zoneID)
+  if err != nil {
+    return err
+  }
+
+  if rr.AliasTarget != nil {
+    return awsClient.RemoveResourceRecordSet(rr, data.zoneID)
+  }
+
   switch *rr.Type {
-  case "A":
-    ip := net.ParseIP(*rr.ResourceRecords[0].Value)
-    err = awsClient.RemoveAResourceRecordSet(&rr, ip, data.hostname, data.zoneName, rInfos)
+  case "A", "AAAA":
+    err = awsClient.RemoveAResourceRecordSet(rr, data.zoneID, rInfos)
     if err != nil {
       return err
     }
   case "CNAME":
-    err = awsClient.RemoveCnameResourceRecordSet(&rr, data.zoneName)
+    err = awsClient.RemoveCnameResourceRecordSet(rr, data.zoneID)
+    if err != nil {
+      return err
+    }
+  default:
+    err = awsClient.RemoveResourceRecordSet(rr, data.zoneID)
     if err != nil {
       return err
     }
@@ -79,3 +114,42 @@ func doDelete(c *cli.Context) (err error){
 
   return nil
 }
+
+// doDeleteFromFile deletes the hosts of --from-file with as few change
+// batches as possible, and waits for them concurrently.
+func doDeleteFromFile(c *cli.Context) (err error) {
+  for _, name := range []string{"hostname", "type", "set-id"} {
+    if c.IsSet(name) {
+      return fmt.Errorf("from-file can not be used with %s", name)
+    }
+  }
+  hosts, err := utils.ReadBulkHosts(c.String("from-file"))
+  if err != nil {
+    return err
+  }
+
+  var confToml utils.ConfToml
+  err = utils.LoadConf(c.String("conf"), &confToml)
+  if err != nil {
+    return err
+  }
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zoneName := c.String("zone")
+  zoneID, err := awsClient.GetHostedZoneID(zoneName)
+  if err != nil {
+    return err
+  }
+  rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return err
+  }
+
+  plan, err := awsClient.PlanBulkRemove(hosts, zoneID, utils.Fqdn("@", zoneName), rInfos)
+  if err != nil {
+    return err
+  }
+  return awsClient.ApplyChangePlanConcurrently(plan)
+}
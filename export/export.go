@@ -0,0 +1,81 @@
+package export
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "export",
+  Usage: "export a hosted zone",
+  Action: doExport,
+  Flags: []cli.Flag{
+    &cli.StringFlag{
+      Name: "zone",
+      Usage: "Hosted Zone name",
+      Required: true,
+      Aliases: []string{"z"},
+    },
+    &cli.StringFlag{
+      Name: "format",
+      Usage: "bind, or one of the list output formats",
+      Value: "bind",
+    },
+    &cli.StringFlag{
+      Name: "file",
+      Usage: "path to output file (default: stdout)",
+      Aliases: []string{"f"},
+    },
+  },
+}
+
+func doExport(c *cli.Context) (err error) {
+  zonename := c.String("zone")
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+
+  id, err := awsClient.GetHostedZoneID(zonename)
+  if err != nil {
+    return err
+  }
+
+  rrsets, err := awsClient.ListAllResourceRecords(id)
+  if err != nil {
+    return err
+  }
+
+  w := c.App.Writer
+  if len(c.String("file")) > 0 {
+    var f *os.File
+    f, err = os.Create(c.String("file"))
+    if err != nil {
+      return err
+    }
+    // the records may only be flushed to the file on close
+    defer func() {
+      closeErr := f.Close()
+      if err == nil {
+        err = closeErr
+      }
+    }()
+    w = f
+  }
+
+  switch c.String("format") {
+  case "bind":
+    err = utils.WriteZoneFile(w, zonename, rrsets)
+  default:
+    err = utils.WriteResourceRecordSets(w, rrsets, c.String("format"))
+  }
+  if err != nil {
+    return fmt.Errorf("export %s: %v", zonename, err)
+  }
+  return nil
+}
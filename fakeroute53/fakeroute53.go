@@ -0,0 +1,991 @@
+// Package fakeroute53 is a stateful in-memory Route53 backend. It implements
+// the Route53 client interface of this tool, so that commands and other
+// tooling can be exercised end to end without an AWS account.
+package fakeroute53
+
+import (
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+const (
+  hostedZonePrefix = "/hostedzone/"
+  changePrefix = "/change/"
+
+  // defaultMaxHostedZones and defaultMaxRecordSets are the page sizes
+  // Route53 uses when MaxItems is not given. They are also the largest
+  // pages it returns.
+  defaultMaxHostedZones = 100
+  defaultMaxRecordSets = 300
+
+  // maxBatchRecords and maxBatchValueChars are the limits of a change
+  // batch, in ResourceRecord elements and characters of their values.
+  // UPSERT changes count twice.
+  maxBatchRecords = 1000
+  maxBatchValueChars = 32000
+)
+
+// Route53 is the in-memory backend. The zero value is not usable, create it
+// with New.
+type Route53 struct {
+  mu sync.Mutex
+
+  zones map[string]*hostedZone
+  changes map[string]*route53.ChangeInfo
+  changeOrder []string
+  errors map[string][]error
+  // responseErrors are returned after the call took effect, like a
+  // response which is lost on its way back.
+  responseErrors map[string][]error
+  // delegationSets holds the name servers of the delegation sets given to
+  // CreateHostedZoneWithContext, which are made up on first use.
+  delegationSets map[string][]string
+
+  zoneSeq int
+  changeSeq int
+  waitDelay time.Duration
+}
+
+type hostedZone struct {
+  id string
+  name string
+  callerReference string
+  comment string
+  private bool
+  vpcs []*route53.VPC
+  delegationSetID string
+  nameServers []string
+  rrsets []*route53.ResourceRecordSet
+}
+
+// New returns an empty backend.
+func New() *Route53 {
+  return &Route53{
+    zones: map[string]*hostedZone{},
+    changes: map[string]*route53.ChangeInfo{},
+    errors: map[string][]error{},
+    responseErrors: map[string][]error{},
+    delegationSets: map[string][]string{},
+  }
+}
+
+// CreateHostedZone adds a public hosted zone with its apex SOA and NS
+// record sets, the way Route53 creates them, and returns its ID.
+func (r *Route53) CreateHostedZone(name string) (hostedZoneID string) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  return r.createHostedZone(name, false)
+}
+
+// CreatePrivateHostedZone adds a private hosted zone and returns its ID.
+func (r *Route53) CreatePrivateHostedZone(name string) (hostedZoneID string) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  return r.createHostedZone(name, true)
+}
+
+func (r *Route53) createHostedZone(name string, private bool) string {
+  return r.newHostedZone(name, private, "").id
+}
+
+// newHostedZone adds a hosted zone with its apex SOA and NS record sets. The
+// name servers are those of the delegation set, or new ones when
+// delegationSetID is empty.
+func (r *Route53) newHostedZone(name string, private bool, delegationSetID string) *hostedZone {
+  r.zoneSeq++
+  zone := &hostedZone{
+    id: fmt.Sprintf("ZFAKE%08d", r.zoneSeq),
+    name: normalizeName(name),
+    private: private,
+    delegationSetID: delegationSetID,
+  }
+  zone.callerReference = zone.id
+  if len(delegationSetID) > 0 {
+    if _, ok := r.delegationSets[delegationSetID]; !ok {
+      r.delegationSets[delegationSetID] = newNameServers(r.zoneSeq)
+    }
+    zone.nameServers = r.delegationSets[delegationSetID]
+  } else {
+    zone.nameServers = newNameServers(r.zoneSeq)
+  }
+  ns := &route53.ResourceRecordSet{
+    Name: aws.String(zone.name),
+    Type: aws.String(route53.RRTypeNs),
+    TTL: aws.Int64(172800),
+  }
+  for _, nameServer := range zone.nameServers {
+    ns.ResourceRecords = append(ns.ResourceRecords, &route53.ResourceRecord{Value: aws.String(nameServer)})
+  }
+  zone.rrsets = []*route53.ResourceRecordSet{
+    ns,
+    {
+      Name: aws.String(zone.name),
+      Type: aws.String(route53.RRTypeSoa),
+      TTL: aws.Int64(900),
+      ResourceRecords: []*route53.ResourceRecord{
+        {Value: aws.String(zone.nameServers[0] + " awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400")},
+      },
+    },
+  }
+  sortRecordSets(zone.rrsets)
+  r.zones[zone.id] = zone
+  return zone
+}
+
+// newNameServers returns the four name servers of the seq-th delegation
+// set, one in each of the top level domains Route53 uses.
+func newNameServers(seq int) []string {
+  n := 4 * (seq - 1)
+  return []string{
+    fmt.Sprintf("ns-%d.awsdns-%02d.org.", n+1, (n+1)%64),
+    fmt.Sprintf("ns-%d.awsdns-%02d.co.uk.", n+2, (n+2)%64),
+    fmt.Sprintf("ns-%d.awsdns-%02d.com.", n+3, (n+3)%64),
+    fmt.Sprintf("ns-%d.awsdns-%02d.net.", n+4, (n+4)%64),
+  }
+}
+
+// DeleteHostedZone removes the hosted zone whatever record sets it holds.
+func (r *Route53) DeleteHostedZone(hostedZoneID string) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  delete(r.zones, trimHostedZoneID(hostedZoneID))
+}
+
+// ResourceRecordSets returns a copy of the record sets of the hosted zone in
+// the order Route53 lists them, or nil when there is no such hosted zone.
+func (r *Route53) ResourceRecordSets(hostedZoneID string) []*route53.ResourceRecordSet {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  zone, ok := r.zones[trimHostedZoneID(hostedZoneID)]
+  if !ok {
+    return nil
+  }
+  return copyRecordSets(zone.rrsets)
+}
+
+// PutResourceRecordSets stores rrsets in the hosted zone as they are,
+// replacing the record sets with the same name, type and set identifier.
+// No change is recorded and no validation is done, which makes it handy to
+// seed a scenario.
+func (r *Route53) PutResourceRecordSets(hostedZoneID string, rrsets ...*route53.ResourceRecordSet) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  zone, ok := r.zones[trimHostedZoneID(hostedZoneID)]
+  if !ok {
+    panic(fmt.Sprintf("fakeroute53: no hosted zone %s", hostedZoneID))
+  }
+  for _, rrset := range rrsets {
+    zone.rrsets = upsertRecordSet(zone.rrsets, copyRecordSet(rrset))
+  }
+  sortRecordSets(zone.rrsets)
+}
+
+// Changes returns a copy of the recorded changes, oldest first.
+func (r *Route53) Changes() []*route53.ChangeInfo {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  changes := make([]*route53.ChangeInfo, 0, len(r.changeOrder))
+  for _, id := range r.changeOrder {
+    change := *r.changes[id]
+    changes = append(changes, &change)
+  }
+  return changes
+}
+
+// SyncChanges marks every pending change as INSYNC.
+func (r *Route53) SyncChanges() {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  for _, change := range r.changes {
+    change.Status = aws.String(route53.ChangeStatusInsync)
+  }
+}
+
+// InjectError makes the next call of the named method (for example
+// "ChangeResourceRecordSets") fail with err. Errors injected for the same
+// method are returned in order, one per call.
+func (r *Route53) InjectError(method string, err error) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  r.errors[method] = append(r.errors[method], err)
+}
+
+// InjectResponseError makes the next call of the named method take effect
+// and then fail with err, the way a server or network error after Route53
+// committed the request does. Only "ChangeResourceRecordSets" supports it.
+func (r *Route53) InjectResponseError(method string, err error) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  r.responseErrors[method] = append(r.responseErrors[method], err)
+}
+
+// SetWaitDelay makes WaitUntilResourceRecordSetsChangedWithContext block for
+// d, or until its context is done, before the change is in sync.
+func (r *Route53) SetWaitDelay(d time.Duration) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  r.waitDelay = d
+}
+
+func (r *Route53) injectedError(method string) error {
+  return popError(r.errors, method)
+}
+
+func popError(errors map[string][]error, method string) error {
+  errs := errors[method]
+  if len(errs) == 0 {
+    return nil
+  }
+  errors[method] = errs[1:]
+  return errs[0]
+}
+
+// ListHostedZonesByName lists the hosted zones ordered by their names with
+// the labels reversed, starting at DNSName and HostedZoneId.
+func (r *Route53) ListHostedZonesByName(input *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("ListHostedZonesByName"); err != nil {
+    return nil, err
+  }
+  if input.HostedZoneId != nil && input.DNSName == nil {
+    return nil, awserr.New(route53.ErrCodeInvalidInput, "HostedZoneId requires DNSName", nil)
+  }
+  maxItems, err := parseMaxItems(input.MaxItems, defaultMaxHostedZones)
+  if err != nil {
+    return nil, err
+  }
+
+  zones := make([]*hostedZone, 0, len(r.zones))
+  for _, zone := range r.zones {
+    zones = append(zones, zone)
+  }
+  sort.Slice(zones, func(i, j int) bool {
+    return compareZones(zones[i].name, zones[i].id, zones[j].name, zones[j].id) < 0
+  })
+
+  start := 0
+  if input.DNSName != nil {
+    name := normalizeName(aws.StringValue(input.DNSName))
+    id := trimHostedZoneID(aws.StringValue(input.HostedZoneId))
+    for start < len(zones) && compareZones(zones[start].name, zones[start].id, name, id) < 0 {
+      start++
+    }
+  }
+
+  output := &route53.ListHostedZonesByNameOutput{
+    DNSName: input.DNSName,
+    HostedZoneId: input.HostedZoneId,
+    MaxItems: aws.String(strconv.Itoa(maxItems)),
+    IsTruncated: aws.Bool(false),
+    HostedZones: []*route53.HostedZone{},
+  }
+  for idx := start; idx < len(zones); idx++ {
+    if len(output.HostedZones) == maxItems {
+      output.IsTruncated = aws.Bool(true)
+      output.NextDNSName = aws.String(zones[idx].name)
+      output.NextHostedZoneId = aws.String(zones[idx].id)
+      break
+    }
+    output.HostedZones = append(output.HostedZones, zones[idx].hostedZone())
+  }
+  return output, nil
+}
+
+func (zone *hostedZone) hostedZone() *route53.HostedZone {
+  return &route53.HostedZone{
+    Id: aws.String(hostedZonePrefix + zone.id),
+    Name: aws.String(zone.name),
+    CallerReference: aws.String(zone.callerReference),
+    Config: &route53.HostedZoneConfig{
+      Comment: stringOrNil(zone.comment),
+      PrivateZone: aws.Bool(zone.private),
+    },
+    ResourceRecordSetCount: aws.Int64(int64(len(zone.rrsets))),
+  }
+}
+
+// ListResourceRecordSets lists the record sets of a hosted zone ordered by
+// name with the labels reversed, type and set identifier, starting at
+// StartRecordName, StartRecordType and StartRecordIdentifier.
+func (r *Route53) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("ListResourceRecordSets"); err != nil {
+    return nil, err
+  }
+  if err := input.Validate(); err != nil {
+    return nil, err
+  }
+  zone, err := r.zone(aws.StringValue(input.HostedZoneId))
+  if err != nil {
+    return nil, err
+  }
+  if input.StartRecordType != nil && input.StartRecordName == nil {
+    return nil, awserr.New(route53.ErrCodeInvalidInput, "StartRecordType requires StartRecordName", nil)
+  }
+  if input.StartRecordIdentifier != nil && input.StartRecordType == nil {
+    return nil, awserr.New(route53.ErrCodeInvalidInput, "StartRecordIdentifier requires StartRecordType", nil)
+  }
+  maxItems, err := parseMaxItems(input.MaxItems, defaultMaxRecordSets)
+  if err != nil {
+    return nil, err
+  }
+
+  start := 0
+  if input.StartRecordName != nil {
+    key := recordKey{
+      name: normalizeName(aws.StringValue(input.StartRecordName)),
+      rrType: aws.StringValue(input.StartRecordType),
+      setIdentifier: aws.StringValue(input.StartRecordIdentifier),
+    }
+    for start < len(zone.rrsets) && compareKeys(keyOf(zone.rrsets[start]), key) < 0 {
+      start++
+    }
+  }
+
+  output := &route53.ListResourceRecordSetsOutput{
+    MaxItems: aws.String(strconv.Itoa(maxItems)),
+    IsTruncated: aws.Bool(false),
+    ResourceRecordSets: []*route53.ResourceRecordSet{},
+  }
+  for idx := start; idx < len(zone.rrsets); idx++ {
+    if len(output.ResourceRecordSets) == maxItems {
+      next := zone.rrsets[idx]
+      output.IsTruncated = aws.Bool(true)
+      output.NextRecordName = next.Name
+      output.NextRecordType = next.Type
+      output.NextRecordIdentifier = next.SetIdentifier
+      break
+    }
+    output.ResourceRecordSets = append(output.ResourceRecordSets, copyRecordSet(zone.rrsets[idx]))
+  }
+  return output, nil
+}
+
+// ChangeResourceRecordSets applies the change batch atomically. The batch is
+// rejected with InvalidChangeBatch, leaving the hosted zone untouched, when
+// one of the changes breaks the rules Route53 enforces.
+func (r *Route53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("ChangeResourceRecordSets"); err != nil {
+    return nil, err
+  }
+  if err := input.Validate(); err != nil {
+    return nil, err
+  }
+  zone, err := r.zone(aws.StringValue(input.HostedZoneId))
+  if err != nil {
+    return nil, err
+  }
+  if err := checkBatchSize(input.ChangeBatch.Changes); err != nil {
+    return nil, err
+  }
+
+  rrsets, err := applyChanges(zone, input.ChangeBatch.Changes)
+  if err != nil {
+    return nil, err
+  }
+  zone.rrsets = rrsets
+
+  changeInfo := r.recordChange(input.ChangeBatch.Comment)
+  if err := popError(r.responseErrors, "ChangeResourceRecordSets"); err != nil {
+    return nil, err
+  }
+  return &route53.ChangeResourceRecordSetsOutput{ChangeInfo: changeInfo}, nil
+}
+
+// recordChange records a new PENDING change and returns a copy of it.
+func (r *Route53) recordChange(comment *string) *route53.ChangeInfo {
+  r.changeSeq++
+  change := &route53.ChangeInfo{
+    Id: aws.String(fmt.Sprintf("%sCFAKE%08d", changePrefix, r.changeSeq)),
+    Status: aws.String(route53.ChangeStatusPending),
+    SubmittedAt: aws.Time(time.Now().UTC()),
+    Comment: comment,
+  }
+  id := trimChangeID(aws.StringValue(change.Id))
+  r.changes[id] = change
+  r.changeOrder = append(r.changeOrder, id)
+
+  info := *change
+  return &info
+}
+
+// GetChange returns the status of a change.
+func (r *Route53) GetChange(input *route53.GetChangeInput) (*route53.GetChangeOutput, error) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("GetChange"); err != nil {
+    return nil, err
+  }
+  change, err := r.change(input)
+  if err != nil {
+    return nil, err
+  }
+  info := *change
+  return &route53.GetChangeOutput{ChangeInfo: &info}, nil
+}
+
+// WaitUntilResourceRecordSetsChanged marks the change as INSYNC and returns
+// at once.
+func (r *Route53) WaitUntilResourceRecordSetsChanged(input *route53.GetChangeInput) error {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("WaitUntilResourceRecordSetsChanged"); err != nil {
+    return err
+  }
+  change, err := r.change(input)
+  if err != nil {
+    return err
+  }
+  change.Status = aws.String(route53.ChangeStatusInsync)
+  return nil
+}
+
+// CreateHostedZoneWithContext creates a hosted zone with its apex SOA and NS
+// record sets. The zone is private when a VPC is given. The caller
+// reference must be unique, as Route53 uses it to make the call idempotent.
+// The SDK method without a context is not implemented, since CreateHostedZone
+// is the helper which seeds a zone.
+func (r *Route53) CreateHostedZoneWithContext(ctx aws.Context, input *route53.CreateHostedZoneInput, opts ...request.Option) (*route53.CreateHostedZoneOutput, error) {
+  if err := canceled(ctx); err != nil {
+    return nil, err
+  }
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("CreateHostedZone"); err != nil {
+    return nil, err
+  }
+  if err := input.Validate(); err != nil {
+    return nil, err
+  }
+  for _, zone := range r.zones {
+    if zone.callerReference == aws.StringValue(input.CallerReference) {
+      return nil, awserr.New(route53.ErrCodeHostedZoneAlreadyExists, fmt.Sprintf("A hosted zone has already been created with the specified caller reference: %s", zone.callerReference), nil)
+    }
+  }
+  private := input.VPC != nil
+  if input.HostedZoneConfig != nil && aws.BoolValue(input.HostedZoneConfig.PrivateZone) && !private {
+    return nil, awserr.New(route53.ErrCodeInvalidVPCId, "A private hosted zone requires a VPC", nil)
+  }
+  if private && input.DelegationSetId != nil {
+    return nil, awserr.New(route53.ErrCodeInvalidInput, "A private hosted zone can not use a delegation set", nil)
+  }
+
+  zone := r.newHostedZone(aws.StringValue(input.Name), private, trimDelegationSetID(aws.StringValue(input.DelegationSetId)))
+  zone.callerReference = aws.StringValue(input.CallerReference)
+  if input.HostedZoneConfig != nil {
+    zone.comment = aws.StringValue(input.HostedZoneConfig.Comment)
+  }
+  if private {
+    vpc := *input.VPC
+    zone.vpcs = []*route53.VPC{&vpc}
+  }
+
+  output := &route53.CreateHostedZoneOutput{
+    HostedZone: zone.hostedZone(),
+    ChangeInfo: r.recordChange(nil),
+    Location: aws.String("https://route53.amazonaws.com/2013-04-01" + hostedZonePrefix + zone.id),
+  }
+  if private {
+    vpc := *input.VPC
+    output.VPC = &vpc
+  } else {
+    output.DelegationSet = zone.delegationSet()
+  }
+  return output, nil
+}
+
+// GetHostedZoneWithContext returns a hosted zone with its delegation set, or
+// its VPCs when it is private.
+func (r *Route53) GetHostedZoneWithContext(ctx aws.Context, input *route53.GetHostedZoneInput, opts ...request.Option) (*route53.GetHostedZoneOutput, error) {
+  if err := canceled(ctx); err != nil {
+    return nil, err
+  }
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("GetHostedZone"); err != nil {
+    return nil, err
+  }
+  if err := input.Validate(); err != nil {
+    return nil, err
+  }
+  zone, err := r.zone(aws.StringValue(input.Id))
+  if err != nil {
+    return nil, err
+  }
+  output := &route53.GetHostedZoneOutput{HostedZone: zone.hostedZone()}
+  if zone.private {
+    for _, vpc := range zone.vpcs {
+      v := *vpc
+      output.VPCs = append(output.VPCs, &v)
+    }
+  } else {
+    output.DelegationSet = zone.delegationSet()
+  }
+  return output, nil
+}
+
+// DeleteHostedZoneWithContext deletes a hosted zone, which fails with
+// HostedZoneNotEmpty when it holds record sets other than the apex SOA and
+// NS. The SDK method without a context is not implemented, since
+// DeleteHostedZone is the helper which removes any zone.
+func (r *Route53) DeleteHostedZoneWithContext(ctx aws.Context, input *route53.DeleteHostedZoneInput, opts ...request.Option) (*route53.DeleteHostedZoneOutput, error) {
+  if err := canceled(ctx); err != nil {
+    return nil, err
+  }
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if err := r.injectedError("DeleteHostedZone"); err != nil {
+    return nil, err
+  }
+  if err := input.Validate(); err != nil {
+    return nil, err
+  }
+  zone, err := r.zone(aws.StringValue(input.Id))
+  if err != nil {
+    return nil, err
+  }
+  for _, rrset := range zone.rrsets {
+    rrType := aws.StringValue(rrset.Type)
+    if aws.StringValue(rrset.Name) != zone.name || (rrType != route53.RRTypeSoa && rrType != route53.RRTypeNs) {
+      return nil, awserr.New(route53.ErrCodeHostedZoneNotEmpty, "The specified hosted zone contains non-required resource record sets and so cannot be deleted.", nil)
+    }
+  }
+  delete(r.zones, zone.id)
+  return &route53.DeleteHostedZoneOutput{ChangeInfo: r.recordChange(nil)}, nil
+}
+
+func (zone *hostedZone) delegationSet() *route53.DelegationSet {
+  set := &route53.DelegationSet{NameServers: aws.StringSlice(zone.nameServers)}
+  if len(zone.delegationSetID) > 0 {
+    set.Id = aws.String("/delegationset/" + zone.delegationSetID)
+  }
+  return set
+}
+
+// ListHostedZonesByNameWithContext is ListHostedZonesByName which fails when
+// ctx is done.
+func (r *Route53) ListHostedZonesByNameWithContext(ctx aws.Context, input *route53.ListHostedZonesByNameInput, opts ...request.Option) (*route53.ListHostedZonesByNameOutput, error) {
+  if err := canceled(ctx); err != nil {
+    return nil, err
+  }
+  return r.ListHostedZonesByName(input)
+}
+
+// ListResourceRecordSetsWithContext is ListResourceRecordSets which fails
+// when ctx is done.
+func (r *Route53) ListResourceRecordSetsWithContext(ctx aws.Context, input *route53.ListResourceRecordSetsInput, opts ...request.Option) (*route53.ListResourceRecordSetsOutput, error) {
+  if err := canceled(ctx); err != nil {
+    return nil, err
+  }
+  return r.ListResourceRecordSets(input)
+}
+
+// ChangeResourceRecordSetsWithContext is ChangeResourceRecordSets which fails
+// when ctx is done.
+func (r *Route53) ChangeResourceRecordSetsWithContext(ctx aws.Context, input *route53.ChangeResourceRecordSetsInput, opts ...request.Option) (*route53.ChangeResourceRecordSetsOutput, error) {
+  if err := canceled(ctx); err != nil {
+    return nil, err
+  }
+  return r.ChangeResourceRecordSets(input)
+}
+
+// GetChangeWithContext is GetChange which fails when ctx is done.
+func (r *Route53) GetChangeWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.Option) (*route53.GetChangeOutput, error) {
+  if err := canceled(ctx); err != nil {
+    return nil, err
+  }
+  return r.GetChange(input)
+}
+
+// WaitUntilResourceRecordSetsChangedWithContext blocks for the wait delay,
+// failing the way the SDK waiters do when ctx is done first, and then marks
+// the change as INSYNC.
+func (r *Route53) WaitUntilResourceRecordSetsChangedWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.WaiterOption) error {
+  r.mu.Lock()
+  delay := r.waitDelay
+  r.mu.Unlock()
+  if err := canceled(ctx); err != nil {
+    return err
+  }
+  if delay > 0 {
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+    case <-ctx.Done():
+      return canceled(ctx)
+    }
+  }
+  return r.WaitUntilResourceRecordSetsChanged(input)
+}
+
+// canceled returns the error the SDK returns for a request whose context is
+// done, or nil.
+func canceled(ctx aws.Context) error {
+  if ctx.Err() == nil {
+    return nil
+  }
+  return awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+}
+
+func (r *Route53) zone(hostedZoneID string) (*hostedZone, error) {
+  zone, ok := r.zones[trimHostedZoneID(hostedZoneID)]
+  if !ok {
+    return nil, awserr.New(route53.ErrCodeNoSuchHostedZone, fmt.Sprintf("No hosted zone found with ID: %s", trimHostedZoneID(hostedZoneID)), nil)
+  }
+  return zone, nil
+}
+
+func (r *Route53) change(input *route53.GetChangeInput) (*route53.ChangeInfo, error) {
+  if err := input.Validate(); err != nil {
+    return nil, err
+  }
+  id := trimChangeID(aws.StringValue(input.Id))
+  change, ok := r.changes[id]
+  if !ok {
+    return nil, awserr.New(route53.ErrCodeNoSuchChange, fmt.Sprintf("A change with the specified change ID does not exist: %s", id), nil)
+  }
+  return change, nil
+}
+
+// applyChanges applies changes in order to a copy of the record sets of the
+// zone, and returns the copy once the whole batch is known to be valid.
+func applyChanges(zone *hostedZone, changes []*route53.Change) ([]*route53.ResourceRecordSet, error) {
+  rrsets := copyRecordSets(zone.rrsets)
+  var msgs []string
+  for _, change := range changes {
+    rrset := copyRecordSet(change.ResourceRecordSet)
+    rrset.Name = aws.String(normalizeName(aws.StringValue(rrset.Name)))
+    desc := describe(rrset)
+
+    if msg := validateRecordSet(zone, rrset); len(msg) > 0 {
+      msgs = append(msgs, msg)
+      continue
+    }
+
+    key := keyOf(rrset)
+    idx := indexOf(rrsets, key)
+    switch aws.StringValue(change.Action) {
+    case route53.ChangeActionCreate:
+      if idx >= 0 {
+        msgs = append(msgs, fmt.Sprintf("Tried to create resource record set %s but it already exists", desc))
+        continue
+      }
+      rrsets = append(rrsets, rrset)
+    case route53.ChangeActionDelete:
+      if idx < 0 {
+        msgs = append(msgs, fmt.Sprintf("Tried to delete resource record set %s but it was not found", desc))
+        continue
+      }
+      if !equalRecordSet(rrsets[idx], rrset) {
+        msgs = append(msgs, fmt.Sprintf("Tried to delete resource record set %s but the values provided do not match the current values", desc))
+        continue
+      }
+      if key.name == zone.name && (key.rrType == route53.RRTypeSoa || key.rrType == route53.RRTypeNs) {
+        msgs = append(msgs, fmt.Sprintf("A HostedZone must contain exactly one SOA record and at least one NS record for the zone itself, can not delete %s", desc))
+        continue
+      }
+      rrsets = append(rrsets[:idx], rrsets[idx+1:]...)
+    case route53.ChangeActionUpsert:
+      rrsets = upsertRecordSet(rrsets, rrset)
+    default:
+      msgs = append(msgs, fmt.Sprintf("Unknown action %s for %s", aws.StringValue(change.Action), desc))
+    }
+  }
+  msgs = append(msgs, checkConflicts(rrsets)...)
+
+  if len(msgs) > 0 {
+    return nil, awserr.New(route53.ErrCodeInvalidChangeBatch, fmt.Sprintf("[%s]", strings.Join(msgs, ", ")), nil)
+  }
+  sortRecordSets(rrsets)
+  return rrsets, nil
+}
+
+// checkBatchSize rejects a batch which is over the limits of Route53.
+func checkBatchSize(changes []*route53.Change) error {
+  records, chars := 0, 0
+  for _, change := range changes {
+    weight := 1
+    if aws.StringValue(change.Action) == route53.ChangeActionUpsert {
+      weight = 2
+    }
+    rrset := change.ResourceRecordSet
+    if rrset.AliasTarget != nil {
+      records += weight
+    }
+    for _, rr := range rrset.ResourceRecords {
+      records += weight
+      chars += weight * len(aws.StringValue(rr.Value))
+    }
+  }
+  if records > maxBatchRecords {
+    return awserr.New(route53.ErrCodeInvalidChangeBatch, fmt.Sprintf("Number of records limit of %d exceeded.", maxBatchRecords), nil)
+  }
+  if chars > maxBatchValueChars {
+    return awserr.New(route53.ErrCodeInvalidChangeBatch, fmt.Sprintf("Number of characters limit of %d exceeded.", maxBatchValueChars), nil)
+  }
+  return nil
+}
+
+// validateRecordSet checks a record set on its own and returns the reason it
+// is rejected, or an empty string.
+func validateRecordSet(zone *hostedZone, rrset *route53.ResourceRecordSet) string {
+  name := aws.StringValue(rrset.Name)
+  rrType := aws.StringValue(rrset.Type)
+  desc := describe(rrset)
+  if name != zone.name && !strings.HasSuffix(name, "."+zone.name) {
+    return fmt.Sprintf("RRSet with DNS name %s is not permitted in zone %s", name, zone.name)
+  }
+  if rrset.AliasTarget != nil {
+    if rrset.TTL != nil || len(rrset.ResourceRecords) > 0 {
+      return fmt.Sprintf("RRSet %s with an alias target can not have a TTL or resource records", desc)
+    }
+  } else {
+    if rrset.TTL == nil {
+      return fmt.Sprintf("RRSet %s has no TTL", desc)
+    }
+    if len(rrset.ResourceRecords) == 0 {
+      return fmt.Sprintf("RRSet %s has no resource records", desc)
+    }
+  }
+  if rrType == route53.RRTypeCname {
+    if name == zone.name {
+      return fmt.Sprintf("RRSet of type CNAME with DNS name %s is not permitted at apex in zone %s", name, zone.name)
+    }
+    if len(rrset.ResourceRecords) > 1 {
+      return fmt.Sprintf("RRSet of type CNAME with DNS name %s has more than one resource record", name)
+    }
+  }
+  if rrset.SetIdentifier == nil && (rrset.Weight != nil || rrset.Failover != nil || rrset.Region != nil || rrset.GeoLocation != nil || rrset.MultiValueAnswer != nil) {
+    return fmt.Sprintf("RRSet %s with a routing policy requires a SetIdentifier", desc)
+  }
+  if rrset.SetIdentifier != nil && rrset.Weight == nil && rrset.Failover == nil && rrset.Region == nil && rrset.GeoLocation == nil && rrset.MultiValueAnswer == nil {
+    return fmt.Sprintf("RRSet %s with a SetIdentifier requires a routing policy", desc)
+  }
+  return ""
+}
+
+// checkConflicts returns the reasons the record sets can not live together
+// in a hosted zone.
+func checkConflicts(rrsets []*route53.ResourceRecordSet) (msgs []string) {
+  byName := map[string][]*route53.ResourceRecordSet{}
+  var names []string
+  for _, rrset := range rrsets {
+    name := aws.StringValue(rrset.Name)
+    if _, ok := byName[name]; !ok {
+      names = append(names, name)
+    }
+    byName[name] = append(byName[name], rrset)
+  }
+  sort.Strings(names)
+
+  for _, name := range names {
+    hasCname := false
+    hasOther := false
+    policies := map[string]string{}
+    for _, rrset := range byName[name] {
+      rrType := aws.StringValue(rrset.Type)
+      if rrType == route53.RRTypeCname {
+        hasCname = true
+      } else {
+        hasOther = true
+      }
+      policy := routingPolicyOf(rrset)
+      if previous, ok := policies[rrType]; ok && previous != policy {
+        msgs = append(msgs, fmt.Sprintf("RRSet with DNS name %s, type %s can not mix %s and %s routing policies", name, rrType, previous, policy))
+      }
+      policies[rrType] = policy
+    }
+    if hasCname && hasOther {
+      msgs = append(msgs, fmt.Sprintf("RRSet of type CNAME with DNS name %s is not permitted as it conflicts with other records with the same DNS name", name))
+    }
+  }
+  return msgs
+}
+
+func routingPolicyOf(rrset *route53.ResourceRecordSet) string {
+  switch {
+  case rrset.SetIdentifier == nil:
+    return "simple"
+  case rrset.Weight != nil:
+    return "weighted"
+  case rrset.Failover != nil:
+    return "failover"
+  case rrset.Region != nil:
+    return "latency"
+  case rrset.GeoLocation != nil:
+    return "geolocation"
+  default:
+    return "multivalue"
+  }
+}
+
+// recordKey identifies a record set in a hosted zone.
+type recordKey struct {
+  name string
+  rrType string
+  setIdentifier string
+}
+
+func keyOf(rrset *route53.ResourceRecordSet) recordKey {
+  return recordKey{
+    name: normalizeName(aws.StringValue(rrset.Name)),
+    rrType: aws.StringValue(rrset.Type),
+    setIdentifier: aws.StringValue(rrset.SetIdentifier),
+  }
+}
+
+func compareKeys(a recordKey, b recordKey) int {
+  if c := compareNames(a.name, b.name); c != 0 {
+    return c
+  }
+  if c := strings.Compare(a.rrType, b.rrType); c != 0 {
+    return c
+  }
+  return strings.Compare(a.setIdentifier, b.setIdentifier)
+}
+
+func compareZones(aName string, aID string, bName string, bID string) int {
+  if c := compareNames(aName, bName); c != 0 {
+    return c
+  }
+  return strings.Compare(aID, bID)
+}
+
+// compareNames orders domain names the way Route53 does, label by label from
+// the root.
+func compareNames(a string, b string) int {
+  return strings.Compare(reverseLabels(a), reverseLabels(b))
+}
+
+func reverseLabels(name string) string {
+  labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+  for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+    labels[i], labels[j] = labels[j], labels[i]
+  }
+  // "\x00" sorts a parent before the names under it
+  return strings.Join(labels, "\x00")
+}
+
+func indexOf(rrsets []*route53.ResourceRecordSet, key recordKey) int {
+  for idx, rrset := range rrsets {
+    if keyOf(rrset) == key {
+      return idx
+    }
+  }
+  return -1
+}
+
+func upsertRecordSet(rrsets []*route53.ResourceRecordSet, rrset *route53.ResourceRecordSet) []*route53.ResourceRecordSet {
+  rrset.Name = aws.String(normalizeName(aws.StringValue(rrset.Name)))
+  if idx := indexOf(rrsets, keyOf(rrset)); idx >= 0 {
+    rrsets[idx] = rrset
+    return rrsets
+  }
+  return append(rrsets, rrset)
+}
+
+func sortRecordSets(rrsets []*route53.ResourceRecordSet) {
+  sort.SliceStable(rrsets, func(i, j int) bool {
+    return compareKeys(keyOf(rrsets[i]), keyOf(rrsets[j])) < 0
+  })
+}
+
+// equalRecordSet reports whether two record sets are the same, ignoring the
+// order of the resource records.
+func equalRecordSet(a *route53.ResourceRecordSet, b *route53.ResourceRecordSet) bool {
+  return awsutil.StringValue(canonical(a)) == awsutil.StringValue(canonical(b))
+}
+
+func canonical(rrset *route53.ResourceRecordSet) *route53.ResourceRecordSet {
+  c := copyRecordSet(rrset)
+  c.Name = aws.String(normalizeName(aws.StringValue(c.Name)))
+  sort.Slice(c.ResourceRecords, func(i, j int) bool {
+    return aws.StringValue(c.ResourceRecords[i].Value) < aws.StringValue(c.ResourceRecords[j].Value)
+  })
+  if c.AliasTarget != nil {
+    c.AliasTarget.DNSName = aws.String(normalizeName(aws.StringValue(c.AliasTarget.DNSName)))
+  }
+  return c
+}
+
+func copyRecordSet(rrset *route53.ResourceRecordSet) *route53.ResourceRecordSet {
+  c := &route53.ResourceRecordSet{}
+  awsutil.Copy(c, rrset)
+  return c
+}
+
+func copyRecordSets(rrsets []*route53.ResourceRecordSet) []*route53.ResourceRecordSet {
+  copies := make([]*route53.ResourceRecordSet, 0, len(rrsets))
+  for _, rrset := range rrsets {
+    copies = append(copies, copyRecordSet(rrset))
+  }
+  return copies
+}
+
+func describe(rrset *route53.ResourceRecordSet) string {
+  if rrset.SetIdentifier != nil {
+    return fmt.Sprintf("[name='%s', type='%s', set-identifier='%s']", aws.StringValue(rrset.Name), aws.StringValue(rrset.Type), aws.StringValue(rrset.SetIdentifier))
+  }
+  return fmt.Sprintf("[name='%s', type='%s']", aws.StringValue(rrset.Name), aws.StringValue(rrset.Type))
+}
+
+// normalizeName returns name in the form Route53 returns it: lower case,
+// fully qualified and with the wildcard label escaped.
+func normalizeName(name string) string {
+  name = strings.ToLower(name)
+  if !strings.HasSuffix(name, ".") {
+    name += "."
+  }
+  if strings.HasPrefix(name, "*.") || name == "*." {
+    name = `\052` + name[1:]
+  }
+  return name
+}
+
+func trimHostedZoneID(id string) string {
+  return strings.TrimPrefix(id, hostedZonePrefix)
+}
+
+func trimDelegationSetID(id string) string {
+  return strings.TrimPrefix(id, "/delegationset/")
+}
+
+func trimChangeID(id string) string {
+  return strings.TrimPrefix(id, changePrefix)
+}
+
+func stringOrNil(s string) *string {
+  if len(s) == 0 {
+    return nil
+  }
+  return aws.String(s)
+}
+
+func parseMaxItems(maxItems *string, defaultMax int) (int, error) {
+  if maxItems == nil {
+    return defaultMax, nil
+  }
+  n, err := strconv.Atoi(aws.StringValue(maxItems))
+  if err != nil || n < 1 {
+    return 0, awserr.New(route53.ErrCodeInvalidInput, fmt.Sprintf("invalid MaxItems: %s", aws.StringValue(maxItems)), nil)
+  }
+  if n > defaultMax {
+    n = defaultMax
+  }
+  return n, nil
+}
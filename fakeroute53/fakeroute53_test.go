@@ -0,0 +1,420 @@
+package fakeroute53
+
+import (
+  "context"
+  "fmt"
+  "strings"
+  "testing"
+  "time"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+var _ utils.Route53Client = New()
+
+func newA(name string, values ...string) *route53.ResourceRecordSet {
+  rrset := &route53.ResourceRecordSet{
+    Name: aws.String(name),
+    Type: aws.String(route53.RRTypeA),
+    TTL: aws.Int64(300),
+  }
+  for _, value := range values {
+    rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(value)})
+  }
+  return rrset
+}
+
+func change(action string, rrset *route53.ResourceRecordSet) *route53.Change {
+  return &route53.Change{Action: aws.String(action), ResourceRecordSet: rrset}
+}
+
+func changeInput(hostedZoneID string, changes ...*route53.Change) *route53.ChangeResourceRecordSetsInput {
+  return &route53.ChangeResourceRecordSetsInput{
+    HostedZoneId: aws.String(hostedZoneID),
+    ChangeBatch: &route53.ChangeBatch{Changes: changes},
+  }
+}
+
+func TestListHostedZonesByName(t *testing.T) {
+  r53 := New()
+  r53.CreateHostedZone("example.org")
+  exampleCom := r53.CreateHostedZone("example.com")
+  r53.CreateHostedZone("1.0.10.in-addr.arpa.")
+
+  patterns := []struct{
+    input *route53.ListHostedZonesByNameInput
+    expectedNames []string
+    expectedNext string
+  }{
+    { &route53.ListHostedZonesByNameInput{}, []string{"1.0.10.in-addr.arpa.", "example.com.", "example.org."}, "" },
+    { &route53.ListHostedZonesByNameInput{DNSName: aws.String("example.com")}, []string{"example.com.", "example.org."}, "" },
+    { &route53.ListHostedZonesByNameInput{DNSName: aws.String("example.com."), MaxItems: aws.String("1")}, []string{"example.com."}, "example.org." },
+    { &route53.ListHostedZonesByNameInput{DNSName: aws.String("example.net.")}, []string{"example.org."}, "" },
+  }
+
+  for idx, p := range patterns {
+    resp, err := r53.ListHostedZonesByName(p.input)
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+      continue
+    }
+    var names []string
+    for _, zone := range resp.HostedZones {
+      names = append(names, aws.StringValue(zone.Name))
+    }
+    if strings.Join(names, " ") != strings.Join(p.expectedNames, " ") {
+      t.Errorf("pattern %d: want %v, actual %v", idx, p.expectedNames, names)
+    }
+    if aws.StringValue(resp.NextDNSName) != p.expectedNext || aws.BoolValue(resp.IsTruncated) != (len(p.expectedNext) > 0) {
+      t.Errorf("pattern %d: want next %q, actual %q", idx, p.expectedNext, aws.StringValue(resp.NextDNSName))
+    }
+  }
+
+  resp, _ := r53.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{DNSName: aws.String("example.com.")})
+  if aws.StringValue(resp.HostedZones[0].Id) != "/hostedzone/"+exampleCom {
+    t.Errorf("want id /hostedzone/%s, actual %s", exampleCom, aws.StringValue(resp.HostedZones[0].Id))
+  }
+}
+
+func TestListResourceRecordSetsPagination(t *testing.T) {
+  r53 := New()
+  id := r53.CreateHostedZone("example.com.")
+  for i := 0; i < 5; i++ {
+    r53.PutResourceRecordSets(id, newA(fmt.Sprintf("host%d.example.com.", i), fmt.Sprintf("10.0.1.%d", i)))
+  }
+
+  input := &route53.ListResourceRecordSetsInput{
+    HostedZoneId: aws.String(id),
+    MaxItems: aws.String("2"),
+  }
+  var names []string
+  pages := 0
+  for {
+    resp, err := r53.ListResourceRecordSets(input)
+    if err != nil {
+      t.Fatalf("unexpected error: %v", err)
+    }
+    pages++
+    for _, rrset := range resp.ResourceRecordSets {
+      names = append(names, aws.StringValue(rrset.Name)+" "+aws.StringValue(rrset.Type))
+    }
+    if !aws.BoolValue(resp.IsTruncated) {
+      break
+    }
+    input.StartRecordName = resp.NextRecordName
+    input.StartRecordType = resp.NextRecordType
+    input.StartRecordIdentifier = resp.NextRecordIdentifier
+  }
+
+  expected := []string{
+    "example.com. NS", "example.com. SOA",
+    "host0.example.com. A", "host1.example.com. A", "host2.example.com. A",
+    "host3.example.com. A", "host4.example.com. A",
+  }
+  if strings.Join(names, ",") != strings.Join(expected, ",") {
+    t.Errorf("want %v, actual %v", expected, names)
+  }
+  if pages != 4 {
+    t.Errorf("want 4 pages, actual %d", pages)
+  }
+
+  resp, err := r53.ListResourceRecordSets(&route53.ListResourceRecordSetsInput{
+    HostedZoneId: aws.String(id),
+    StartRecordName: aws.String("host2.example.com."),
+    StartRecordType: aws.String("TXT"),
+    MaxItems: aws.String("1"),
+  })
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if aws.StringValue(resp.ResourceRecordSets[0].Name) != "host3.example.com." {
+    t.Errorf("want host3.example.com., actual %s", aws.StringValue(resp.ResourceRecordSets[0].Name))
+  }
+}
+
+func TestChangeResourceRecordSets(t *testing.T) {
+  cname := &route53.ResourceRecordSet{
+    Name: aws.String("www.example.com."),
+    Type: aws.String(route53.RRTypeCname),
+    TTL: aws.Int64(300),
+    ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("web.example.com.")}},
+  }
+
+  patterns := []struct{
+    changes []*route53.Change
+    expectedError string
+  }{
+    {
+      changes: []*route53.Change{change(route53.ChangeActionCreate, newA("new.example.com.", "10.0.1.2"))},
+    },
+    {
+      changes: []*route53.Change{change(route53.ChangeActionCreate, newA("www.example.com.", "10.0.1.2"))},
+      expectedError: "Tried to create resource record set [name='www.example.com.', type='A'] but it already exists",
+    },
+    {
+      changes: []*route53.Change{change(route53.ChangeActionDelete, newA("www.example.com.", "10.0.1.2"))},
+      expectedError: "Tried to delete resource record set [name='www.example.com.', type='A'] but the values provided do not match the current values",
+    },
+    {
+      changes: []*route53.Change{change(route53.ChangeActionDelete, newA("new.example.com.", "10.0.1.2"))},
+      expectedError: "Tried to delete resource record set [name='new.example.com.', type='A'] but it was not found",
+    },
+    {
+      changes: []*route53.Change{
+        change(route53.ChangeActionDelete, newA("WWW.example.com", "10.0.1.1")),
+        change(route53.ChangeActionCreate, newA("www.example.com.", "10.0.1.2")),
+      },
+    },
+    {
+      changes: []*route53.Change{change(route53.ChangeActionUpsert, newA("www.example.com.", "10.0.1.2"))},
+    },
+    {
+      changes: []*route53.Change{change(route53.ChangeActionCreate, cname)},
+      expectedError: "RRSet of type CNAME with DNS name www.example.com. is not permitted as it conflicts with other records with the same DNS name",
+    },
+    {
+      changes: []*route53.Change{change(route53.ChangeActionCreate, newA("www.example.org.", "10.0.1.2"))},
+      expectedError: "RRSet with DNS name www.example.org. is not permitted in zone example.com.",
+    },
+    {
+      changes: []*route53.Change{
+        change(route53.ChangeActionCreate, newA("new.example.com.", "10.0.1.2")),
+        change(route53.ChangeActionCreate, newA("new.example.com.", "10.0.1.3")),
+      },
+      expectedError: "Tried to create resource record set [name='new.example.com.', type='A'] but it already exists",
+    },
+  }
+
+  for idx, p := range patterns {
+    r53 := New()
+    id := r53.CreateHostedZone("example.com.")
+    r53.PutResourceRecordSets(id, newA("www.example.com.", "10.0.1.1"))
+    before := r53.ResourceRecordSets(id)
+
+    resp, err := r53.ChangeResourceRecordSets(changeInput(id, p.changes...))
+    if err != nil {
+      aerr, ok := err.(awserr.Error)
+      if !ok || aerr.Code() != route53.ErrCodeInvalidChangeBatch || !strings.Contains(aerr.Message(), p.expectedError) || len(p.expectedError) == 0 {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      if len(r53.ResourceRecordSets(id)) != len(before) || len(r53.Changes()) != 0 {
+        t.Errorf("pattern %d: rejected batch changed the hosted zone", idx)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+      continue
+    }
+    if aws.StringValue(resp.ChangeInfo.Status) != route53.ChangeStatusPending {
+      t.Errorf("pattern %d: want PENDING, actual %s", idx, aws.StringValue(resp.ChangeInfo.Status))
+    }
+  }
+}
+
+func TestChangeStatus(t *testing.T) {
+  r53 := New()
+  id := r53.CreateHostedZone("example.com.")
+  resp, err := r53.ChangeResourceRecordSets(changeInput(id, change(route53.ChangeActionCreate, newA("www.example.com.", "10.0.1.1"))))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  input := &route53.GetChangeInput{Id: resp.ChangeInfo.Id}
+  got, err := r53.GetChange(input)
+  if err != nil || aws.StringValue(got.ChangeInfo.Status) != route53.ChangeStatusPending {
+    t.Errorf("want PENDING, actual %v (%v)", got, err)
+  }
+  err = r53.WaitUntilResourceRecordSetsChanged(input)
+  if err != nil {
+    t.Errorf("unexpected error: %v", err)
+  }
+  got, _ = r53.GetChange(input)
+  if aws.StringValue(got.ChangeInfo.Status) != route53.ChangeStatusInsync {
+    t.Errorf("want INSYNC, actual %s", aws.StringValue(got.ChangeInfo.Status))
+  }
+
+  _, err = r53.GetChange(&route53.GetChangeInput{Id: aws.String("/change/CUNKNOWN")})
+  if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != route53.ErrCodeNoSuchChange {
+    t.Errorf("want NoSuchChange, actual %v", err)
+  }
+  if len(r53.Changes()) != 1 {
+    t.Errorf("want 1 change, actual %d", len(r53.Changes()))
+  }
+}
+
+func TestInjectError(t *testing.T) {
+  r53 := New()
+  id := r53.CreateHostedZone("example.com.")
+  r53.InjectError("ChangeResourceRecordSets", fmt.Errorf("throttled"))
+
+  input := changeInput(id, change(route53.ChangeActionCreate, newA("www.example.com.", "10.0.1.1")))
+  _, err := r53.ChangeResourceRecordSets(input)
+  if err == nil || err.Error() != "throttled" {
+    t.Errorf("want injected error, actual %v", err)
+  }
+  _, err = r53.ChangeResourceRecordSets(input)
+  if err != nil {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
+
+func TestInjectResponseError(t *testing.T) {
+  r53 := New()
+  id := r53.CreateHostedZone("example.com.")
+  unavailable := awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service Unavailable", nil), 503, "req-1")
+  r53.InjectResponseError("ChangeResourceRecordSets", unavailable)
+
+  // the batch is committed even though the call fails
+  input := changeInput(id, change(route53.ChangeActionCreate, newA("www.example.com.", "10.0.1.1")))
+  _, err := r53.ChangeResourceRecordSets(input)
+  if err != unavailable {
+    t.Errorf("want injected error, actual %v", err)
+  }
+  if len(r53.ResourceRecordSets(id)) != 3 || len(r53.Changes()) != 1 {
+    t.Errorf("want the batch committed, actual %v", r53.ResourceRecordSets(id))
+  }
+
+  // sending it again through the retrying client would fail the CREATE,
+  // so the server error is returned after a single attempt
+  r53.InjectResponseError("ChangeResourceRecordSets", unavailable)
+  client := utils.NewRetryingRoute53Client(r53, utils.RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+  input = changeInput(id, change(route53.ChangeActionCreate, newA("api.example.com.", "10.0.1.2")))
+  _, err = client.ChangeResourceRecordSetsWithContext(context.Background(), input)
+  if err != unavailable {
+    t.Errorf("want the server error, actual %v", err)
+  }
+  if len(r53.ResourceRecordSets(id)) != 4 || len(r53.Changes()) != 2 {
+    t.Errorf("want the batch committed once, actual %d changes", len(r53.Changes()))
+  }
+
+  // a throttled batch is rejected before it is committed and is retried
+  r53.InjectError("ChangeResourceRecordSets", awserr.New("Throttling", "Rate exceeded", nil))
+  input = changeInput(id, change(route53.ChangeActionCreate, newA("mail.example.com.", "10.0.1.3")))
+  _, err = client.ChangeResourceRecordSetsWithContext(context.Background(), input)
+  if err != nil {
+    t.Errorf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(id)) != 5 {
+    t.Errorf("want the throttled batch retried, actual %v", r53.ResourceRecordSets(id))
+  }
+}
+
+func TestWaitWithContext(t *testing.T) {
+  r53 := New()
+  id := r53.CreateHostedZone("example.com.")
+  resp, err := r53.ChangeResourceRecordSets(changeInput(id, change(route53.ChangeActionCreate, newA("www.example.com.", "10.0.1.1"))))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  input := &route53.GetChangeInput{Id: resp.ChangeInfo.Id}
+  r53.SetWaitDelay(time.Hour)
+
+  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+  defer cancel()
+  err = r53.WaitUntilResourceRecordSetsChangedWithContext(ctx, input)
+  if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != request.CanceledErrorCode {
+    t.Errorf("want RequestCanceled, actual %v", err)
+  }
+  got, _ := r53.GetChange(input)
+  if aws.StringValue(got.ChangeInfo.Status) != route53.ChangeStatusPending {
+    t.Errorf("want PENDING, actual %s", aws.StringValue(got.ChangeInfo.Status))
+  }
+  _, err = r53.ChangeResourceRecordSetsWithContext(ctx, changeInput(id, change(route53.ChangeActionCreate, newA("api.example.com.", "10.0.1.2"))))
+  if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != request.CanceledErrorCode {
+    t.Errorf("want RequestCanceled, actual %v", err)
+  }
+  if len(r53.Changes()) != 1 {
+    t.Errorf("want 1 change, actual %d", len(r53.Changes()))
+  }
+
+  r53.SetWaitDelay(time.Millisecond)
+  err = r53.WaitUntilResourceRecordSetsChangedWithContext(context.Background(), input)
+  if err != nil {
+    t.Errorf("unexpected error: %v", err)
+  }
+  got, _ = r53.GetChange(input)
+  if aws.StringValue(got.ChangeInfo.Status) != route53.ChangeStatusInsync {
+    t.Errorf("want INSYNC, actual %s", aws.StringValue(got.ChangeInfo.Status))
+  }
+}
+
+func TestCreateDeleteHostedZone(t *testing.T) {
+  r53 := New()
+  ctx := context.Background()
+  create := func(name string, ref string, vpc *route53.VPC, delegationSetID *string) (*route53.CreateHostedZoneOutput, error) {
+    return r53.CreateHostedZoneWithContext(ctx, &route53.CreateHostedZoneInput{
+      Name: aws.String(name),
+      CallerReference: aws.String(ref),
+      VPC: vpc,
+      DelegationSetId: delegationSetID,
+    })
+  }
+
+  a, err := create("a.example.com", "ref-a", nil, aws.String("/delegationset/NSET"))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  b, err := create("b.example.com", "ref-b", nil, aws.String("NSET"))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  c, err := create("c.example.com", "ref-c", nil, nil)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  nsA := aws.StringValueSlice(a.DelegationSet.NameServers)
+  nsB := aws.StringValueSlice(b.DelegationSet.NameServers)
+  nsC := aws.StringValueSlice(c.DelegationSet.NameServers)
+  if strings.Join(nsA, ",") != strings.Join(nsB, ",") || strings.Join(nsA, ",") == strings.Join(nsC, ",") {
+    t.Errorf("want the name servers of the delegation set shared, actual %v, %v and %v", nsA, nsB, nsC)
+  }
+
+  patterns := []struct {
+    name string
+    ref string
+    vpc *route53.VPC
+    delegationSetID *string
+    expectedCode string
+  }{
+    { "d.example.com", "ref-a", nil, nil, route53.ErrCodeHostedZoneAlreadyExists },
+    { "d.example.com", "ref-d", &route53.VPC{VPCId: aws.String("vpc-1")}, aws.String("NSET"), route53.ErrCodeInvalidInput },
+  }
+  for idx, pattern := range patterns {
+    _, err := create(pattern.name, pattern.ref, pattern.vpc, pattern.delegationSetID)
+    if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != pattern.expectedCode {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, pattern.expectedCode, err)
+    }
+  }
+
+  private, err := create("example.com", "ref-p", &route53.VPC{VPCId: aws.String("vpc-1"), VPCRegion: aws.String("us-east-1")}, nil)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  got, err := r53.GetHostedZoneWithContext(ctx, &route53.GetHostedZoneInput{Id: private.HostedZone.Id})
+  if err != nil || got.DelegationSet != nil || len(got.VPCs) != 1 || !aws.BoolValue(got.HostedZone.Config.PrivateZone) {
+    t.Errorf("unexpected private zone: %v (%v)", got, err)
+  }
+
+  r53.PutResourceRecordSets(aws.StringValue(a.HostedZone.Id), newA("www.a.example.com.", "10.0.1.1"))
+  _, err = r53.DeleteHostedZoneWithContext(ctx, &route53.DeleteHostedZoneInput{Id: a.HostedZone.Id})
+  if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != route53.ErrCodeHostedZoneNotEmpty {
+    t.Errorf("want HostedZoneNotEmpty, actual %v", err)
+  }
+  resp, err := r53.DeleteHostedZoneWithContext(ctx, &route53.DeleteHostedZoneInput{Id: b.HostedZone.Id})
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if r53.ResourceRecordSets(aws.StringValue(b.HostedZone.Id)) != nil {
+    t.Errorf("hosted zone %s is not deleted", aws.StringValue(b.HostedZone.Id))
+  }
+  _, err = r53.GetChange(&route53.GetChangeInput{Id: resp.ChangeInfo.Id})
+  if err != nil {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
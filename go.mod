@@ -6,4 +6,5 @@ require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/aws/aws-sdk-go v1.27.0
 	github.com/urfave/cli/v2 v2.1.1
+	gopkg.in/yaml.v2 v2.2.8
 )
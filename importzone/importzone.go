@@ -0,0 +1,73 @@
+package importzone
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "import",
+  Usage: "import a BIND zone file into a hosted zone",
+  Action: doImport,
+  Flags: []cli.Flag{
+    &cli.StringFlag{
+      Name: "zone",
+      Usage: "Hosted Zone name",
+      Required: true,
+      Aliases: []string{"z"},
+    },
+    &cli.StringFlag{
+      Name: "file",
+      Usage: "path to zone file",
+      Required: true,
+      Aliases: []string{"f"},
+    },
+    &cli.StringFlag{
+      Name: "format",
+      Usage: "format of the zone file (bind)",
+      Value: "bind",
+    },
+  },
+}
+
+func doImport(c *cli.Context) (err error) {
+  zonename := c.String("zone")
+  if c.String("format") != "bind" {
+    return fmt.Errorf("unknown format: %s", c.String("format"))
+  }
+
+  f, err := os.Open(c.String("file"))
+  if err != nil {
+    return err
+  }
+  defer f.Close()
+
+  rrsets, err := utils.ParseZoneFile(f, zonename)
+  if err != nil {
+    return fmt.Errorf("%s: %v", c.String("file"), err)
+  }
+  rrsets, warnings := utils.FilterImportableRecordSets(rrsets, zonename)
+  for _, warning := range warnings {
+    fmt.Fprintf(c.App.ErrWriter, "warning: %s\n", warning)
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  id, err := awsClient.GetHostedZoneID(zonename)
+  if err != nil {
+    return err
+  }
+
+  plan, err := awsClient.PlanImportRecordSets(id, zonename, rrsets)
+  if err != nil {
+    return err
+  }
+  return awsClient.ApplyChangePlan(plan)
+}
@@ -2,6 +2,7 @@ package list
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/nabeo/cli-tool-example/utils"
 
@@ -21,6 +22,12 @@ var Command = cli.Command{
       Required: true,
       Aliases: []string{"z"},
     },
+    &cli.StringFlag{
+      Name: "output",
+      Usage: fmt.Sprintf("output format (%s)", strings.Join(utils.OutputFormats, ", ")),
+      Value: "text",
+      Aliases: []string{"o"},
+    },
   },
 }
 
@@ -41,12 +48,5 @@ func doList(c *cli.Context) (err error) {
     return err
   }
 
-  for _, rrset := range rrsets {
-    fmt.Printf("%s\t%s", *rrset.Type, *rrset.Name)
-    for _, rr := range rrset.ResourceRecords {
-      fmt.Printf("\t%s",*rr.Value)
-    }
-    fmt.Printf("\n")
-  }
-  return nil
+  return utils.WriteResourceRecordSets(c.App.Writer, rrsets, c.String("output"))
 }
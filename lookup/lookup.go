@@ -0,0 +1,84 @@
+package lookup
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "lookup",
+  Usage: "look up the PTR record and the address records of an IP Address",
+  Action: doLookup,
+  Flags: []cli.Flag{
+    &cli.StringFlag{
+      Name: "ip",
+      Usage: "IP Address (IPv4 or IPv6)",
+      Required: true,
+      Aliases: []string{"i"},
+    },
+    &cli.StringFlag{
+      Name: "zone",
+      Usage: "Hosted Zone name to search for address records (default: all hosted zones)",
+      Aliases: []string{"z"},
+    },
+    &cli.StringFlag{
+      Name: "output",
+      Usage: "output format (text or json)",
+      Value: "text",
+      Aliases: []string{"o"},
+    },
+  },
+}
+
+func doLookup(c *cli.Context) (err error) {
+  ip := net.ParseIP(c.String("ip"))
+  if ip == nil {
+    return fmt.Errorf("invalid ip: %s", c.String("ip"))
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+
+  var zones []*route53.HostedZone
+  if c.IsSet("zone") {
+    zoneID, err := awsClient.GetHostedZoneID(c.String("zone"))
+    if err != nil {
+      return err
+    }
+    zones = append(zones, &route53.HostedZone{
+      Id: aws.String(zoneID),
+      Name: aws.String(utils.Fqdn("@", c.String("zone"))),
+    })
+  } else {
+    zones, err = awsClient.ForwardHostedZones()
+    if err != nil {
+      return err
+    }
+  }
+
+  var confToml utils.ConfToml
+  err = utils.LoadConf(c.String("conf"), &confToml)
+  if err != nil {
+    return err
+  }
+
+  rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return err
+  }
+
+  result, err := awsClient.LookupIP(ip, zones, rInfos)
+  if err != nil {
+    return err
+  }
+  return utils.WriteIPLookup(c.App.Writer, result, c.String("output"))
+}
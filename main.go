@@ -1,18 +1,56 @@
 package main
 
 import (
+  "context"
   "log"
   "os"
 
+  "github.com/nabeo/cli-tool-example/abort"
   "github.com/nabeo/cli-tool-example/add"
+  "github.com/nabeo/cli-tool-example/audit"
   "github.com/nabeo/cli-tool-example/list"
+  "github.com/nabeo/cli-tool-example/lookup"
   "github.com/nabeo/cli-tool-example/delete"
+  "github.com/nabeo/cli-tool-example/export"
+  "github.com/nabeo/cli-tool-example/importzone"
+  "github.com/nabeo/cli-tool-example/pool"
+  "github.com/nabeo/cli-tool-example/resume"
+  "github.com/nabeo/cli-tool-example/status"
+  "github.com/nabeo/cli-tool-example/sync"
+  "github.com/nabeo/cli-tool-example/undo"
+  "github.com/nabeo/cli-tool-example/update"
+  "github.com/nabeo/cli-tool-example/utils"
+  "github.com/nabeo/cli-tool-example/zone"
 
   "github.com/urfave/cli/v2"
 )
 
 func main() {
-  app := &cli.App{
+  ctx, stop := utils.SignalContext(context.Background())
+  err := newApp().RunContext(ctx, os.Args)
+  stop()
+
+  if err != nil {
+    log.Fatal(err)
+  }
+}
+
+func newApp() *cli.App {
+  // cancelTimeout releases the timer of --timeout
+  cancelTimeout := func() {}
+  return &cli.App{
+    Before: func(c *cli.Context) error {
+      if timeout := c.Duration("timeout"); timeout > 0 {
+        var ctx context.Context
+        ctx, cancelTimeout = context.WithTimeout(c.Context, timeout)
+        c.Context = ctx
+      }
+      return nil
+    },
+    After: func(c *cli.Context) error {
+      cancelTimeout()
+      return nil
+    },
     Flags: []cli.Flag{
       &cli.StringFlag{
         Name: "profile",
@@ -20,19 +58,70 @@ func main() {
       },
       &cli.StringFlag{
         Name: "conf",
-        Usage: "path to config file",
+        Usage: "path to config file (optional)",
+      },
+      &cli.StringFlag{
+        Name: "endpoint-url",
+        Usage: "Route53 endpoint, e.g. a local emulator such as LocalStack or moto",
+        EnvVars: []string{"AWS_ENDPOINT_URL_ROUTE_53", "AWS_ENDPOINT_URL"},
+      },
+      &cli.StringFlag{
+        Name: "region",
+        Usage: "AWS region",
+        EnvVars: []string{"AWS_REGION", "AWS_DEFAULT_REGION"},
+      },
+      &cli.BoolFlag{
+        Name: "dry-run",
+        Usage: "print the change batches instead of applying them",
+      },
+      &cli.StringFlag{
+        Name: "plan-format",
+        Usage: "format of the dry-run output (text or json)",
+        Value: "text",
+      },
+      &cli.BoolFlag{
+        Name: "no-wait",
+        Usage: "print the Route53 change IDs instead of waiting until the changes are in sync (see status)",
+      },
+      &cli.DurationFlag{
+        Name: "timeout",
+        Usage: "give up the AWS calls after this long, e.g. 5m, and roll back (default: no limit)",
+      },
+      &cli.IntFlag{
+        Name: "max-attempts",
+        Usage: "attempts of a Route53 call failing with throttling or another retryable error",
+        Value: utils.DefaultRetryOptions.MaxAttempts,
+      },
+      &cli.Float64Flag{
+        Name: "rate-limit",
+        Usage: "Route53 calls per second, 0 for no limit",
+        Value: utils.DefaultRetryOptions.RateLimit,
+      },
+      &cli.StringFlag{
+        Name: "journal-dir",
+        Usage: "directory of the journal of applied changes (default: ~/.cli-tool-example/journal)",
+      },
+      &cli.BoolFlag{
+        Name: "no-journal",
+        Usage: "apply the changes without writing them to the journal",
       },
     },
     Commands: []*cli.Command{
       &add.Command,
       &delete.Command,
+      &update.Command,
       &list.Command,
+      &export.Command,
+      &importzone.Command,
+      &sync.Command,
+      &audit.Command,
+      &pool.Command,
+      &lookup.Command,
+      &undo.Command,
+      &resume.Command,
+      &abort.Command,
+      &status.Command,
+      &zone.Command,
     },
   }
-
-  err := app.Run(os.Args)
-
-  if err != nil {
-    log.Fatal(err)
-  }
 }
@@ -0,0 +1,1511 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+	"github.com/nabeo/cli-tool-example/fakeroute53"
+	"github.com/nabeo/cli-tool-example/utils"
+	"github.com/urfave/cli/v2"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+const testConf = `
+[[ReverseHostedZone]]
+NetworkCIDR = "10.0.0.0/8"
+ZoneName = "10.in-addr.arpa."
+`
+
+func TestMain(m *testing.M) {
+  dir, err := ioutil.TempDir("", "cli-test-journal")
+  if err != nil {
+    panic(err)
+  }
+  utils.DefaultJournalDir = func() string {
+    return dir
+  }
+  code := m.Run()
+  os.RemoveAll(dir)
+  os.Exit(code)
+}
+
+// runApp runs the command line against r53 and returns what it printed.
+func runApp(t *testing.T, r53 utils.Route53Client, args ...string) (string, error) {
+  t.Helper()
+  return runAppContext(t, context.Background(), r53, args...)
+}
+
+// runAppContext is runApp with a context, which stands in for the signal
+// handling of main.
+func runAppContext(t *testing.T, ctx context.Context, r53 utils.Route53Client, args ...string) (string, error) {
+  t.Helper()
+  utils.NewRoute53Client = func(c *cli.Context) (utils.Route53Client, error) {
+    return r53, nil
+  }
+  var out bytes.Buffer
+  app := newApp()
+  app.Writer = &out
+  // the fake backend has no rate limit to stay under
+  err := app.RunContext(ctx, append([]string{"cli-test", "--rate-limit", "0"}, args...))
+  return out.String(), err
+}
+
+// writeConf writes the config file into dir and returns its path.
+func writeConf(t *testing.T, dir string) string {
+  t.Helper()
+  path := filepath.Join(dir, "conf.toml")
+  err := ioutil.WriteFile(path, []byte(testConf), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return path
+}
+
+func tempDir(t *testing.T) string {
+  t.Helper()
+  dir, err := ioutil.TempDir("", "cli-test")
+  if err != nil {
+    t.Fatal(err)
+  }
+  return dir
+}
+
+func TestAddListDelete(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+
+  patterns := []struct{
+    args []string
+    expectedError string
+    expectedOutput []string
+  }{
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15"},
+    },
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "www", "--type", "TXT", "--value", "hello"},
+    },
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.16"},
+      expectedError: "Tried to create resource record set [name='www.example.com.', type='A'] but it already exists",
+    },
+    {
+      args: []string{"list", "-z", "example.com"},
+      expectedOutput: []string{"A\twww.example.com.\t10.0.1.15", "TXT\twww.example.com.\t\"hello\""},
+    },
+    {
+      args: []string{"--conf", conf, "delete", "-z", "example.com", "-H", "www"},
+      expectedError: "2 record sets match www.example.com. (A, TXT), choose one with type or set-id",
+    },
+    {
+      args: []string{"--conf", conf, "delete", "-z", "example.com", "-H", "www", "--type", "A"},
+    },
+    {
+      args: []string{"--conf", conf, "delete", "-z", "example.com", "-H", "www", "--type", "A"},
+      expectedError: "record set not found: www.example.com. (type A)",
+    },
+    {
+      args: []string{"list", "-z", "example.net"},
+      expectedError: "HostedZone not found: example.net",
+    },
+  }
+
+  for idx, p := range patterns {
+    out, err := runApp(t, r53, p.args...)
+    if err != nil {
+      if len(p.expectedError) == 0 || !strings.Contains(err.Error(), p.expectedError) {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+    }
+    for _, line := range p.expectedOutput {
+      if !strings.Contains(out, line+"\n") {
+        t.Errorf("pattern %d: want %q in output, actual %q", idx, line, out)
+      }
+    }
+  }
+
+  for _, rrset := range r53.ResourceRecordSets(zoneID) {
+    if aws.StringValue(rrset.Name) == "www.example.com." && aws.StringValue(rrset.Type) == "A" {
+      t.Errorf("www.example.com. A is not deleted")
+    }
+  }
+  for _, rrset := range r53.ResourceRecordSets(reverseID) {
+    if aws.StringValue(rrset.Type) == "PTR" {
+      t.Errorf("PTR record %s is not deleted", aws.StringValue(rrset.Name))
+    }
+  }
+  if len(r53.Changes()) != 5 {
+    t.Errorf("want 5 changes, actual %d", len(r53.Changes()))
+  }
+}
+
+func TestRoutingPolicy(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+
+  patterns := []struct{
+    args []string
+    expectedError string
+  }{
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15", "--set-id", "blue", "--weight", "100"},
+    },
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.16", "--set-id", "green", "--weight", "0"},
+    },
+    {
+      args: []string{"--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "10.0.1.17"},
+      expectedError: "2 record sets match www.example.com. (A/blue, A/green), choose one with type or set-id",
+    },
+    {
+      args: []string{"--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "10.0.1.17", "--set-id", "green", "--weight", "100"},
+    },
+    {
+      args: []string{"--conf", conf, "delete", "-z", "example.com", "-H", "www", "--set-id", "blue"},
+    },
+    {
+      args: []string{"--conf", conf, "delete", "-z", "example.com", "-H", "www", "--set-id", "blue"},
+      expectedError: "record set not found: www.example.com. (set-id blue)",
+    },
+  }
+
+  for idx, p := range patterns {
+    _, err := runApp(t, r53, p.args...)
+    actualError := ""
+    if err != nil {
+      actualError = err.Error()
+    }
+    if actualError != p.expectedError {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+    }
+  }
+
+  rrsets, err := utils.FilterResourceRecordSets(r53.ResourceRecordSets(zoneID), "www.example.com.", "A", "")
+  if err != nil || len(rrsets) != 1 {
+    t.Fatalf("want the green record set, actual %v (%v)", rrsets, err)
+  }
+  green := rrsets[0]
+  if aws.StringValue(green.SetIdentifier) != "green" || aws.Int64Value(green.Weight) != 100 || aws.StringValue(green.ResourceRecords[0].Value) != "10.0.1.17" {
+    t.Errorf("green is not updated: %v", green)
+  }
+}
+
+func TestAddRollback(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+
+  // the PTR record of the address is taken by another host
+  _, err := runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "old", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  before := len(r53.ResourceRecordSets(zoneID))
+
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err == nil || !strings.Contains(err.Error(), "already exists") {
+    t.Fatalf("want PTR conflict, actual %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != before {
+    t.Errorf("want %d record sets, actual %v", before, r53.ResourceRecordSets(zoneID))
+  }
+  if len(r53.ResourceRecordSets(reverseID)) != 3 {
+    t.Errorf("want the PTR record of old.example.com. only, actual %v", r53.ResourceRecordSets(reverseID))
+  }
+}
+
+func TestAddDiscoversReverseZone(t *testing.T) {
+  r53 := fakeroute53.New()
+  r53.CreateHostedZone("example.com.")
+  wideID := r53.CreateHostedZone("10.in-addr.arpa.")
+  narrowID := r53.CreateHostedZone("1.0.10.in-addr.arpa.")
+  r53.CreateHostedZone("0-63.2.0.10.in-addr.arpa.")
+
+  _, err := runApp(t, r53, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  for _, rrset := range r53.ResourceRecordSets(wideID) {
+    if aws.StringValue(rrset.Type) == "PTR" {
+      t.Errorf("PTR record %s is added to 10.in-addr.arpa.", aws.StringValue(rrset.Name))
+    }
+  }
+  found := false
+  for _, rrset := range r53.ResourceRecordSets(narrowID) {
+    if aws.StringValue(rrset.Name) == "15.1.0.10.in-addr.arpa." && aws.StringValue(rrset.Type) == "PTR" {
+      found = true
+    }
+  }
+  if !found {
+    t.Errorf("PTR record is not added to 1.0.10.in-addr.arpa.")
+  }
+
+  // a private reverse zone is only used when it is configured
+  private, err := r53.CreateHostedZoneWithContext(context.Background(), &route53.CreateHostedZoneInput{
+    Name: aws.String("2.0.10.in-addr.arpa."),
+    CallerReference: aws.String("private-reverse"),
+    VPC: &route53.VPC{VPCId: aws.String("vpc-1"), VPCRegion: aws.String("ap-northeast-1")},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = runApp(t, r53, "add", "-z", "example.com", "-H", "api", "-i", "10.0.2.100")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  ptrs, _ := utils.FilterResourceRecordSets(r53.ResourceRecordSets(wideID), "100.2.0.10.in-addr.arpa.", "PTR", "")
+  if len(ptrs) != 1 {
+    t.Errorf("PTR record is not added to 10.in-addr.arpa.: %v", r53.ResourceRecordSets(wideID))
+  }
+
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := filepath.Join(dir, "conf.toml")
+  err = ioutil.WriteFile(conf, []byte(`
+[[ReverseHostedZone]]
+NetworkCIDR = "10.0.2.0/24"
+ZoneName = "2.0.10.in-addr.arpa."
+`), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "mail", "-i", "10.0.2.101")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  ptrs, _ = utils.FilterResourceRecordSets(r53.ResourceRecordSets(aws.StringValue(private.HostedZone.Id)), "101.2.0.10.in-addr.arpa.", "PTR", "")
+  if len(ptrs) != 1 {
+    t.Errorf("PTR record is not added to the configured private zone")
+  }
+}
+
+func TestAddDeleteClassless(t *testing.T) {
+  r53 := fakeroute53.New()
+  r53.CreateHostedZone("example.com.")
+  parentID := r53.CreateHostedZone("2.0.192.in-addr.arpa.")
+  classlessID := r53.CreateHostedZone("0-63.2.0.192.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := filepath.Join(dir, "conf.toml")
+  err := ioutil.WriteFile(conf, []byte(`
+[[ReverseHostedZone]]
+NetworkCIDR = "192.0.2.0/26"
+ZoneName = "0-63.2.0.192.in-addr.arpa."
+ParentZoneName = "2.0.192.in-addr.arpa."
+`), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  find := func(zoneID string, name string, rrType string) string {
+    for _, rrset := range r53.ResourceRecordSets(zoneID) {
+      if aws.StringValue(rrset.Name) == name && aws.StringValue(rrset.Type) == rrType {
+        return aws.StringValue(rrset.ResourceRecords[0].Value)
+      }
+    }
+    return ""
+  }
+
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "192.0.2.5")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if ptr := find(classlessID, "5.0-63.2.0.192.in-addr.arpa.", "PTR"); ptr != "www.example.com." {
+    t.Errorf("want PTR www.example.com., actual %q", ptr)
+  }
+  if cname := find(parentID, "5.2.0.192.in-addr.arpa.", "CNAME"); cname != "5.0-63.2.0.192.in-addr.arpa." {
+    t.Errorf("want CNAME 5.0-63.2.0.192.in-addr.arpa., actual %q", cname)
+  }
+
+  _, err = runApp(t, r53, "--conf", conf, "delete", "-z", "example.com", "-H", "www")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if ptr := find(classlessID, "5.0-63.2.0.192.in-addr.arpa.", "PTR"); ptr != "" {
+    t.Errorf("PTR record is not deleted: %s", ptr)
+  }
+  if cname := find(parentID, "5.2.0.192.in-addr.arpa.", "CNAME"); cname != "" {
+    t.Errorf("CNAME record is not deleted: %s", cname)
+  }
+}
+
+func TestAuditFix(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+
+  // www.example.com. was renamed to web.example.com. by hand
+  r53.PutResourceRecordSets(zoneID, &route53.ResourceRecordSet{
+    Name: aws.String("web.example.com."),
+    Type: aws.String(route53.RRTypeA),
+    TTL: aws.Int64(600),
+    ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10.0.1.16")}},
+  })
+  r53.PutResourceRecordSets(reverseID, &route53.ResourceRecordSet{
+    Name: aws.String("15.1.0.10.in-addr.arpa."),
+    Type: aws.String(route53.RRTypePtr),
+    TTL: aws.Int64(600),
+    ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("www.example.com.")}},
+  })
+
+  out, err := runApp(t, r53, "--conf", conf, "audit", "-z", "example.com")
+  if err == nil || err.Error() != "2 issues found" {
+    t.Errorf("want 2 issues, actual %v", err)
+  }
+  for _, line := range []string{"missing-ptr\tweb.example.com.\t10.0.1.16\t", "orphan-ptr\twww.example.com.\t10.0.1.15\t"} {
+    if !strings.Contains(out, line) {
+      t.Errorf("want %q in output, actual %q", line, out)
+    }
+  }
+
+  _, err = runApp(t, r53, "--conf", conf, "audit", "-z", "example.com", "--fix")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  out, err = runApp(t, r53, "--conf", conf, "audit", "-z", "example.com")
+  if err != nil || len(out) > 0 {
+    t.Errorf("want no issues after fix, actual %q (%v)", out, err)
+  }
+  var ptrs []string
+  for _, rrset := range r53.ResourceRecordSets(reverseID) {
+    if aws.StringValue(rrset.Type) == "PTR" {
+      ptrs = append(ptrs, aws.StringValue(rrset.Name))
+    }
+  }
+  if strings.Join(ptrs, " ") != "16.1.0.10.in-addr.arpa." {
+    t.Errorf("want PTR record of 10.0.1.16 only, actual %v", ptrs)
+  }
+
+  // an address without a reverse hosted zone can not be fixed
+  r53.PutResourceRecordSets(zoneID, &route53.ResourceRecordSet{
+    Name: aws.String("ext.example.com."),
+    Type: aws.String(route53.RRTypeA),
+    TTL: aws.Int64(600),
+    ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("192.0.2.1")}},
+  })
+  _, err = runApp(t, r53, "--conf", conf, "audit", "-z", "example.com", "--fix")
+  if err == nil || err.Error() != "1 issues can not be fixed" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
+
+func TestAddAlias(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+
+  patterns := []struct{
+    args []string
+    expectedError string
+  }{
+    {
+      args: []string{"add", "-z", "example.com", "-H", "@", "--alias-target", "www.example.com."},
+    },
+    {
+      args: []string{"add", "-z", "example.com", "-H", "ipv6", "--alias-target", "www.example.com.", "--type", "aaaa"},
+    },
+    {
+      args: []string{"add", "-z", "example.com", "-H", "txt", "--alias-target", "www.example.com.", "--type", "TXT"},
+      expectedError: "--alias-target requires --type A, AAAA or CNAME",
+    },
+    {
+      args: []string{"add", "-z", "example.com", "-H", "cdn", "--alias-target", "d111111abcdef8.cloudfront.net."},
+      expectedError: "alias-zone-id is required for targets outside example.com",
+    },
+  }
+
+  for idx, p := range patterns {
+    _, err := runApp(t, r53, p.args...)
+    if err != nil {
+      if len(p.expectedError) == 0 || err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+    }
+  }
+
+  for name, rrType := range map[string]string{"example.com.": "A", "ipv6.example.com.": "AAAA"} {
+    rrsets, _ := utils.FilterResourceRecordSets(r53.ResourceRecordSets(zoneID), name, rrType, "")
+    if len(rrsets) != 1 || rrsets[0].AliasTarget == nil {
+      t.Errorf("want an alias %s record set at %s, actual %v", rrType, name, rrsets)
+    }
+  }
+}
+
+func TestAddPool(t *testing.T) {
+  r53 := fakeroute53.New()
+  r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := filepath.Join(dir, "conf.toml")
+  err := ioutil.WriteFile(conf, []byte(testConf+`
+[[Pool]]
+Name = "app-subnet"
+NetworkCIDR = "10.0.1.0/29"
+Reserved = ["10.0.1.2"]
+Gateways = ["10.0.1.1"]
+`), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  // the address is taken by a PTR record only
+  r53.PutResourceRecordSets(reverseID, &route53.ResourceRecordSet{
+    Name: aws.String("3.1.0.10.in-addr.arpa."),
+    Type: aws.String(route53.RRTypePtr),
+    TTL: aws.Int64(600),
+    ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("old.example.com.")}},
+  })
+
+  patterns := []struct{
+    args []string
+    expectedError string
+    expectedOutput string
+  }{
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "web1", "--pool", "app-subnet"},
+      expectedOutput: "web1.example.com.\t10.0.1.4\n",
+    },
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "web2", "--pool", "app-subnet"},
+      expectedOutput: "web2.example.com.\t10.0.1.5\n",
+    },
+    {
+      args: []string{"--conf", conf, "pool", "status", "-z", "example.com"},
+      expectedOutput: "app-subnet\t10.0.1.0/29\t3/4 used (75.0%)\tnext free: 10.0.1.6\n",
+    },
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "web3", "--pool", "app-subnet", "--type", "AAAA"},
+      expectedError: "type AAAA does not match pool app-subnet",
+    },
+    {
+      args: []string{"--conf", conf, "add", "-z", "example.com", "-H", "web3", "--pool", "db-subnet"},
+      expectedError: "pool not found: db-subnet",
+    },
+  }
+
+  for idx, p := range patterns {
+    out, err := runApp(t, r53, p.args...)
+    if err != nil {
+      if len(p.expectedError) == 0 || err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+    }
+    if out != p.expectedOutput {
+      t.Errorf("pattern %d: want %q, actual %q", idx, p.expectedOutput, out)
+    }
+  }
+
+  found := false
+  for _, rrset := range r53.ResourceRecordSets(reverseID) {
+    if aws.StringValue(rrset.Name) == "5.1.0.10.in-addr.arpa." {
+      found = true
+    }
+  }
+  if !found {
+    t.Errorf("PTR record of web2.example.com. is not added")
+  }
+
+  // no address is reported when the records are not added
+  r53.InjectError("ChangeResourceRecordSets", awserr.New(route53.ErrCodeInvalidChangeBatch, "invalid", nil))
+  out, err := runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "web3", "--pool", "app-subnet")
+  if err == nil || len(out) > 0 {
+    t.Errorf("unexpected result: %q (%v)", out, err)
+  }
+}
+
+func TestLookupIP(t *testing.T) {
+  r53 := fakeroute53.New()
+  r53.CreateHostedZone("example.com.")
+  r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+
+  _, err := runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.2.3.4")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "web", "-i", "10.2.3.5")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "--conf", conf, "delete", "-z", "example.com", "-H", "web")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  patterns := []struct{
+    args []string
+    expectedOutput string
+  }{
+    {
+      args: []string{"--conf", conf, "lookup", "--ip", "10.2.3.4"},
+      expectedOutput: "PTR\t4.3.2.10.in-addr.arpa.\twww.example.com.\nA\twww.example.com.\texample.com.\n",
+    },
+    {
+      args: []string{"--conf", conf, "lookup", "--ip", "10.2.3.5", "--zone", "example.com"},
+      expectedOutput: "PTR\t5.3.2.10.in-addr.arpa.\t-\n",
+    },
+    {
+      args: []string{"lookup", "--ip", "192.0.2.1"},
+      expectedOutput: "MISMATCH\treverse hosted zone: not found (192.0.2.1)\n",
+    },
+    {
+      args: []string{"--conf", conf, "lookup", "--ip", "10.2.3.4", "--output", "json"},
+      expectedOutput: `{
+  "ip": "10.2.3.4",
+  "reverse_zone": "10.in-addr.arpa.",
+  "ptr_record": "4.3.2.10.in-addr.arpa.",
+  "ptr_hostnames": [
+    "www.example.com."
+  ],
+  "address_records": [
+    {
+      "zone": "example.com.",
+      "name": "www.example.com.",
+      "type": "A"
+    }
+  ],
+  "mismatches": []
+}
+`,
+    },
+  }
+
+  for idx, p := range patterns {
+    out, err := runApp(t, r53, p.args...)
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+      continue
+    }
+    if out != p.expectedOutput {
+      t.Errorf("pattern %d: want %q, actual %q", idx, p.expectedOutput, out)
+    }
+  }
+}
+
+func TestUndo(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+
+  _, err := runApp(t, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  entries, err := utils.ListJournalEntries(journal)
+  if err != nil || len(entries) != 1 {
+    t.Fatalf("want 1 journal entry, actual %v (%v)", entries, err)
+  }
+  entry := entries[0]
+  if entry.Status != utils.JournalApplied || entry.Command != "add" || len(entry.Steps) != 2 || len(entry.Steps[1].ChangeID) == 0 {
+    t.Errorf("unexpected journal entry: %+v", entry)
+  }
+
+  out, err := runApp(t, r53, "--journal-dir", journal, "undo", "--list")
+  if err != nil || !strings.HasPrefix(out, entry.ID+"\t") || !strings.Contains(out, "\tapplied\tadd\n") {
+    t.Errorf("unexpected list: %q (%v)", out, err)
+  }
+
+  _, err = runApp(t, r53, "--journal-dir", journal, "undo", entry.ID)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+    t.Errorf("records are not reverted: %v %v", r53.ResourceRecordSets(zoneID), r53.ResourceRecordSets(reverseID))
+  }
+
+  _, err = runApp(t, r53, "--journal-dir", journal, "undo", entry.ID)
+  if err == nil || err.Error() != "journal entry "+entry.ID+" is already undone" {
+    t.Errorf("unexpected error: %v", err)
+  }
+  entries, _ = utils.ListJournalEntries(journal)
+  if len(entries) != 2 || entries[1].UndoOf != entry.ID || entries[1].Status != utils.JournalApplied {
+    t.Errorf("undo is not journaled: %v", entries)
+  }
+
+  // the undo can be undone in turn
+  _, err = runApp(t, r53, "--journal-dir", journal, "undo", entries[1].ID)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 3 || len(r53.ResourceRecordSets(reverseID)) != 3 {
+    t.Errorf("records are not restored: %v %v", r53.ResourceRecordSets(zoneID), r53.ResourceRecordSets(reverseID))
+  }
+}
+
+func TestUpdate(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  otherReverseID := r53.CreateHostedZone("168.192.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := filepath.Join(dir, "conf.toml")
+  err := ioutil.WriteFile(conf, []byte(testConf+`
+[[ReverseHostedZone]]
+NetworkCIDR = "192.168.0.0/16"
+ZoneName = "168.192.in-addr.arpa."
+`), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  address := func() string {
+    addrs, _ := utils.FilterResourceRecordSets(r53.ResourceRecordSets(zoneID), "www.example.com.", "A", "")
+    if len(addrs) != 1 {
+      return ""
+    }
+    return aws.StringValue(addrs[0].ResourceRecords[0].Value)
+  }
+  ptr := func(hostedZoneID string, name string) string {
+    ptrs, _ := utils.FilterResourceRecordSets(r53.ResourceRecordSets(hostedZoneID), name, "PTR", "")
+    if len(ptrs) != 1 {
+      return ""
+    }
+    return aws.StringValue(ptrs[0].ResourceRecords[0].Value)
+  }
+
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  // the PTR record moves with the address in the same reverse zone
+  _, err = runApp(t, r53, "--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "10.0.1.16")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if address() != "10.0.1.16" {
+    t.Errorf("address is not updated: %v", r53.ResourceRecordSets(zoneID))
+  }
+  if ptr(reverseID, "15.1.0.10.in-addr.arpa.") != "" || ptr(reverseID, "16.1.0.10.in-addr.arpa.") != "www.example.com." {
+    t.Errorf("PTR record is not moved: %v", r53.ResourceRecordSets(reverseID))
+  }
+
+  // and across reverse zones
+  _, err = runApp(t, r53, "--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "192.168.1.16")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if address() != "192.168.1.16" {
+    t.Errorf("address is not updated: %v", r53.ResourceRecordSets(zoneID))
+  }
+  if len(r53.ResourceRecordSets(reverseID)) != 2 || ptr(otherReverseID, "16.1.168.192.in-addr.arpa.") != "www.example.com." {
+    t.Errorf("PTR record is not moved: %v and %v", r53.ResourceRecordSets(reverseID), r53.ResourceRecordSets(otherReverseID))
+  }
+
+  // the PTR record of another host is only replaced on request
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "api", "-i", "10.0.1.20")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "10.0.1.20")
+  expected := "PTR record 20.1.0.10.in-addr.arpa. points at api.example.com., use --replace-ptr to point it at www.example.com."
+  if err == nil || err.Error() != expected {
+    t.Errorf("unexpected error: expected %s, actual %v", expected, err)
+  }
+  if address() != "192.168.1.16" {
+    t.Errorf("refused update changed the address: %v", r53.ResourceRecordSets(zoneID))
+  }
+  _, err = runApp(t, r53, "--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "10.0.1.20", "--replace-ptr")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if address() != "10.0.1.20" || ptr(reverseID, "20.1.0.10.in-addr.arpa.") != "www.example.com." {
+    t.Errorf("PTR record is not replaced: %v", r53.ResourceRecordSets(reverseID))
+  }
+  if len(r53.ResourceRecordSets(otherReverseID)) != 2 {
+    t.Errorf("old PTR record is not deleted: %v", r53.ResourceRecordSets(otherReverseID))
+  }
+
+  // the address is rolled back when the PTR step fails
+  r53.InjectError("ChangeResourceRecordSets", nil)
+  r53.InjectError("ChangeResourceRecordSets", awserr.New(route53.ErrCodeInvalidChangeBatch, "invalid", nil))
+  _, err = runApp(t, r53, "--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "10.0.1.21")
+  if err == nil || err.Error() != "InvalidChangeBatch: invalid" {
+    t.Errorf("unexpected error: %v", err)
+  }
+  if address() != "10.0.1.20" {
+    t.Errorf("address is not rolled back: %v", r53.ResourceRecordSets(zoneID))
+  }
+  if ptr(reverseID, "20.1.0.10.in-addr.arpa.") != "www.example.com." || ptr(reverseID, "21.1.0.10.in-addr.arpa.") != "" {
+    t.Errorf("PTR records are changed: %v", r53.ResourceRecordSets(reverseID))
+  }
+}
+
+func TestUndoUpdate(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+
+  _, err := runApp(t, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "--journal-dir", journal, "--conf", conf, "update", "-z", "example.com", "-H", "www", "-i", "10.0.1.16")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  entries, _ := utils.ListJournalEntries(journal)
+  if len(entries) != 2 {
+    t.Fatalf("want 2 journal entries, actual %v", entries)
+  }
+
+  _, err = runApp(t, r53, "--journal-dir", journal, "undo", entries[1].ID)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  out, _ := runApp(t, r53, "list", "-z", "example.com")
+  if !strings.Contains(out, "A\twww.example.com.\t10.0.1.15\n") {
+    t.Errorf("address is not reverted: %q", out)
+  }
+  ptrs, _ := utils.FilterResourceRecordSets(r53.ResourceRecordSets(reverseID), "15.1.0.10.in-addr.arpa.", "PTR", "")
+  if len(ptrs) != 1 || len(r53.ResourceRecordSets(reverseID)) != 3 {
+    t.Errorf("PTR records are not reverted: %v", r53.ResourceRecordSets(reverseID))
+  }
+
+  // the undo of the UPSERT can be undone in turn
+  entries, _ = utils.ListJournalEntries(journal)
+  _, err = runApp(t, r53, "--journal-dir", journal, "undo", entries[2].ID)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  addrs, _ := utils.FilterResourceRecordSets(r53.ResourceRecordSets(zoneID), "www.example.com.", "A", "")
+  if len(addrs) != 1 || aws.StringValue(addrs[0].ResourceRecords[0].Value) != "10.0.1.16" {
+    t.Errorf("update is not restored: %v", addrs)
+  }
+}
+
+// interrupt rewrites the journal entry id as if the command died while its
+// last step was being submitted.
+func interrupt(t *testing.T, journal string, id string) {
+  t.Helper()
+  entry, err := utils.LoadJournalEntry(journal, id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  entry.Status = utils.JournalPending
+  last := entry.Steps[len(entry.Steps)-1]
+  last.Status = utils.JournalApplying
+  last.ChangeID = ""
+  b, err := json.Marshal(entry)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = ioutil.WriteFile(filepath.Join(journal, id+".json"), b, 0600)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestResumeAbort(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+
+  _, err := runApp(t, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  entries, _ := utils.ListJournalEntries(journal)
+  id := entries[0].ID
+
+  // the PTR step never reached Route53
+  ptr := r53.ResourceRecordSets(reverseID)[2]
+  _, err = r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+    HostedZoneId: aws.String(reverseID),
+    ChangeBatch: &route53.ChangeBatch{Changes: []*route53.Change{{Action: aws.String("DELETE"), ResourceRecordSet: ptr}}},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  interrupt(t, journal, id)
+
+  _, err = runApp(t, r53, "--journal-dir", journal, "undo", id)
+  if err == nil || err.Error() != "journal entry "+id+" is interrupted, resume or abort it first" {
+    t.Errorf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "--journal-dir", journal, "resume")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(reverseID)) != 3 {
+    t.Errorf("PTR record is not added: %v", r53.ResourceRecordSets(reverseID))
+  }
+  _, err = runApp(t, r53, "--journal-dir", journal, "resume")
+  if err == nil || err.Error() != "no interrupted journal entry" {
+    t.Errorf("unexpected error: %v", err)
+  }
+
+  // the PTR step reached Route53 before the command died
+  interrupt(t, journal, id)
+  _, err = runApp(t, r53, "--journal-dir", journal, "abort", id)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+    t.Errorf("records are not rolled back: %v %v", r53.ResourceRecordSets(zoneID), r53.ResourceRecordSets(reverseID))
+  }
+  entry, _ := utils.LoadJournalEntry(journal, id)
+  if entry.Status != utils.JournalRolledBack || entry.Steps[0].Status != utils.JournalRolledBack || entry.Steps[1].Status != utils.JournalRolledBack {
+    t.Errorf("unexpected journal entry: %+v", entry)
+  }
+}
+
+func TestAddCancel(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+
+  // the signal arrives while the PTR step is waited for
+  r53.SetWaitDelay(60 * time.Millisecond)
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  go func() {
+    time.Sleep(90 * time.Millisecond)
+    cancel()
+  }()
+  _, err := runAppContext(t, ctx, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err == nil || !strings.HasPrefix(err.Error(), "canceled: ") {
+    t.Fatalf("want canceled, actual %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+    t.Errorf("records are not rolled back: %v %v", r53.ResourceRecordSets(zoneID), r53.ResourceRecordSets(reverseID))
+  }
+  entries, _ := utils.ListJournalEntries(journal)
+  if len(entries) != 1 || entries[0].Status != utils.JournalRolledBack || entries[0].Steps[1].Status != utils.JournalRolledBack {
+    t.Errorf("unexpected journal entries: %+v", entries)
+  }
+
+  // the rollback is bound by --timeout as well
+  r53.SetWaitDelay(time.Hour)
+  _, err = runApp(t, r53, "--timeout", "20ms", "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err == nil || !strings.HasPrefix(err.Error(), "timed out: ") || !strings.Contains(err.Error(), "rollback failed: timed out: ") {
+    t.Fatalf("want timed out, actual %v", err)
+  }
+  entries, _ = utils.ListJournalEntries(journal)
+  if len(entries) != 2 || !entries[1].Interrupted() {
+    t.Errorf("want an interrupted journal entry, actual %+v", entries)
+  }
+}
+
+func TestAddRetry(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  r53.CreateHostedZone("10.in-addr.arpa.")
+
+  r53.InjectError("ChangeResourceRecordSets", awserr.New(route53.ErrCodePriorRequestNotComplete, "still processing a prior request", nil))
+  r53.InjectError("WaitUntilResourceRecordSetsChanged", awserr.New("Throttling", "Rate exceeded", nil))
+  _, err := runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 3 {
+    t.Errorf("A record is not added: %v", r53.ResourceRecordSets(zoneID))
+  }
+
+  // InvalidChangeBatch is not retried, the step is rolled back
+  r53.InjectError("ChangeResourceRecordSets", awserr.New(route53.ErrCodeInvalidChangeBatch, "invalid", nil))
+  _, err = runApp(t, r53, "--max-attempts", "3", "--conf", conf, "add", "-z", "example.com", "-H", "api", "-i", "10.0.1.16")
+  if err == nil || err.Error() != "InvalidChangeBatch: invalid" {
+    t.Errorf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 3 {
+    t.Errorf("want no new record, actual %v", r53.ResourceRecordSets(zoneID))
+  }
+}
+
+func TestAddAmbiguousFailure(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+
+  // the A record is committed but its response fails: it is not sent
+  // again, and found applied it is rolled back with the rest
+  r53.InjectResponseError("ChangeResourceRecordSets", awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service Unavailable", nil), 503, "req-1"))
+  _, err := runApp(t, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err == nil || !strings.HasPrefix(err.Error(), "ServiceUnavailable: Service Unavailable") {
+    t.Errorf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+    t.Errorf("records are not rolled back: %v", r53.ResourceRecordSets(zoneID))
+  }
+  entries, err := utils.ListJournalEntries(journal)
+  if err != nil || len(entries) != 1 || entries[0].Status != utils.JournalRolledBack {
+    t.Errorf("want a rolled back journal entry, actual %+v (%v)", entries, err)
+  }
+
+  // the batch is not committed: nothing is left to roll back
+  r53.InjectError("ChangeResourceRecordSets", awserr.NewRequestFailure(awserr.New("InternalError", "Internal Error", nil), 500, "req-2"))
+  _, err = runApp(t, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err == nil || !strings.HasPrefix(err.Error(), "InternalError: Internal Error") {
+    t.Errorf("unexpected error: %v", err)
+  }
+  entries, _ = utils.ListJournalEntries(journal)
+  if len(entries) != 2 || entries[1].Status != utils.JournalRolledBack || entries[1].Steps[0].Status != utils.JournalPending {
+    t.Errorf("want a pending step, actual %+v", entries)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 {
+    t.Errorf("want no new record, actual %v", r53.ResourceRecordSets(zoneID))
+  }
+}
+
+func TestAddDeleteFromFile(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+
+  // more hosts than fit in a change batch
+  var lines []string
+  lines = append(lines, "hostname,ip")
+  for i := 0; i < 1200; i++ {
+    lines = append(lines, fmt.Sprintf("host%d,10.0.%d.%d", i, i/256, i%256))
+  }
+  hosts := filepath.Join(dir, "hosts.csv")
+  err := ioutil.WriteFile(hosts, []byte(strings.Join(lines, "\n")), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // the first PTR batch fails, the submitted batches are rolled back
+  r53.InjectError("ChangeResourceRecordSets", nil)
+  r53.InjectError("ChangeResourceRecordSets", nil)
+  r53.InjectError("ChangeResourceRecordSets", awserr.New(route53.ErrCodeInvalidChangeBatch, "invalid", nil))
+  _, err = runApp(t, r53, "--no-journal", "--conf", conf, "add", "-z", "example.com", "--from-file", hosts)
+  if err == nil || err.Error() != "InvalidChangeBatch: invalid" {
+    t.Errorf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+    t.Errorf("records are not rolled back: %d and %d record sets", len(r53.ResourceRecordSets(zoneID)), len(r53.ResourceRecordSets(reverseID)))
+  }
+  before := len(r53.Changes())
+
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "--from-file", hosts)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 1202 || len(r53.ResourceRecordSets(reverseID)) != 1202 {
+    t.Errorf("want 1200 hosts, actual %d and %d record sets", len(r53.ResourceRecordSets(zoneID)), len(r53.ResourceRecordSets(reverseID)))
+  }
+  changes := r53.Changes()[before:]
+  if len(changes) != 4 {
+    t.Errorf("want 4 change batches, actual %d", len(changes))
+  }
+  for _, change := range changes {
+    if aws.StringValue(change.Status) != route53.ChangeStatusInsync {
+      t.Errorf("change %s is not waited for", aws.StringValue(change.Id))
+    }
+  }
+
+  _, err = runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "--from-file", hosts, "-H", "www")
+  if err == nil || err.Error() != "from-file can not be used with hostname" {
+    t.Errorf("unexpected error: %v", err)
+  }
+
+  _, err = runApp(t, r53, "--conf", conf, "delete", "-z", "example.com", "--from-file", hosts)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+    t.Errorf("want no hosts, actual %d and %d record sets", len(r53.ResourceRecordSets(zoneID)), len(r53.ResourceRecordSets(reverseID)))
+  }
+}
+
+func TestExport(t *testing.T) {
+  r53 := fakeroute53.New()
+  r53.CreateHostedZone("example.com.")
+  r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+
+  _, err := runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  out, err := runApp(t, r53, "export", "-z", "example.com")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if !strings.Contains(out, "www.example.com.") {
+    t.Errorf("unexpected output: %q", out)
+  }
+
+  // --file gets the same zone file
+  zoneFile := filepath.Join(dir, "example.com.zone")
+  fileOut, err := runApp(t, r53, "export", "-z", "example.com", "-f", zoneFile)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  written, err := ioutil.ReadFile(zoneFile)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(fileOut) > 0 || string(written) != out {
+    t.Errorf("want %q in the file only, actual %q and %q", out, string(written), fileOut)
+  }
+}
+
+func TestImport(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+
+  var changes []*route53.Change
+  for _, id := range []string{"blue", "green"} {
+    changes = append(changes, &route53.Change{
+      Action: aws.String(route53.ChangeActionCreate),
+      ResourceRecordSet: &route53.ResourceRecordSet{
+        Name: aws.String("www.example.com."),
+        Type: aws.String(route53.RRTypeA),
+        TTL: aws.Int64(300),
+        SetIdentifier: aws.String(id),
+        Weight: aws.Int64(50),
+        ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10.0.2.1")}},
+      },
+    })
+  }
+  changes = append(changes, &route53.Change{
+    Action: aws.String(route53.ChangeActionCreate),
+    ResourceRecordSet: &route53.ResourceRecordSet{
+      Name: aws.String("api.example.com."),
+      Type: aws.String(route53.RRTypeA),
+      TTL: aws.Int64(300),
+      ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10.0.2.2")}},
+    },
+  })
+  _, err := r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+    HostedZoneId: aws.String(zoneID),
+    ChangeBatch: &route53.ChangeBatch{Changes: changes},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // more record sets than fit in a change batch, next to the weighted ones
+  lines := []string{"$TTL 300", "api IN A 10.0.2.3"}
+  for i := 0; i < 1100; i++ {
+    lines = append(lines, fmt.Sprintf("host%d IN A 10.0.%d.%d", i, i/256, i%256))
+  }
+  zoneFile := filepath.Join(dir, "example.com.zone")
+  err = ioutil.WriteFile(zoneFile, []byte(strings.Join(lines, "\n")), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  before := len(r53.Changes())
+  _, err = runApp(t, r53, "import", "-z", "example.com", "-f", zoneFile)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(r53.Changes())-before != 2 {
+    t.Errorf("want 2 change batches, actual %d", len(r53.Changes())-before)
+  }
+  rrsets := r53.ResourceRecordSets(zoneID)
+  if len(rrsets) != 1105 {
+    t.Errorf("want 1105 record sets, actual %d", len(rrsets))
+  }
+  for _, rrset := range rrsets {
+    name := aws.StringValue(rrset.Name)
+    if (name == "api.example.com." && aws.StringValue(rrset.ResourceRecords[0].Value) != "10.0.2.3") ||
+      (name == "www.example.com." && aws.StringValue(rrset.ResourceRecords[0].Value) != "10.0.2.1") {
+      t.Errorf("unexpected record set: %v", rrset)
+    }
+  }
+
+  // importing it again changes nothing
+  before = len(r53.Changes())
+  _, err = runApp(t, r53, "import", "-z", "example.com", "-f", zoneFile)
+  if err != nil || len(r53.Changes()) != before {
+    t.Errorf("want no change, actual %d changes (%v)", len(r53.Changes())-before, err)
+  }
+
+  err = ioutil.WriteFile(zoneFile, []byte("www 300 IN A 10.0.2.4\n"), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = runApp(t, r53, "import", "-z", "example.com", "-f", zoneFile)
+  if err == nil || err.Error() != "www.example.com. A has record sets with a routing policy in the hosted zone" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
+
+func TestAddFromFileAmbiguousFailure(t *testing.T) {
+  r53 := fakeroute53.New()
+  zoneID := r53.CreateHostedZone("example.com.")
+  reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+  hosts := filepath.Join(dir, "hosts.csv")
+  err := ioutil.WriteFile(hosts, []byte("hostname,ip\nwww,10.0.1.15\napi,10.0.1.16\n"), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // the PTR batch is committed but its response fails: it is found applied
+  // and rolled back with the address records
+  r53.InjectResponseError("ChangeResourceRecordSets", nil)
+  r53.InjectResponseError("ChangeResourceRecordSets", awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service Unavailable", nil), 503, "req-1"))
+  _, err = runApp(t, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "--from-file", hosts)
+  if err == nil || !strings.HasPrefix(err.Error(), "ServiceUnavailable: Service Unavailable") {
+    t.Errorf("unexpected error: %v", err)
+  }
+  if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+    t.Errorf("records are not rolled back: %v and %v", r53.ResourceRecordSets(zoneID), r53.ResourceRecordSets(reverseID))
+  }
+  entries, err := utils.ListJournalEntries(journal)
+  if err != nil || len(entries) != 1 || entries[0].Status != utils.JournalRolledBack {
+    t.Fatalf("want a rolled back journal entry, actual %+v (%v)", entries, err)
+  }
+  for idx, step := range entries[0].Steps {
+    if step.Status != utils.JournalRolledBack {
+      t.Errorf("step %d: want rolled back, actual %s", idx+1, step.Status)
+    }
+  }
+}
+
+func TestDeleteMultiValue(t *testing.T) {
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  hosts := filepath.Join(dir, "hosts.csv")
+  err := ioutil.WriteFile(hosts, []byte("hostname,ip\nwww,\n"), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  patterns := [][]string{
+    {"--conf", conf, "delete", "-z", "example.com", "-H", "www", "-t", "A"},
+    {"--conf", conf, "delete", "-z", "example.com", "--from-file", hosts},
+  }
+
+  for idx, args := range patterns {
+    r53 := fakeroute53.New()
+    zoneID := r53.CreateHostedZone("example.com.")
+    reverseID := r53.CreateHostedZone("10.in-addr.arpa.")
+
+    // www has two addresses, each with its PTR record
+    _, err := runApp(t, r53, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+    if err != nil {
+      t.Fatalf("unexpected error: %v", err)
+    }
+    _, err = r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+      HostedZoneId: aws.String(zoneID),
+      ChangeBatch: &route53.ChangeBatch{Changes: []*route53.Change{{
+        Action: aws.String(route53.ChangeActionUpsert),
+        ResourceRecordSet: &route53.ResourceRecordSet{
+          Name: aws.String("www.example.com."),
+          Type: aws.String(route53.RRTypeA),
+          TTL: aws.Int64(300),
+          ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10.0.1.15")}, {Value: aws.String("10.0.1.16")}},
+        },
+      }}},
+    })
+    if err != nil {
+      t.Fatal(err)
+    }
+    _, err = r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+      HostedZoneId: aws.String(reverseID),
+      ChangeBatch: &route53.ChangeBatch{Changes: []*route53.Change{{
+        Action: aws.String(route53.ChangeActionCreate),
+        ResourceRecordSet: &route53.ResourceRecordSet{
+          Name: aws.String("16.1.0.10.in-addr.arpa."),
+          Type: aws.String(route53.RRTypePtr),
+          TTL: aws.Int64(300),
+          ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("www.example.com.")}},
+        },
+      }}},
+    })
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    _, err = runApp(t, r53, args...)
+    if err != nil {
+      t.Fatalf("unexpected error (%d): %v", idx, err)
+    }
+    if len(r53.ResourceRecordSets(zoneID)) != 2 || len(r53.ResourceRecordSets(reverseID)) != 2 {
+      t.Errorf("pattern %d: want both PTR records deleted, actual %v", idx, r53.ResourceRecordSets(reverseID))
+    }
+  }
+}
+
+func TestNoWaitStatus(t *testing.T) {
+  r53 := fakeroute53.New()
+  r53.CreateHostedZone("example.com.")
+  r53.CreateHostedZone("10.in-addr.arpa.")
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+
+  out, err := runApp(t, r53, "--no-wait", "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  ids := strings.Fields(out)
+  changes := r53.Changes()
+  if len(ids) != 2 || len(changes) != 2 || ids[0] != aws.StringValue(changes[0].Id) || ids[1] != aws.StringValue(changes[1].Id) {
+    t.Fatalf("want the change IDs %v, actual %q", changes, out)
+  }
+  entries, _ := utils.ListJournalEntries(journal)
+  if len(entries) != 1 || entries[0].Status != utils.JournalApplied {
+    t.Errorf("unexpected journal entries: %+v", entries)
+  }
+
+  out, err = runApp(t, r53, "status", ids[0], strings.TrimPrefix(ids[1], "/change/"))
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  lines := strings.Split(strings.TrimSpace(out), "\n")
+  if len(lines) != 2 || !strings.HasPrefix(lines[0], ids[0]+"\tPENDING\t") || !strings.HasPrefix(lines[1], ids[1]+"\tPENDING\t") {
+    t.Errorf("unexpected status: %q", out)
+  }
+
+  go func() {
+    time.Sleep(50 * time.Millisecond)
+    r53.SyncChanges()
+  }()
+  out, err = runApp(t, r53, "status", "--wait", "--interval", "10ms", "-o", "json", ids[0], ids[1])
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  var statuses []utils.ChangeStatus
+  err = json.Unmarshal([]byte(out), &statuses)
+  if err != nil || len(statuses) != 2 || !statuses[0].InSync() || !statuses[1].InSync() {
+    t.Errorf("want INSYNC, actual %q (%v)", out, err)
+  }
+
+  _, err = runApp(t, r53, "status", "/change/CUNKNOWN")
+  if err == nil || !strings.HasPrefix(err.Error(), "change /change/CUNKNOWN: NoSuchChange") {
+    t.Errorf("unexpected error: %v", err)
+  }
+
+  _, err = runApp(t, r53, "status", "--wait", "--interval", "0s", ids[0])
+  if err == nil || err.Error() != "interval must be positive: 0s" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
+
+func TestZoneLifecycle(t *testing.T) {
+  r53 := fakeroute53.New()
+  dir := tempDir(t)
+  defer os.RemoveAll(dir)
+  conf := writeConf(t, dir)
+  journal := filepath.Join(dir, "journal")
+
+  out, err := runApp(t, r53, "zone", "create", "-z", "example.com", "--caller-reference", "ref-1", "--comment", "public", "-o", "json")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  var public utils.HostedZone
+  err = json.Unmarshal([]byte(out), &public)
+  if err != nil || public.Name != "example.com." || public.Private || public.Comment != "public" || len(public.NameServers) != 4 {
+    t.Fatalf("unexpected zone: %q (%v)", out, err)
+  }
+
+  _, err = runApp(t, r53, "zone", "create", "-z", "example.org", "--caller-reference", "ref-1")
+  if err == nil || !strings.HasPrefix(err.Error(), route53.ErrCodeHostedZoneAlreadyExists) {
+    t.Errorf("unexpected error: %v", err)
+  }
+
+  _, err = runApp(t, r53, "--region", "ap-northeast-1", "zone", "create", "-z", "example.com", "--vpc-id", "vpc-1")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "zone", "create", "--network", "10.0.0.0/8")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  out, err = runApp(t, r53, "zone", "list")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  lines := strings.Split(strings.TrimSpace(out), "\n")
+  if len(lines) != 3 || !strings.Contains(lines[0], "\t10.in-addr.arpa.\tpublic\t2\t") || !strings.Contains(lines[1], "\texample.com.\t") || !strings.Contains(lines[2], "\texample.com.\t") {
+    t.Errorf("unexpected zones: %q", out)
+  }
+
+  var privateID string
+  for _, line := range lines {
+    if strings.Contains(line, "\tprivate\t") {
+      privateID = strings.Fields(line)[0]
+    }
+  }
+  out, err = runApp(t, r53, "zone", "show", "--id", privateID)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if !strings.Contains(out, "private\ttrue\n") || !strings.Contains(out, "vpc\tvpc-1\tap-northeast-1\n") || strings.Contains(out, "name server") {
+    t.Errorf("unexpected zone: %q", out)
+  }
+
+  _, err = runApp(t, r53, "--journal-dir", journal, "--conf", conf, "add", "-z", "example.com", "-H", "www", "-i", "10.0.1.15")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "zone", "delete", "--id", public.ID)
+  if err == nil || err.Error() != "hosted zone example.com. is not empty: 1 record sets, use --force to delete them" {
+    t.Errorf("unexpected error: %v", err)
+  }
+  _, err = runApp(t, r53, "--journal-dir", journal, "zone", "delete", "--id", public.ID, "--force")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if r53.ResourceRecordSets(public.ID) != nil {
+    t.Errorf("hosted zone %s is not deleted", public.ID)
+  }
+  entries, _ := utils.ListJournalEntries(journal)
+  if len(entries) != 2 || entries[1].Command != "zone delete" || entries[1].Status != utils.JournalApplied {
+    t.Errorf("unexpected journal entries: %+v", entries)
+  }
+
+  _, err = runApp(t, r53, "--no-journal", "zone", "delete", "-z", "10.in-addr.arpa", "--force")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  out, _ = runApp(t, r53, "zone", "list")
+  if strings.Count(out, "\n") != 1 {
+    t.Errorf("unexpected zones: %q", out)
+  }
+}
+
+func TestZoneDelegate(t *testing.T) {
+  r53 := fakeroute53.New()
+  parentID := r53.CreateHostedZone("example.com.")
+  journal := filepath.Join(tempDir(t), "journal")
+  defer os.RemoveAll(filepath.Dir(journal))
+
+  _, err := runApp(t, r53, "zone", "delegate", "-p", "example.com", "-c", "team.example.org")
+  if err == nil || err.Error() != "team.example.org. is not a subdomain of example.com." {
+    t.Errorf("unexpected error: %v", err)
+  }
+
+  out, err := runApp(t, r53, "--journal-dir", journal, "zone", "delegate", "-p", "example.com", "-c", "team.example.com", "-o", "json")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  var d utils.Delegation
+  err = json.Unmarshal([]byte(out), &d)
+  if err != nil || d.Action != "created" || !d.InSync() || len(d.NameServers) != 4 {
+    t.Fatalf("unexpected delegation: %q (%v)", out, err)
+  }
+  child := r53.ResourceRecordSets(d.ChildID)
+  ns, _ := utils.FilterResourceRecordSets(r53.ResourceRecordSets(parentID), "team.example.com.", "NS", "")
+  if child == nil || len(ns) != 1 || aws.Int64Value(ns[0].TTL) != utils.DefaultDelegationTTL || len(ns[0].ResourceRecords) != 4 {
+    t.Fatalf("unexpected NS record sets: %v", ns)
+  }
+
+  // running it again changes nothing
+  changes := len(r53.Changes())
+  out, err = runApp(t, r53, "--journal-dir", journal, "zone", "delegate", "-p", "example.com", "-c", "team.example.com")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if !strings.Contains(out, "status\tin-sync\n") || strings.Contains(out, "action") || len(r53.Changes()) != changes {
+    t.Errorf("unexpected output: %q", out)
+  }
+  _, err = runApp(t, r53, "zone", "delegate", "--check", "-p", "example.com", "-c", "team.example.com")
+  if err != nil {
+    t.Errorf("unexpected error: %v", err)
+  }
+
+  stale := *ns[0]
+  stale.ResourceRecords = []*route53.ResourceRecord{{Value: aws.String("ns-0.awsdns-00.com.")}}
+  r53.PutResourceRecordSets(parentID, &stale)
+  out, err = runApp(t, r53, "zone", "delegate", "--check", "-p", "example.com", "-c", "team.example.com")
+  if err == nil || err.Error() != "delegation of team.example.com. in example.com. is mismatch" || !strings.Contains(out, "delegated to\tns-0.awsdns-00.com.\n") {
+    t.Errorf("unexpected result: %q (%v)", out, err)
+  }
+
+  out, err = runApp(t, r53, "--journal-dir", journal, "zone", "delegate", "-p", "example.com", "-c", "team.example.com")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if !strings.Contains(out, "action\tupdated\n") {
+    t.Errorf("unexpected output: %q", out)
+  }
+  _, err = runApp(t, r53, "zone", "delegate", "--check", "-p", "example.com", "-c", "team.example.com")
+  if err != nil {
+    t.Errorf("unexpected error: %v", err)
+  }
+
+  // the journal can undo the correction
+  entries, _ := utils.ListJournalEntries(journal)
+  if len(entries) != 2 {
+    t.Fatalf("unexpected journal entries: %+v", entries)
+  }
+  _, err = runApp(t, r53, "--journal-dir", journal, "undo", entries[1].ID)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  ns, _ = utils.FilterResourceRecordSets(r53.ResourceRecordSets(parentID), "team.example.com.", "NS", "")
+  if len(ns) != 1 || len(ns[0].ResourceRecords) != 1 || aws.StringValue(ns[0].ResourceRecords[0].Value) != "ns-0.awsdns-00.com." {
+    t.Errorf("unexpected NS record sets: %v", ns)
+  }
+}
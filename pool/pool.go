@@ -0,0 +1,92 @@
+package pool
+
+import (
+	"fmt"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "pool",
+  Usage: "IP pools of the config file",
+  Subcommands: []*cli.Command{
+    {
+      Name: "status",
+      Usage: "show the utilization of IP pools",
+      Action: doStatus,
+      Flags: []cli.Flag{
+        &cli.StringFlag{
+          Name: "zone",
+          Usage: "Hosted Zone name",
+          Required: true,
+          Aliases: []string{"z"},
+        },
+        &cli.StringFlag{
+          Name: "pool",
+          Usage: "pool name (default: all pools)",
+          Aliases: []string{"p"},
+        },
+        &cli.StringFlag{
+          Name: "output",
+          Usage: "output format (text or json)",
+          Value: "text",
+          Aliases: []string{"o"},
+        },
+      },
+    },
+  },
+}
+
+func doStatus(c *cli.Context) (err error) {
+  var confToml utils.ConfToml
+  err = utils.LoadConf(c.String("conf"), &confToml)
+  if err != nil {
+    return err
+  }
+
+  var pools []utils.Pool
+  if c.IsSet("pool") {
+    pool, err := confToml.GetPool(c.String("pool"))
+    if err != nil {
+      return err
+    }
+    pools = append(pools, pool)
+  } else {
+    for _, p := range confToml.Pools {
+      pool, err := utils.NewPool(p)
+      if err != nil {
+        return err
+      }
+      pools = append(pools, pool)
+    }
+  }
+  if len(pools) == 0 {
+    return fmt.Errorf("no pool in the config file")
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zoneID, err := awsClient.GetHostedZoneID(c.String("zone"))
+  if err != nil {
+    return err
+  }
+  rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return err
+  }
+
+  var statuses []utils.PoolStatus
+  for _, pool := range pools {
+    used, err := awsClient.PoolUsedAddresses(pool, zoneID, rInfos)
+    if err != nil {
+      return err
+    }
+    statuses = append(statuses, pool.Status(used))
+  }
+  return utils.WritePoolStatuses(c.App.Writer, statuses, c.String("output"))
+}
@@ -0,0 +1,28 @@
+package resume
+
+import (
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "resume",
+  Usage: "finish an interrupted journal entry",
+  ArgsUsage: "[journal-id] (default: the latest interrupted entry)",
+  Action: doResume,
+}
+
+func doResume(c *cli.Context) (err error) {
+  entry, err := utils.LoadInterruptedEntry(utils.JournalDirFromContext(c), c.Args().First())
+  if err != nil {
+    return err
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  return awsClient.Resume(entry)
+}
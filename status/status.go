@@ -0,0 +1,60 @@
+package status
+
+import (
+	"fmt"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "status",
+  Usage: "show the status of changes submitted with --no-wait",
+  ArgsUsage: "<change-id>...",
+  Action: doStatus,
+  Flags: []cli.Flag{
+    &cli.BoolFlag{
+      Name: "wait",
+      Usage: "poll until all the changes are in sync",
+      Aliases: []string{"w"},
+    },
+    &cli.DurationFlag{
+      Name: "interval",
+      Usage: "polling interval of --wait",
+      Value: utils.DefaultStatusInterval,
+    },
+    &cli.StringFlag{
+      Name: "output",
+      Usage: "output format (text or json)",
+      Value: "text",
+      Aliases: []string{"o"},
+    },
+  },
+}
+
+func doStatus(c *cli.Context) (err error) {
+  if c.NArg() == 0 {
+    return fmt.Errorf("usage: status <change-id>...")
+  }
+  if c.Duration("interval") <= 0 {
+    return fmt.Errorf("interval must be positive: %v", c.Duration("interval"))
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+
+  var statuses []utils.ChangeStatus
+  if c.Bool("wait") {
+    statuses, err = awsClient.WaitChangeStatuses(c.Args().Slice(), c.Duration("interval"))
+  } else {
+    statuses, err = awsClient.GetChangeStatuses(c.Args().Slice())
+  }
+  if err != nil {
+    return err
+  }
+  return utils.WriteChangeStatuses(c.App.Writer, statuses, c.String("output"))
+}
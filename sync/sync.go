@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "sync",
+  Usage: "reconcile a hosted zone with a desired state file",
+  Action: doSync,
+  Flags: []cli.Flag{
+    &cli.StringFlag{
+      Name: "zone",
+      Usage: "Hosted Zone name",
+      Required: true,
+      Aliases: []string{"z"},
+    },
+    &cli.StringFlag{
+      Name: "file",
+      Usage: "path to desired state file",
+      Required: true,
+      Aliases: []string{"f"},
+    },
+    &cli.BoolFlag{
+      Name: "no-prune",
+      Usage: "keep records which are not in the desired state file",
+    },
+    &cli.BoolFlag{
+      Name: "replace-ptr",
+      Usage: "replace the PTR records of the desired addresses when they point at another host",
+    },
+  },
+}
+
+type syncData struct {
+  zonename string
+  zoneID string
+  state utils.DesiredState
+}
+
+func doSync(c *cli.Context) (err error) {
+  var data syncData
+  data.zonename = c.String("zone")
+
+  err = utils.LoadDesiredState(c.String("file"), &data.state)
+  if err != nil {
+    return err
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  data.zoneID, err = awsClient.GetHostedZoneID(data.zonename)
+  if err != nil {
+    return err
+  }
+
+  var confToml utils.ConfToml
+  err = utils.LoadConf(c.String("conf"), &confToml)
+  if err != nil {
+    return err
+  }
+
+  rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return err
+  }
+
+  plan, err := awsClient.PlanSync(data.zoneID, data.zonename, data.state, rInfos, !c.Bool("no-prune"), c.Bool("replace-ptr"))
+  if err != nil {
+    return err
+  }
+
+  return awsClient.ApplyChangePlan(plan)
+}
@@ -0,0 +1,53 @@
+package undo
+
+import (
+	"fmt"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "undo",
+  Usage: "revert the changes of a journal entry",
+  ArgsUsage: "<journal-id>",
+  Action: doUndo,
+  Flags: []cli.Flag{
+    &cli.BoolFlag{
+      Name: "list",
+      Usage: "list the journal entries instead",
+      Aliases: []string{"l"},
+    },
+  },
+}
+
+func doUndo(c *cli.Context) (err error) {
+  dir := utils.JournalDirFromContext(c)
+  if len(dir) == 0 {
+    return fmt.Errorf("the journal is disabled")
+  }
+
+  if c.Bool("list") {
+    entries, err := utils.ListJournalEntries(dir)
+    if err != nil {
+      return err
+    }
+    return utils.WriteJournalEntries(c.App.Writer, entries)
+  }
+
+  if c.NArg() != 1 {
+    return fmt.Errorf("usage: undo <journal-id>")
+  }
+  entry, err := utils.LoadJournalEntry(dir, c.Args().First())
+  if err != nil {
+    return err
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  return awsClient.Undo(entry)
+}
@@ -0,0 +1,125 @@
+package update
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "update",
+  Aliases: []string{"u"},
+  Usage: "update command",
+  Action: doUpdate,
+  Flags: append([]cli.Flag{
+    &cli.StringFlag{
+      Name: "hostname",
+      Usage: "hostname",
+      Required: true,
+      Aliases: []string{"H"},
+    },
+    &cli.StringFlag{
+      Name: "ip",
+      Usage: "new IP Address (IPv4 or IPv6)",
+      Aliases: []string{"i"},
+    },
+    &cli.StringFlag{
+      Name: "cname",
+      Usage: "new CNAME record",
+      Aliases: []string{"c"},
+    },
+    &cli.Int64Flag{
+      Name: "ttl",
+      Usage: "new TTL (default: keep the current TTL)",
+    },
+    &cli.StringFlag{
+      Name: "zone",
+      Usage: "Hosted Zone name",
+      Required: true,
+      Aliases: []string{"z"},
+    },
+    &cli.BoolFlag{
+      Name: "replace-ptr",
+      Usage: "replace the PTR record of the new IP Address when it points at another host",
+    },
+  }, utils.RoutingPolicyFlags...),
+}
+
+type updateData struct {
+  hostname string
+  ip net.IP
+  cname string
+  zonename string
+  zoneID string
+  rrType string
+  policy utils.RoutingPolicy
+}
+
+func doUpdate(c *cli.Context) (err error) {
+  if len(c.String("ip")) > 0 && len(c.String("cname")) > 0 {
+    return fmt.Errorf("choose ip or cname")
+  }
+  if len(c.String("ip")) == 0 && len(c.String("cname")) == 0 {
+    return fmt.Errorf("choose ip or cname")
+  }
+
+  var data updateData
+  data.zonename = c.String("zone")
+  data.hostname = utils.Fqdn(c.String("hostname"), data.zonename)
+  data.cname = c.String("cname")
+  data.rrType = "CNAME"
+  if len(c.String("ip")) > 0 {
+    data.ip = net.ParseIP(c.String("ip"))
+    if data.ip == nil {
+      return fmt.Errorf("invalid ip: %s", c.String("ip"))
+    }
+    data.rrType = utils.AddressRecordType(data.ip)
+  }
+
+  data.policy, err = utils.RoutingPolicyFromContext(c)
+  if err != nil {
+    return err
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  data.zoneID, err = awsClient.GetHostedZoneID(data.zonename)
+  if err != nil {
+    return err
+  }
+
+  rr, err := awsClient.FindResourceRecordSet(data.hostname, data.rrType, data.policy.SetIdentifier, data.zoneID)
+  if err != nil {
+    return err
+  }
+
+  var plan *utils.ChangePlan
+  if data.ip != nil {
+    var confToml utils.ConfToml
+    err = utils.LoadConf(c.String("conf"), &confToml)
+    if err != nil {
+      return err
+    }
+    rInfos, err := awsClient.ResolveReverseHostedZoneInfos(confToml)
+    if err != nil {
+      return err
+    }
+    plan, err = awsClient.PlanUpdateAResourceRecordSet(rr, data.ip, c.Int64("ttl"), data.policy, c.Bool("replace-ptr"), data.zoneID, rInfos)
+    if err != nil {
+      return err
+    }
+  } else {
+    plan, err = awsClient.PlanUpdateCnameResourceRecordSet(rr, data.cname, c.Int64("ttl"), data.policy, data.zoneID)
+    if err != nil {
+      return err
+    }
+  }
+
+  return awsClient.ApplyChangePlan(plan)
+}
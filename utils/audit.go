@@ -0,0 +1,260 @@
+package utils
+
+import (
+  "fmt"
+  "io"
+  "net"
+  "sort"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// Audit issue kinds.
+const (
+  // AuditMissingPtr is an address record whose address has no PTR record.
+  AuditMissingPtr = "missing-ptr"
+  // AuditWrongPtr is an address record whose PTR record points at another
+  // host.
+  AuditWrongPtr = "wrong-ptr"
+  // AuditOrphanPtr is a PTR record pointing at a host of the zone which has
+  // no address record.
+  AuditOrphanPtr = "orphan-ptr"
+  // AuditStalePtr is a PTR record pointing at a host of the zone which has
+  // other addresses.
+  AuditStalePtr = "stale-ptr"
+  // AuditNoReverseZone is an address record whose address is not covered by
+  // any reverse hosted zone.
+  AuditNoReverseZone = "no-reverse-zone"
+  // AuditMissingCname is an address of a classless reverse hosted zone whose
+  // name has no CNAME record in the parent zone.
+  AuditMissingCname = "missing-cname"
+  // AuditWrongCname is an address of a classless reverse hosted zone whose
+  // CNAME record in the parent zone points at another name.
+  AuditWrongCname = "wrong-cname"
+)
+
+// AuditIssue is a mismatch between the address records of a hosted zone and
+// the PTR records of the reverse hosted zones.
+type AuditIssue struct {
+  Kind string `json:"kind"`
+  Name string `json:"name"`
+  IP string `json:"ip"`
+  Detail string `json:"detail"`
+  Fixable bool `json:"fixable"`
+}
+
+// AuditReverseRecords compares the address records of the hosted zone with
+// the PTR records of the reverse hosted zones, and with the CNAME records
+// of the parent zones of the classless ones. It returns the issues found and
+// the plan which repairs the fixable ones.
+func (client *AWSClientImpl) AuditReverseRecords(hostedZoneID string, zoneName string, rInfos ReverseHostedZoneInfos) (issues []AuditIssue, plan *ChangePlan, err error) {
+  forward, err := client.ListAllResourceRecords(hostedZoneID)
+  if err != nil {
+    return nil, nil, err
+  }
+  records := map[string][]*route53.ResourceRecordSet{}
+  for _, rInfo := range rInfos.ReverseHostedZoneInfo {
+    for _, id := range []string{rInfo.HostedZoneID, rInfo.ParentHostedZoneID} {
+      if _, ok := records[id]; ok || len(id) == 0 {
+        continue
+      }
+      records[id], err = client.ListAllResourceRecords(id)
+      if err != nil {
+        return nil, nil, err
+      }
+    }
+  }
+  issues, plan = buildAudit(zoneName, forward, records, rInfos)
+  return issues, plan, nil
+}
+
+func buildAudit(zoneName string, forward []*route53.ResourceRecordSet, records map[string][]*route53.ResourceRecordSet, rInfos ReverseHostedZoneInfos) (issues []AuditIssue, plan *ChangePlan) {
+  // hosts by address and addresses by host of the address records
+  hostsByIP := map[string][]string{}
+  ipsByHost := map[string]map[string]bool{}
+  var ttls = map[string]*int64{}
+  for _, rrset := range forward {
+    rrType := aws.StringValue(rrset.Type)
+    if (rrType != route53.RRTypeA && rrType != route53.RRTypeAaaa) || rrset.AliasTarget != nil {
+      continue
+    }
+    host := strings.ToLower(aws.StringValue(rrset.Name))
+    if ipsByHost[host] == nil {
+      ipsByHost[host] = map[string]bool{}
+    }
+    for _, rr := range rrset.ResourceRecords {
+      ip := net.ParseIP(aws.StringValue(rr.Value))
+      if ip == nil {
+        continue
+      }
+      if !ipsByHost[host][ip.String()] {
+        hostsByIP[ip.String()] = append(hostsByIP[ip.String()], host)
+      }
+      ipsByHost[host][ip.String()] = true
+      ttls[ip.String()+" "+host] = rrset.TTL
+    }
+  }
+
+  reverse := &changeSteps{}
+  // PTR records which belong to an address record, by hosted zone and name
+  claimed := map[string]bool{}
+
+  ips := make([]string, 0, len(hostsByIP))
+  for ip := range hostsByIP {
+    ips = append(ips, ip)
+  }
+  sortIPs(ips)
+  for _, ipString := range ips {
+    ip := net.ParseIP(ipString)
+    hosts := hostsByIP[ipString]
+    sort.Strings(hosts)
+    rInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
+    if err != nil {
+      issues = append(issues, AuditIssue{Kind: AuditNoReverseZone, Name: hosts[0], IP: ipString, Detail: err.Error()})
+      continue
+    }
+    name := rInfo.PtrRecordName(ip)
+    claimed[rInfo.HostedZoneID+" "+strings.ToLower(name)] = true
+    ptr := findResourceRecordSet(records[rInfo.HostedZoneID], name, route53.RRTypePtr)
+    wanted := rInfo.ptrResourceRecordSet(ip, hosts[0])
+    if ttl := ttls[ipString+" "+hosts[0]]; ttl != nil {
+      wanted.TTL = ttl
+    }
+
+    switch {
+    case ptr == nil:
+      issues = append(issues, AuditIssue{Kind: AuditMissingPtr, Name: hosts[0], IP: ipString,
+        Detail: fmt.Sprintf("no PTR record %s in %s", name, rInfo.HostedZoneName), Fixable: true})
+      reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName).AppendChange(route53.ChangeActionUpsert, wanted, ptr)
+    case !ptrPointsAtAny(ptr, hosts):
+      issues = append(issues, AuditIssue{Kind: AuditWrongPtr, Name: hosts[0], IP: ipString,
+        Detail: fmt.Sprintf("PTR record %s points at %s", name, strings.Join(ptrValues(ptr), " ")), Fixable: true})
+      wanted.TTL = ptr.TTL
+      reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName).AppendChange(route53.ChangeActionUpsert, wanted, ptr)
+    }
+
+    // the parent zone delegates the name of the address to the classless zone
+    if rInfo.parentCnameResourceRecordSet(ip) == nil {
+      continue
+    }
+    cnameName := GenerateReverseRecord(ip)
+    cname := findResourceRecordSet(records[rInfo.ParentHostedZoneID], cnameName, route53.RRTypeCname)
+    switch {
+    case cname == nil:
+      issues = append(issues, AuditIssue{Kind: AuditMissingCname, Name: hosts[0], IP: ipString,
+        Detail: fmt.Sprintf("no CNAME record %s in %s", cnameName, rInfo.ParentHostedZoneName), Fixable: true})
+    case !ptrPointsAt(cname, name):
+      issues = append(issues, AuditIssue{Kind: AuditWrongCname, Name: hosts[0], IP: ipString,
+        Detail: fmt.Sprintf("CNAME record %s points at %s", cnameName, strings.Join(ptrValues(cname), " ")), Fixable: true})
+    default:
+      continue
+    }
+    upsertParentCname(reverse, rInfo, ip, cname)
+  }
+
+  seen := map[string]bool{}
+  for _, rInfo := range rInfos.ReverseHostedZoneInfo {
+    if seen[rInfo.HostedZoneID] {
+      continue
+    }
+    seen[rInfo.HostedZoneID] = true
+    for _, ptr := range records[rInfo.HostedZoneID] {
+      if aws.StringValue(ptr.Type) != route53.RRTypePtr || ptr.SetIdentifier != nil {
+        continue
+      }
+      name := aws.StringValue(ptr.Name)
+      if claimed[rInfo.HostedZoneID+" "+strings.ToLower(name)] {
+        continue
+      }
+      ip := rInfo.PtrRecordIP(name)
+      if ip == nil {
+        continue
+      }
+      // the address belongs to a more specific reverse hosted zone
+      if owner, err := GetReverseHostedZoneInfo(ip, rInfos); err != nil || owner.HostedZoneID != rInfo.HostedZoneID {
+        continue
+      }
+      for _, target := range ptrValues(ptr) {
+        if !InZone(target, zoneName) {
+          continue
+        }
+        issue := AuditIssue{Kind: AuditOrphanPtr, Name: target, IP: ip.String(), Fixable: len(ptr.ResourceRecords) == 1}
+        if addrs, ok := ipsByHost[strings.ToLower(target)]; ok {
+          issue.Kind = AuditStalePtr
+          issue.Detail = fmt.Sprintf("PTR record %s points at %s, which has %s", name, target, strings.Join(sortedSet(addrs), " "))
+        } else {
+          issue.Detail = fmt.Sprintf("PTR record %s points at %s, which has no address record", name, target)
+        }
+        issues = append(issues, issue)
+        if issue.Fixable {
+          reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName).AppendChange(route53.ChangeActionDelete, ptr, nil)
+        }
+      }
+    }
+  }
+
+  plan = &ChangePlan{}
+  reverse.addTo(plan)
+  return issues, plan
+}
+
+// WriteAuditIssues writes issues to w as text or json.
+func WriteAuditIssues(w io.Writer, issues []AuditIssue, format string) (err error) {
+  switch format {
+  case "", "text":
+    for _, issue := range issues {
+      _, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Kind, issue.Name, issue.IP, issue.Detail)
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  case "json":
+    if issues == nil {
+      issues = []AuditIssue{}
+    }
+    return WriteJSON(w, issues)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
+
+func ptrValues(rrset *route53.ResourceRecordSet) (values []string) {
+  for _, rr := range rrset.ResourceRecords {
+    values = append(values, aws.StringValue(rr.Value))
+  }
+  return values
+}
+
+func ptrPointsAtAny(ptr *route53.ResourceRecordSet, hosts []string) bool {
+  for _, host := range hosts {
+    if ptrPointsAt(ptr, host) {
+      return true
+    }
+  }
+  return false
+}
+
+func sortedSet(set map[string]bool) (keys []string) {
+  for key := range set {
+    keys = append(keys, key)
+  }
+  sortIPs(keys)
+  return keys
+}
+
+// sortIPs sorts textual addresses numerically, IPv4 before IPv6.
+func sortIPs(ips []string) {
+  sort.Slice(ips, func(i, j int) bool {
+    a, b := net.ParseIP(ips[i]), net.ParseIP(ips[j])
+    if a == nil || b == nil {
+      return ips[i] < ips[j]
+    }
+    if (a.To4() == nil) != (b.To4() == nil) {
+      return a.To4() != nil
+    }
+    return strings.Compare(string(a.To16()), string(b.To16())) < 0
+  })
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+  "net"
+  "strings"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestBuildAudit(t *testing.T) {
+  rInfo := newReverseHostedZoneInfo("10.0.1.0/24", "REV123", "1.0.10.in-addr.arpa.")
+  rInfos := ReverseHostedZoneInfos{ReverseHostedZoneInfo: []ReverseHostedZoneInfo{rInfo}}
+
+  forward := []*route53.ResourceRecordSet{
+    newAddressResourceRecordSet(net.ParseIP("10.0.1.1"), "ok.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("10.0.1.2"), "missing.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("10.0.1.3"), "wrong.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("10.0.1.4"), "moved.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("192.0.2.1"), "outside.example.com."),
+  }
+  records := map[string][]*route53.ResourceRecordSet{
+    "REV123": {
+      rInfo.ptrResourceRecordSet(net.ParseIP("10.0.1.1"), "ok.example.com."),
+      rInfo.ptrResourceRecordSet(net.ParseIP("10.0.1.3"), "other.example.com."),
+      rInfo.ptrResourceRecordSet(net.ParseIP("10.0.1.5"), "moved.example.com."),
+      rInfo.ptrResourceRecordSet(net.ParseIP("10.0.1.6"), "deleted.example.com."),
+      // PTR records of other zones are not audited
+      rInfo.ptrResourceRecordSet(net.ParseIP("10.0.1.7"), "www.example.org."),
+      {
+        Name: aws.String("1.0.10.in-addr.arpa."),
+        Type: aws.String(route53.RRTypeNs),
+        TTL: aws.Int64(172800),
+        ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("ns.example.com.")}},
+      },
+    },
+  }
+
+  issues, plan := buildAudit("example.com.", forward, records, rInfos)
+
+  expectedIssues := []string{
+    "missing-ptr missing.example.com. 10.0.1.2",
+    "wrong-ptr wrong.example.com. 10.0.1.3",
+    "missing-ptr moved.example.com. 10.0.1.4",
+    "no-reverse-zone outside.example.com. 192.0.2.1",
+    "stale-ptr moved.example.com. 10.0.1.5",
+    "orphan-ptr deleted.example.com. 10.0.1.6",
+  }
+  if len(issues) != len(expectedIssues) {
+    t.Fatalf("want %d issues, actual %v", len(expectedIssues), issues)
+  }
+  for idx, issue := range issues {
+    actual := strings.Join([]string{issue.Kind, issue.Name, issue.IP}, " ")
+    if actual != expectedIssues[idx] {
+      t.Errorf("pattern %d: want %s, actual %s", idx, expectedIssues[idx], actual)
+    }
+  }
+
+  var out strings.Builder
+  err := PrintChangePlan(&out, plan, "text")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  expected := strings.Join([]string{
+    "@@ step 1: 1.0.10.in-addr.arpa. (REV123) @@",
+    "~ 2.1.0.10.in-addr.arpa. 600 PTR missing.example.com.",
+    "~ 3.1.0.10.in-addr.arpa. 600 PTR wrong.example.com.",
+    "~ 4.1.0.10.in-addr.arpa. 600 PTR moved.example.com.",
+    "- 5.1.0.10.in-addr.arpa. 600 PTR moved.example.com.",
+    "- 6.1.0.10.in-addr.arpa. 600 PTR deleted.example.com.",
+    "",
+  }, "\n")
+  if out.String() != expected {
+    t.Errorf("unexpected plan: expected\n%s\nactual\n%s", expected, out.String())
+  }
+}
+
+func TestBuildAuditClassless(t *testing.T) {
+  rInfo := newReverseHostedZoneInfo("192.0.2.0/26", "CLS123", "0-63.2.0.192.in-addr.arpa.")
+  rInfo.ParentHostedZoneID = "REV123"
+  rInfo.ParentHostedZoneName = "2.0.192.in-addr.arpa."
+  rInfos := ReverseHostedZoneInfos{ReverseHostedZoneInfo: []ReverseHostedZoneInfo{rInfo}}
+
+  forward := []*route53.ResourceRecordSet{
+    newAddressResourceRecordSet(net.ParseIP("192.0.2.1"), "ok.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("192.0.2.2"), "missing.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("192.0.2.3"), "wrong.example.com."),
+  }
+  records := map[string][]*route53.ResourceRecordSet{
+    "CLS123": {
+      rInfo.ptrResourceRecordSet(net.ParseIP("192.0.2.1"), "ok.example.com."),
+      rInfo.ptrResourceRecordSet(net.ParseIP("192.0.2.2"), "missing.example.com."),
+      rInfo.ptrResourceRecordSet(net.ParseIP("192.0.2.3"), "wrong.example.com."),
+    },
+    "REV123": {
+      rInfo.parentCnameResourceRecordSet(net.ParseIP("192.0.2.1")),
+      {
+        Name: aws.String("3.2.0.192.in-addr.arpa."),
+        Type: aws.String(route53.RRTypeCname),
+        TTL: aws.Int64(600),
+        ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("3.64-127.2.0.192.in-addr.arpa.")}},
+      },
+    },
+  }
+
+  issues, plan := buildAudit("example.com.", forward, records, rInfos)
+
+  expectedIssues := []string{
+    "missing-cname missing.example.com. 192.0.2.2",
+    "wrong-cname wrong.example.com. 192.0.2.3",
+  }
+  if len(issues) != len(expectedIssues) {
+    t.Fatalf("want %d issues, actual %v", len(expectedIssues), issues)
+  }
+  for idx, issue := range issues {
+    actual := strings.Join([]string{issue.Kind, issue.Name, issue.IP}, " ")
+    if actual != expectedIssues[idx] {
+      t.Errorf("pattern %d: want %s, actual %s", idx, expectedIssues[idx], actual)
+    }
+  }
+
+  var out strings.Builder
+  err := PrintChangePlan(&out, plan, "text")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  expected := strings.Join([]string{
+    "@@ step 1: 2.0.192.in-addr.arpa. (REV123) @@",
+    "~ 2.2.0.192.in-addr.arpa. 600 CNAME 2.0-63.2.0.192.in-addr.arpa.",
+    "~ 3.2.0.192.in-addr.arpa. 600 CNAME 3.0-63.2.0.192.in-addr.arpa.",
+    "",
+  }, "\n")
+  if out.String() != expected {
+    t.Errorf("unexpected plan: expected\n%s\nactual\n%s", expected, out.String())
+  }
+}
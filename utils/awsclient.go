@@ -1,15 +1,20 @@
 package utils
 
 import (
+  "context"
 	"fmt"
+  "io"
+  "os"
   "strings"
   "regexp"
   "net"
+  "time"
 
 	"github.com/urfave/cli/v2"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
 )
@@ -17,14 +22,37 @@ import (
 // AWSClientImpl ...
 type AWSClientImpl struct {
   r53 Route53Client
+  // ctx is passed to every Route53 call. It is cancelled by --timeout and
+  // by SIGINT or SIGTERM.
+  ctx context.Context
+  // rollbackTimeout bounds the rollback after a failure, which runs even
+  // when ctx is cancelled. Zero means no limit.
+  rollbackTimeout time.Duration
+
+  dryRun bool
+  // noWait prints the change IDs of the applied steps instead of waiting
+  // until they are in sync.
+  noWait bool
+  planFormat string
+  out io.Writer
+
+  // journalDir is where ApplyChangePlan journals the plans, or empty to
+  // apply them without a journal.
+  journalDir string
+  command string
+  undoOf string
 }
 
 // Route53Client ...
 type Route53Client interface {
-  ListHostedZonesByName(input *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error)
-  ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error)
-  ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error)
-  WaitUntilResourceRecordSetsChanged(input *route53.GetChangeInput) error
+  ListHostedZonesByNameWithContext(ctx aws.Context, input *route53.ListHostedZonesByNameInput, opts ...request.Option) (*route53.ListHostedZonesByNameOutput, error)
+  ListResourceRecordSetsWithContext(ctx aws.Context, input *route53.ListResourceRecordSetsInput, opts ...request.Option) (*route53.ListResourceRecordSetsOutput, error)
+  ChangeResourceRecordSetsWithContext(ctx aws.Context, input *route53.ChangeResourceRecordSetsInput, opts ...request.Option) (*route53.ChangeResourceRecordSetsOutput, error)
+  GetChangeWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.Option) (*route53.GetChangeOutput, error)
+  WaitUntilResourceRecordSetsChangedWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.WaiterOption) error
+  CreateHostedZoneWithContext(ctx aws.Context, input *route53.CreateHostedZoneInput, opts ...request.Option) (*route53.CreateHostedZoneOutput, error)
+  GetHostedZoneWithContext(ctx aws.Context, input *route53.GetHostedZoneInput, opts ...request.Option) (*route53.GetHostedZoneOutput, error)
+  DeleteHostedZoneWithContext(ctx aws.Context, input *route53.DeleteHostedZoneInput, opts ...request.Option) (*route53.DeleteHostedZoneOutput, error)
 }
 
 // ReverseHostedZoneInfos ...
@@ -38,22 +66,131 @@ type ReverseHostedZoneInfo struct {
   NetworkCIDR string
   HostedZoneID string
   HostedZoneName string
+  // ParentHostedZoneID and ParentHostedZoneName are set for a classless
+  // zone whose CNAMEs in the parent zone are maintained with the PTR
+  // records.
+  ParentHostedZoneID string
+  ParentHostedZoneName string
 }
 
-// NewAWSClient ...
-func NewAWSClient(c *cli.Context) (*AWSClientImpl, error) {
+// NewRoute53Client creates the Route53 client used by NewAWSClient. Tests
+// and other tooling replace it to run the commands against another backend,
+// such as the in-memory one of package fakeroute53.
+var NewRoute53Client = func(c *cli.Context) (Route53Client, error) {
   profileName := c.String("profile")
-  config := aws.NewConfig()
+  config, err := awsConfigFromContext(c)
+  if err != nil {
+    return nil, err
+  }
+  // the calls are retried by RetryingRoute53Client, which knows about the
+  // rate limit
+  config = config.WithMaxRetries(0)
   sessOpts := session.Options{
     Config: *config,
     Profile: profileName,
     AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
     SharedConfigState: session.SharedConfigEnable,
   }
-  sess := session.Must(session.NewSessionWithOptions(sessOpts))
+  sess, err := session.NewSessionWithOptions(sessOpts)
+  if err != nil {
+    return nil, err
+  }
+  return route53.New(sess), nil
+}
+
+// awsConfigFromContext builds the aws.Config from the global --endpoint-url
+// and --region flags, falling back on the config file. They are read from the
+// root context, since the routing policy flags of add and update shadow
+// --region.
+func awsConfigFromContext(c *cli.Context) (config *aws.Config, err error) {
+  root := rootContext(c)
+
+  var confToml ConfToml
+  if confPath := root.String("conf"); len(confPath) > 0 {
+    err = LoadConf(confPath, &confToml)
+    if err != nil {
+      return nil, err
+    }
+  }
+  return NewAWSConfig(root.String("endpoint-url"), root.String("region"), confToml), nil
+}
+
+// rootContext returns the context of the application, which holds the
+// global flags.
+func rootContext(c *cli.Context) *cli.Context {
+  root := c
+  for _, ctx := range c.Lineage() {
+    if ctx.App != nil {
+      root = ctx
+    }
+  }
+  return root
+}
+
+// NewAWSConfig returns the aws.Config which sends the requests to
+// endpointURL in region. Empty values are taken from confToml, and left to
+// the SDK defaults when it has none either.
+func NewAWSConfig(endpointURL string, region string, confToml ConfToml) *aws.Config {
+  config := aws.NewConfig()
+  if len(endpointURL) == 0 {
+    endpointURL = confToml.EndpointURL
+  }
+  if len(endpointURL) > 0 {
+    config = config.WithEndpoint(endpointURL)
+  }
+  if len(region) == 0 {
+    region = confToml.Region
+  }
+  if len(region) > 0 {
+    config = config.WithRegion(region)
+  }
+  return config
+}
+
+// NewAWSClient ...
+func NewAWSClient(c *cli.Context) (*AWSClientImpl, error) {
+  r53, err := NewRoute53Client(c)
+  if err != nil {
+    return nil, err
+  }
+  client := NewAWSClientWithRoute53(NewRetryingRoute53Client(r53, RetryOptionsFromContext(c)))
+  client.ctx = c.Context
+  client.rollbackTimeout = rootContext(c).Duration("timeout")
+  client.dryRun = c.Bool("dry-run")
+  client.noWait = c.Bool("no-wait")
+  client.planFormat = c.String("plan-format")
+  if c.App != nil && c.App.Writer != nil {
+    client.out = c.App.Writer
+  }
+  client.journalDir = JournalDirFromContext(c)
+  if c.Command != nil {
+    client.command = c.Command.FullName()
+  }
+  return client, nil
+}
+
+// JournalDirFromContext returns the global --journal-dir, or the default
+// journal directory. --no-journal disables the journal.
+func JournalDirFromContext(c *cli.Context) string {
+  root := rootContext(c)
+  if root.Bool("no-journal") {
+    return ""
+  }
+  if dir := root.String("journal-dir"); len(dir) > 0 {
+    return dir
+  }
+  return DefaultJournalDir()
+}
+
+// NewAWSClientWithRoute53 returns a client which talks to r53 and applies
+// the changes it plans.
+func NewAWSClientWithRoute53(r53 Route53Client) *AWSClientImpl {
   return &AWSClientImpl{
-    r53: route53.New(sess),
-  }, nil
+    r53: r53,
+    ctx: context.Background(),
+    planFormat: "text",
+    out: os.Stdout,
+  }
 }
 
 // GetHostedZoneID ...
@@ -64,16 +201,19 @@ func (client *AWSClientImpl) GetHostedZoneID(hostedZoneName string) (hostedZoneI
   }
 
   var resp *route53.ListHostedZonesByNameOutput
-  resp, err = client.r53.ListHostedZonesByName(&input)
+  resp, err = client.r53.ListHostedZonesByNameWithContext(client.ctx, &input)
 
   if err != nil {
     return "", fmt.Errorf("HostedZone not found: %s", hostedZoneName)
   }
 
+  if len(resp.HostedZones) == 0 {
+    return "", &HostedZoneNotFoundError{Name: hostedZoneName}
+  }
   hostedZone := *resp.HostedZones[0]
   rHostedZoneName := aws.StringValue(hostedZone.Name)
   if compareHostedZoneName(hostedZoneName, rHostedZoneName) != true {
-    return "", fmt.Errorf("unexpected HostedZone Name: expected %s, actual %s", hostedZoneName, rHostedZoneName)
+    return "", &HostedZoneNotFoundError{Name: hostedZoneName, Next: rHostedZoneName}
   }
 
   hostedZoneIDParts := strings.Split(aws.StringValue(hostedZone.Id), "/")
@@ -90,7 +230,7 @@ func (client *AWSClientImpl) ListAllResourceRecords(hostedZoneID string) (rrsets
 
   for {
     var resp *route53.ListResourceRecordSetsOutput
-    resp, err = client.r53.ListResourceRecordSets(&input)
+    resp, err = client.r53.ListResourceRecordSetsWithContext(client.ctx, &input)
     if err != nil {
       return rrsets, err
     }
@@ -133,106 +273,182 @@ func (client *AWSClientImpl) CreateReverseHostedZoneInfo(networkCIDR string, zon
   return rInfo, nil
 }
 
+// LoadReverseHostedZoneInfos resolves the hosted zone IDs of the reverse
+// hosted zones listed in confToml.
+func (client *AWSClientImpl) LoadReverseHostedZoneInfos(confToml ConfToml) (rInfos ReverseHostedZoneInfos, err error) {
+  for _, p := range confToml.ReverseHostedZones {
+    rInfo, err := client.CreateReverseHostedZoneInfo(p.NetworkCIDR, p.ZoneName)
+    if err != nil {
+      return rInfos, err
+    }
+    err = rInfo.checkClassless()
+    if err != nil {
+      return rInfos, err
+    }
+    if len(p.ParentZoneName) > 0 {
+      if !rInfo.IsClassless() {
+        return rInfos, fmt.Errorf("ParentZoneName is only for classless reverse zones: %s", p.ZoneName)
+      }
+      rInfo.ParentHostedZoneName = p.ParentZoneName
+      rInfo.ParentHostedZoneID, err = client.GetHostedZoneID(p.ParentZoneName)
+      if err != nil {
+        return rInfos, err
+      }
+    }
+    rInfos.ReverseHostedZoneInfo = append(rInfos.ReverseHostedZoneInfo, rInfo)
+  }
+  return rInfos, nil
+}
+
 // GetReverseHostedZoneID ...
 func GetReverseHostedZoneID(ip net.IP, rInfos ReverseHostedZoneInfos) (hostedZoneID string, err error) {
-  for _, zoneInfo := range rInfos.ReverseHostedZoneInfo {
-    ipnet := zoneInfo.Network
-    if ipnet.Contains(ip) {
-      return zoneInfo.HostedZoneID, nil
+  rInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
+  if err != nil {
+    return hostedZoneID, err
+  }
+  return rInfo.HostedZoneID, nil
+}
+
+// GetReverseHostedZoneInfo returns the reverse hosted zone with the longest
+// network prefix which covers ip.
+func GetReverseHostedZoneInfo(ip net.IP, rInfos ReverseHostedZoneInfos) (rInfo ReverseHostedZoneInfo, err error) {
+  matches := longestPrefixMatch(ip, rInfos)
+  if len(matches) == 0 {
+    return rInfo, fmt.Errorf("not found (%s)", ip.String())
+  }
+  for _, match := range matches[1:] {
+    if match.HostedZoneID != matches[0].HostedZoneID {
+      return rInfo, fmt.Errorf("multiple reverse hosted zones for %s: %s", ip.String(), reverseHostedZoneNames(matches))
     }
   }
-  return hostedZoneID, fmt.Errorf("not found (%s)", ip.String())
+  return matches[0], nil
 }
 
 // AddAResourceRecordSet ...
 func (client *AWSClientImpl) AddAResourceRecordSet(ip net.IP, hostname string, hostedZoneID string, rInfos ReverseHostedZoneInfos) (err error) {
-  err = client.createAResourceRecordSet(ip, hostname, hostedZoneID)
+  plan, err := client.PlanAddAResourceRecordSet(ip, hostname, hostedZoneID, rInfos, RoutingPolicy{})
   if err != nil {
     return err
   }
+  return client.ApplyChangePlan(plan)
+}
 
-  err = client.createPtrResourceRecordSet(ip, hostname, rInfos)
+// PlanAddAResourceRecordSet builds the plan which creates the A (or AAAA)
+// record with the routing policy in the hosted zone and the PTR record in
+// the reverse hosted zone.
+func (client *AWSClientImpl) PlanAddAResourceRecordSet(ip net.IP, hostname string, hostedZoneID string, rInfos ReverseHostedZoneInfos, policy RoutingPolicy) (plan *ChangePlan, err error) {
+  rInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
   if err != nil {
-    rr, e := client.GetResourceRecordSetByName(ip.String(), hostedZoneID)
-    if e != nil {
-      return e
-    }
-    rolebackErr := client.deleteAResourceRecordSet(&rr, hostedZoneID)
-    if rolebackErr != nil {
-      return rolebackErr
-    }
-    return err
+    return nil, err
+  }
+
+  rrset := newAddressResourceRecordSet(ip, hostname)
+  policy.Apply(rrset)
+
+  reverse := &changeSteps{}
+  ptrStep := reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName)
+  ptrStep.Changes = append(ptrStep.Changes, newChange(route53.ChangeActionCreate, rInfo.ptrResourceRecordSet(ip, hostname)))
+  err = client.planParentCname(reverse, rInfo, ip)
+  if err != nil {
+    return nil, err
   }
 
-  return nil
+  plan = &ChangePlan{}
+  plan.AddStep(hostedZoneID, "", newChange(route53.ChangeActionCreate, rrset))
+  reverse.addTo(plan)
+  return plan, nil
 }
 
 // RemoveAResourceRecordSet ...
-func (client *AWSClientImpl) RemoveAResourceRecordSet(rrset *route53.ResourceRecordSet, ip net.IP, hostname string, hostedZoneID string, rInfos ReverseHostedZoneInfos) (err error) {
-  err = client.deleteAResourceRecordSet(rrset, hostedZoneID)
+func (client *AWSClientImpl) RemoveAResourceRecordSet(rrset *route53.ResourceRecordSet, hostedZoneID string, rInfos ReverseHostedZoneInfos) (err error) {
+  plan, err := client.PlanRemoveAResourceRecordSet(rrset, hostedZoneID, rInfos)
   if err != nil {
     return err
   }
+  return client.ApplyChangePlan(plan)
+}
 
-  err = client.deletePtrResourceRecordSet(ip, rInfos)
-  if err != nil {
-    rolebackErr := client.createAResourceRecordSet(ip, hostname, hostedZoneID)
-    if rolebackErr != nil {
-      return rolebackErr
+// PlanRemoveAResourceRecordSet builds the plan which deletes rrset from the
+// hosted zone and the PTR records of each of its addresses from the reverse
+// hosted zones.
+func (client *AWSClientImpl) PlanRemoveAResourceRecordSet(rrset *route53.ResourceRecordSet, hostedZoneID string, rInfos ReverseHostedZoneInfos) (plan *ChangePlan, err error) {
+  reverse := &changeSteps{}
+  for _, rr := range rrset.ResourceRecords {
+    ip := net.ParseIP(aws.StringValue(rr.Value))
+    if ip == nil {
+      return nil, fmt.Errorf("%s: invalid ip: %s", aws.StringValue(rrset.Name), aws.StringValue(rr.Value))
+    }
+    rInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
+    if err != nil {
+      return nil, err
+    }
+    ptr, err := client.FindResourceRecordSet(rInfo.PtrRecordName(ip), route53.RRTypePtr, "", rInfo.HostedZoneID)
+    if err != nil {
+      return nil, err
+    }
+    reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName).AppendChange(route53.ChangeActionDelete, ptr, nil)
+    err = client.planRemoveParentCname(reverse, rInfo, ip)
+    if err != nil {
+      return nil, err
     }
-    return err
   }
 
-  return nil
+  plan = &ChangePlan{}
+  plan.AddStep(hostedZoneID, "", newChange(route53.ChangeActionDelete, rrset))
+  reverse.addTo(plan)
+  return plan, nil
+}
+
+// AddResourceRecordSet creates rrset in the hosted zone. It is used for the
+// record types which have no reverse record to maintain.
+func (client *AWSClientImpl) AddResourceRecordSet(rrset *route53.ResourceRecordSet, hostedZoneID string) (err error) {
+  plan := &ChangePlan{}
+  plan.AddStep(hostedZoneID, "", newChange(route53.ChangeActionCreate, rrset))
+  return client.ApplyChangePlan(plan)
+}
+
+// NewAliasResourceRecordSet builds an alias record set pointing at
+// dnsName in the hosted zone aliasZoneID.
+func NewAliasResourceRecordSet(name string, rrType string, dnsName string, aliasZoneID string, evaluateTargetHealth bool) *route53.ResourceRecordSet {
+  return &route53.ResourceRecordSet{
+    Name: aws.String(name),
+    Type: aws.String(rrType),
+    AliasTarget: &route53.AliasTarget{
+      DNSName: aws.String(dnsName),
+      HostedZoneId: aws.String(aliasZoneID),
+      EvaluateTargetHealth: aws.Bool(evaluateTargetHealth),
+    },
+  }
+}
+
+// RemoveResourceRecordSet deletes rrset from the hosted zone.
+func (client *AWSClientImpl) RemoveResourceRecordSet(rrset *route53.ResourceRecordSet, hostedZoneID string) (err error) {
+  plan := &ChangePlan{}
+  plan.AddStep(hostedZoneID, "", newChange(route53.ChangeActionDelete, rrset))
+  return client.ApplyChangePlan(plan)
 }
 
 // AddCnameResourceRecordSet ...
 func (client *AWSClientImpl) AddCnameResourceRecordSet(hostname string, cnameHostname string, hostedZoneID string) (err error) {
-  input := &route53.ChangeResourceRecordSetsInput{
-    HostedZoneId: aws.String(hostedZoneID),
-    ChangeBatch: &route53.ChangeBatch{
-      Changes: []*route53.Change{
-        {
-          Action: aws.String(route53.ChangeActionCreate),
-          ResourceRecordSet: &route53.ResourceRecordSet{
-            Name: aws.String(hostname),
-            ResourceRecords: []*route53.ResourceRecord{
-              {
-                Value: aws.String(cnameHostname),
-              },
-            },
-            TTL:  aws.Int64(600),
-            Type: aws.String(route53.RRTypeCname),
-          },
-        },
+  plan := &ChangePlan{}
+  plan.AddStep(hostedZoneID, "", newChange(route53.ChangeActionCreate, &route53.ResourceRecordSet{
+    Name: aws.String(hostname),
+    ResourceRecords: []*route53.ResourceRecord{
+      {
+        Value: aws.String(cnameHostname),
       },
     },
-  }
-  resp, err := client.r53.ChangeResourceRecordSets(input)
-  if err != nil {
-    return err
-  }
-  err = client.r53.WaitUntilResourceRecordSetsChanged(&route53.GetChangeInput{Id: resp.ChangeInfo.Id})
-  if err != nil {
-    return err
-  }
-  return nil
+    TTL:  aws.Int64(600),
+    Type: aws.String(route53.RRTypeCname),
+  }))
+  return client.ApplyChangePlan(plan)
 }
 
 // RemoveCnameResourceRecordSet ...
 func (client *AWSClientImpl) RemoveCnameResourceRecordSet(rrset *route53.ResourceRecordSet, hostedZoneID string) (err error) {
-  input := &route53.ChangeResourceRecordSetsInput{
-    HostedZoneId: aws.String(hostedZoneID),
-    ChangeBatch: &route53.ChangeBatch{
-      Changes: []*route53.Change{
-        {
-          Action: aws.String(route53.ChangeActionDelete),
-          ResourceRecordSet: rrset,
-        },
-      },
-    },
-  }
-  return client.changeAndWaitResourceRecordSet(input)
+  plan := &ChangePlan{}
+  plan.AddStep(hostedZoneID, "", newChange(route53.ChangeActionDelete, rrset))
+  return client.ApplyChangePlan(plan)
 }
 
 func (client *AWSClientImpl) createAResourceRecordSet(ip net.IP, hostname string, hostedZoneID string) (err error) {
@@ -242,16 +458,7 @@ func (client *AWSClientImpl) createAResourceRecordSet(ip net.IP, hostname string
       Changes: []*route53.Change{
         {
           Action: aws.String(route53.ChangeActionCreate),
-          ResourceRecordSet: &route53.ResourceRecordSet{
-            Name: aws.String(hostname),
-            ResourceRecords: []*route53.ResourceRecord{
-              {
-                Value: aws.String(ip.String()),
-              },
-            },
-            TTL:  aws.Int64(600),
-            Type: aws.String(route53.RRTypeA),
-          },
+          ResourceRecordSet: newAddressResourceRecordSet(ip, hostname),
         },
       },
     },
@@ -259,16 +466,43 @@ func (client *AWSClientImpl) createAResourceRecordSet(ip net.IP, hostname string
   return client.changeAndWaitResourceRecordSet(inputForA)
 }
 
+// newAddressResourceRecordSet builds an A record set for IPv4 addresses and
+// an AAAA record set for IPv6 addresses.
+func newAddressResourceRecordSet(ip net.IP, hostname string) *route53.ResourceRecordSet {
+  return &route53.ResourceRecordSet{
+    Name: aws.String(hostname),
+    ResourceRecords: []*route53.ResourceRecord{
+      {
+        Value: aws.String(ip.String()),
+      },
+    },
+    TTL:  aws.Int64(600),
+    Type: aws.String(AddressRecordType(ip)),
+  }
+}
+
 func (client *AWSClientImpl) changeAndWaitResourceRecordSet(input *route53.ChangeResourceRecordSetsInput) (err error) {
-  resp, err := client.r53.ChangeResourceRecordSets(input)
+  _, err = client.changeResourceRecordSet(input)
+  return err
+}
+
+// changeResourceRecordSet submits the change batch, waits until it is in
+// sync, or prints its change ID with --no-wait, and returns its change ID.
+func (client *AWSClientImpl) changeResourceRecordSet(input *route53.ChangeResourceRecordSetsInput) (changeID string, err error) {
+  resp, err := client.r53.ChangeResourceRecordSetsWithContext(client.ctx, input)
   if err != nil {
-    return err
+    return "", err
   }
-  err = client.r53.WaitUntilResourceRecordSetsChanged(&route53.GetChangeInput{Id: resp.ChangeInfo.Id})
+  changeID = aws.StringValue(resp.ChangeInfo.Id)
+  if client.noWait {
+    _, err = fmt.Fprintln(client.out, changeID)
+    return changeID, err
+  }
+  err = client.r53.WaitUntilResourceRecordSetsChangedWithContext(client.ctx, &route53.GetChangeInput{Id: resp.ChangeInfo.Id})
   if err != nil {
-    return err
+    return changeID, err
   }
-  return nil
+  return changeID, nil
 }
 
 func (client *AWSClientImpl) deleteResourceRecordSet(rrset *route53.ResourceRecordSet, hostedZoneName string) (err error) {
@@ -295,36 +529,28 @@ func (client *AWSClientImpl) createPtrResourceRecordSet(ip net.IP, hostname stri
   if err != nil {
     return err
   }
-  ptrRecord := GenerateReverseRecord(ip)
   inputForPTR := &route53.ChangeResourceRecordSetsInput{
     HostedZoneId: aws.String(reverseHostedZoneID),
     ChangeBatch: &route53.ChangeBatch{
       Changes: []*route53.Change{
-        {
-          Action: aws.String(route53.ChangeActionCreate),
-          ResourceRecordSet: &route53.ResourceRecordSet{
-            Name: aws.String(ptrRecord),
-            ResourceRecords: []*route53.ResourceRecord{
-              {
-                Value: aws.String(hostname),
-              },
-            },
-            TTL: aws.Int64(600),
-            Type: aws.String(route53.RRTypePtr),
-          },
-        },
+        newChange(route53.ChangeActionCreate, newPtrResourceRecordSet(ip, hostname)),
       },
     },
   }
-  resp, err := client.r53.ChangeResourceRecordSets(inputForPTR)
-  if err != nil {
-    return err
-  }
-  err = client.r53.WaitUntilResourceRecordSetsChanged(&route53.GetChangeInput{Id: resp.ChangeInfo.Id})
-  if err != nil {
-    return err
+  return client.changeAndWaitResourceRecordSet(inputForPTR)
+}
+
+func newPtrResourceRecordSet(ip net.IP, hostname string) *route53.ResourceRecordSet {
+  return &route53.ResourceRecordSet{
+    Name: aws.String(GenerateReverseRecord(ip)),
+    ResourceRecords: []*route53.ResourceRecord{
+      {
+        Value: aws.String(hostname),
+      },
+    },
+    TTL: aws.Int64(600),
+    Type: aws.String(route53.RRTypePtr),
   }
-  return nil
 }
 
 func (client *AWSClientImpl) deleteAResourceRecordSet(rrset *route53.ResourceRecordSet, hostedZoneID string) (err error) {
@@ -348,7 +574,7 @@ func (client *AWSClientImpl) deletePtrResourceRecordSet(ip net.IP, rInfos Revers
     return err
   }
   ptrRecord := GenerateReverseRecord(ip)
-  rr, err := client.GetResourceRecordSetByName(ptrRecord, reverseHostedZoneID)
+  rr, err := client.FindResourceRecordSet(ptrRecord, route53.RRTypePtr, "", reverseHostedZoneID)
   if err != nil {
     return err
   }
@@ -358,30 +584,10 @@ func (client *AWSClientImpl) deletePtrResourceRecordSet(ip net.IP, rInfos Revers
       Changes: []*route53.Change{
         {
           Action: aws.String(route53.ChangeActionDelete),
-          ResourceRecordSet: &rr,
+          ResourceRecordSet: rr,
         },
       },
     },
   }
   return client.changeAndWaitResourceRecordSet(input)
 }
-
-// GetResourceRecordSetByName ...
-func (client *AWSClientImpl) GetResourceRecordSetByName(hostname string, hostedZoneID string) (rr route53.ResourceRecordSet, err error) {
-  input := &route53.ListResourceRecordSetsInput{
-    HostedZoneId: aws.String(hostedZoneID),
-    MaxItems: aws.String("1"),
-    StartRecordName: aws.String(hostname),
-  }
-  resp, err := client.r53.ListResourceRecordSets(input)
-  if err != nil {
-    return rr, err
-  }
-  if *resp.IsTruncated {
-    return rr, fmt.Errorf("unexpected response: response is truncated")
-  }
-  if hostname != *resp.ResourceRecordSets[0].Name {
-    return rr, fmt.Errorf("hostname mismatch: input %s, response %s", hostname, *resp.ResourceRecordSets[0].Name)
-  }
-  return *resp.ResourceRecordSets[0], nil
-}
@@ -7,8 +7,11 @@ import (
   "net"
   "time"
 
+	"github.com/urfave/cli/v2"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/route53"
 )
 
@@ -28,11 +31,25 @@ type DummyRoute53Client struct {
   changeResourceRecordSetsError error
 
   getChangeInput *route53.GetChangeInput
+  getChangeOutput *route53.GetChangeOutput
+  getChangeError error
 
   waitUntilResourceRecordSetsChangedError error
+
+  createHostedZoneInput *route53.CreateHostedZoneInput
+  createHostedZoneOutput *route53.CreateHostedZoneOutput
+  createHostedZoneError error
+
+  getHostedZoneInput *route53.GetHostedZoneInput
+  getHostedZoneOutput *route53.GetHostedZoneOutput
+  getHostedZoneError error
+
+  deleteHostedZoneInput *route53.DeleteHostedZoneInput
+  deleteHostedZoneOutput *route53.DeleteHostedZoneOutput
+  deleteHostedZoneError error
 }
 
-func (c DummyRoute53Client) ListHostedZonesByName(input *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+func (c DummyRoute53Client) ListHostedZonesByNameWithContext(ctx aws.Context, input *route53.ListHostedZonesByNameInput, opts ...request.Option) (*route53.ListHostedZonesByNameOutput, error) {
   expectedInput := awsutil.StringValue(c.listHostedZonesByNameInput)
   actualInput := awsutil.StringValue(input)
   if expectedInput != actualInput {
@@ -42,7 +59,7 @@ func (c DummyRoute53Client) ListHostedZonesByName(input *route53.ListHostedZones
   return c.listHostedZonesByNameOutput, c.listHostedZonesByNameError
 }
 
-func (c DummyRoute53Client) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+func (c DummyRoute53Client) ListResourceRecordSetsWithContext(ctx aws.Context, input *route53.ListResourceRecordSetsInput, opts ...request.Option) (*route53.ListResourceRecordSetsOutput, error) {
   expectedInput := awsutil.StringValue(c.listResourceRecordSetsInput)
   actualInput := awsutil.StringValue(input)
   if expectedInput != actualInput {
@@ -56,7 +73,7 @@ func (c DummyRoute53Client) ListResourceRecordSets(input *route53.ListResourceRe
   return c.listResourceRecordSetsOutput, c.listHostedZonesByNameError
 }
 
-func (c DummyRoute53Client) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+func (c DummyRoute53Client) ChangeResourceRecordSetsWithContext(ctx aws.Context, input *route53.ChangeResourceRecordSetsInput, opts ...request.Option) (*route53.ChangeResourceRecordSetsOutput, error) {
   expectedInput := awsutil.StringValue(c.changeResourceRecordSetsInput)
   actualInput := awsutil.StringValue(input)
   if expectedInput != actualInput {
@@ -70,7 +87,17 @@ func (c DummyRoute53Client) ChangeResourceRecordSets(input *route53.ChangeResour
   return c.changeResourceRecordSetsOutput, c.changeResourceRecordSetsError
 }
 
-func (c DummyRoute53Client) WaitUntilResourceRecordSetsChanged(input *route53.GetChangeInput) error {
+func (c DummyRoute53Client) GetChangeWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.Option) (*route53.GetChangeOutput, error) {
+  expectedInput := awsutil.StringValue(c.getChangeInput)
+  actualInput := awsutil.StringValue(input)
+  if expectedInput != actualInput {
+    c.t.Errorf("unexpected input: expected %v, actual %v", expectedInput, actualInput)
+  }
+
+  return c.getChangeOutput, c.getChangeError
+}
+
+func (c DummyRoute53Client) WaitUntilResourceRecordSetsChangedWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.WaiterOption) error {
   expectedInput := awsutil.StringValue(c.getChangeInput)
   actualInput := awsutil.StringValue(input)
   if expectedInput != actualInput {
@@ -84,6 +111,36 @@ func (c DummyRoute53Client) WaitUntilResourceRecordSetsChanged(input *route53.Ge
   return c.waitUntilResourceRecordSetsChangedError
 }
 
+func (c DummyRoute53Client) CreateHostedZoneWithContext(ctx aws.Context, input *route53.CreateHostedZoneInput, opts ...request.Option) (*route53.CreateHostedZoneOutput, error) {
+  expectedInput := awsutil.StringValue(c.createHostedZoneInput)
+  actualInput := awsutil.StringValue(input)
+  if expectedInput != actualInput {
+    c.t.Errorf("unexpected input: expected %v, actual %v", expectedInput, actualInput)
+  }
+
+  return c.createHostedZoneOutput, c.createHostedZoneError
+}
+
+func (c DummyRoute53Client) GetHostedZoneWithContext(ctx aws.Context, input *route53.GetHostedZoneInput, opts ...request.Option) (*route53.GetHostedZoneOutput, error) {
+  expectedInput := awsutil.StringValue(c.getHostedZoneInput)
+  actualInput := awsutil.StringValue(input)
+  if expectedInput != actualInput {
+    c.t.Errorf("unexpected input: expected %v, actual %v", expectedInput, actualInput)
+  }
+
+  return c.getHostedZoneOutput, c.getHostedZoneError
+}
+
+func (c DummyRoute53Client) DeleteHostedZoneWithContext(ctx aws.Context, input *route53.DeleteHostedZoneInput, opts ...request.Option) (*route53.DeleteHostedZoneOutput, error) {
+  expectedInput := awsutil.StringValue(c.deleteHostedZoneInput)
+  actualInput := awsutil.StringValue(input)
+  if expectedInput != actualInput {
+    c.t.Errorf("unexpected input: expected %v, actual %v", expectedInput, actualInput)
+  }
+
+  return c.deleteHostedZoneOutput, c.deleteHostedZoneError
+}
+
 func TestGetHostedZoneID(t *testing.T) {
   patterns := []struct {
     hostedZoneName string
@@ -212,6 +269,15 @@ func TestGetReverseHostedZoneID(t *testing.T) {
         HostedZoneID: "EFG456",
         HostedZoneName: "168.192.in-addr.arpa.",
       },
+      {
+        Network: &net.IPNet{
+          IP: net.ParseIP("2001:db8::"),
+          Mask: net.CIDRMask(32, 128),
+        },
+        NetworkCIDR: "2001:db8::/32",
+        HostedZoneID: "HIJ789",
+        HostedZoneName: "8.b.d.0.1.0.0.2.ip6.arpa.",
+      },
     },
   }
 
@@ -246,6 +312,16 @@ func TestGetReverseHostedZoneID(t *testing.T) {
       expectedZoneID: "",
       expectedError: errors.New("not found (172.21.4.15)"),
     },
+    {
+      ip: net.ParseIP("2001:db8:1::15"),
+      expectedZoneID: "HIJ789",
+      expectedError: nil,
+    },
+    {
+      ip: net.ParseIP("2001:db9::15"),
+      expectedZoneID: "",
+      expectedError: errors.New("not found (2001:db9::15)"),
+    },
   }
 
   for idx, p := range patterns {
@@ -263,6 +339,7 @@ func TestCreateAResourceRecordSet(t *testing.T) {
     ip net.IP
     hostname string
     hostedZoneID string
+    rrType string
 
     expectedError error
   }{
@@ -270,6 +347,14 @@ func TestCreateAResourceRecordSet(t *testing.T) {
       ip: net.ParseIP("10.0.5.10"),
       hostname: "host.example.com",
       hostedZoneID: "ABC123",
+      rrType: route53.RRTypeA,
+      expectedError: nil,
+    },
+    {
+      ip: net.ParseIP("2001:db8::10"),
+      hostname: "host.example.com",
+      hostedZoneID: "ABC123",
+      rrType: route53.RRTypeAaaa,
       expectedError: nil,
     },
   }
@@ -293,7 +378,7 @@ func TestCreateAResourceRecordSet(t *testing.T) {
                     },
                   },
                   TTL:  aws.Int64(600),
-                  Type: aws.String(route53.RRTypeA),
+                  Type: aws.String(p.rrType),
                 },
               },
             },
@@ -530,12 +615,11 @@ func TestDeletePtrResourceRecordSet(t *testing.T) {
 
         listResourceRecordSetsInput: &route53.ListResourceRecordSetsInput{
           HostedZoneId: aws.String(p.reverseHostedZoneID),
-          MaxItems: aws.String("1"),
           StartRecordName: aws.String(p.ptrHostname),
+          StartRecordType: aws.String(route53.RRTypePtr),
         },
         listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
           IsTruncated: aws.Bool(false),
-          MaxItems: aws.String("1"),
           ResourceRecordSets: []*route53.ResourceRecordSet{
             {
               Name: aws.String(p.ptrHostname),
@@ -717,178 +801,6 @@ func TestRemoveCnameResourceRecordSet(t *testing.T) {
   }
 }
 
-func TestGetResourceRecordSetByName(t *testing.T) {
-  patterns := []struct{
-    hostname string
-    hostedZoneID string
-
-    expectedRR route53.ResourceRecordSet
-    expectedError error
-
-    listResourceRecordSetsInput *route53.ListResourceRecordSetsInput
-    listResourceRecordSetsOutput *route53.ListResourceRecordSetsOutput
-    listResourceRecordSetsError error
-  }{
-    {
-      hostname: "www.example.com.",
-      hostedZoneID: "ABC123",
-
-      expectedRR: route53.ResourceRecordSet{
-        Name: aws.String("www.example.com."),
-        ResourceRecords: []*route53.ResourceRecord{
-          {
-            Value: aws.String("10.0.1.15"),
-          },
-        },
-        TTL: aws.Int64(600),
-        Type: aws.String(route53.RRTypeA),
-      },
-      expectedError: nil,
-
-      listResourceRecordSetsInput: &route53.ListResourceRecordSetsInput{
-        HostedZoneId: aws.String("ABC123"),
-        MaxItems: aws.String("1"),
-        StartRecordName: aws.String("www.example.com."),
-      },
-      listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-        IsTruncated: aws.Bool(false),
-        MaxItems: aws.String("1"),
-        ResourceRecordSets: []*route53.ResourceRecordSet{
-          {
-            Name: aws.String("www.example.com."),
-            ResourceRecords: []*route53.ResourceRecord{
-              {
-                Value: aws.String("10.0.1.15"),
-              },
-            },
-            TTL: aws.Int64(600),
-            Type: aws.String(route53.RRTypeA),
-          },
-        },
-      },
-      listResourceRecordSetsError: nil,
-    },
-    {
-      hostname: "www.example.com.",
-      hostedZoneID: "ABC123",
-
-      expectedRR: route53.ResourceRecordSet{
-        Name: aws.String("www.example.com."),
-        ResourceRecords: []*route53.ResourceRecord{
-          {
-            Value: aws.String("cname.example.com."),
-          },
-        },
-        TTL: aws.Int64(600),
-        Type: aws.String(route53.RRTypeCname),
-      },
-      expectedError: nil,
-
-      listResourceRecordSetsInput: &route53.ListResourceRecordSetsInput{
-        HostedZoneId: aws.String("ABC123"),
-        MaxItems: aws.String("1"),
-        StartRecordName: aws.String("www.example.com."),
-      },
-      listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-        IsTruncated: aws.Bool(false),
-        MaxItems: aws.String("1"),
-        ResourceRecordSets: []*route53.ResourceRecordSet{
-          {
-            Name: aws.String("www.example.com."),
-            ResourceRecords: []*route53.ResourceRecord{
-              {
-                Value: aws.String("cname.example.com."),
-              },
-            },
-            TTL: aws.Int64(600),
-            Type: aws.String(route53.RRTypeCname),
-          },
-        },
-      },
-      listResourceRecordSetsError: nil,
-    },
-    {
-      hostname: "www.example.com.",
-      hostedZoneID: "ABC123",
-
-      expectedRR: route53.ResourceRecordSet{},
-      expectedError: errors.New("unexpected response: response is truncated"),
-
-      listResourceRecordSetsInput: &route53.ListResourceRecordSetsInput{
-        HostedZoneId: aws.String("ABC123"),
-        MaxItems: aws.String("1"),
-        StartRecordName: aws.String("www.example.com."),
-      },
-      listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-        IsTruncated: aws.Bool(true),
-        MaxItems: aws.String("1"),
-        ResourceRecordSets: []*route53.ResourceRecordSet{
-          {
-            Name: aws.String("www.example.com."),
-            ResourceRecords: []*route53.ResourceRecord{
-              {
-                Value: aws.String("cname.example.com."),
-              },
-            },
-            TTL: aws.Int64(600),
-            Type: aws.String(route53.RRTypeCname),
-          },
-        },
-      },
-      listResourceRecordSetsError: nil,
-    },
-    {
-      hostname: "www.example.com.",
-      hostedZoneID: "ABC123",
-
-      expectedRR: route53.ResourceRecordSet{},
-      expectedError: errors.New("hostname mismatch: input www.example.com., response invalid.example.com."),
-
-      listResourceRecordSetsInput: &route53.ListResourceRecordSetsInput{
-        HostedZoneId: aws.String("ABC123"),
-        MaxItems: aws.String("1"),
-        StartRecordName: aws.String("www.example.com."),
-      },
-      listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-        IsTruncated: aws.Bool(false),
-        MaxItems: aws.String("1"),
-        ResourceRecordSets: []*route53.ResourceRecordSet{
-          {
-            Name: aws.String("invalid.example.com."),
-            ResourceRecords: []*route53.ResourceRecord{
-              {
-                Value: aws.String("cname.example.com."),
-              },
-            },
-            TTL: aws.Int64(600),
-            Type: aws.String(route53.RRTypeCname),
-          },
-        },
-      },
-      listResourceRecordSetsError: nil,
-    },
-  }
-
-  for idx, p := range patterns {
-    awsClient := &AWSClientImpl{
-      r53: &DummyRoute53Client{
-        t: t,
-
-        listResourceRecordSetsInput: p.listResourceRecordSetsInput,
-        listResourceRecordSetsOutput: p.listResourceRecordSetsOutput,
-        listHostedZonesByNameError: p.listResourceRecordSetsError,
-      },
-    }
-    rr, err := awsClient.GetResourceRecordSetByName(p.hostname, p.hostedZoneID)
-    if err != nil && err.Error() != p.expectedError.Error() {
-      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
-    }
-    if awsutil.StringValue(rr) != awsutil.StringValue(p.expectedRR) {
-      t.Errorf("response mismatch: expected %v, actual %v", p.expectedRR, rr)
-    }
-  }
-}
-
 func TestDeleteResourceRecordSet(t *testing.T) {
   patterns := []struct{
     rrset *route53.ResourceRecordSet
@@ -1113,3 +1025,69 @@ func TestChangeAndWaitResourceRecordSet(t *testing.T) {
     }
   }
 }
+
+func TestNewAWSConfig(t *testing.T) {
+  patterns := []struct{
+    endpointURL string
+    region string
+    confToml ConfToml
+
+    expectedEndpoint string
+    expectedRegion string
+  }{
+    { "", "", ConfToml{}, "", "" },
+    { "http://localhost:4566", "us-east-1", ConfToml{}, "http://localhost:4566", "us-east-1" },
+    { "", "", ConfToml{EndpointURL: "http://localhost:5000", Region: "ap-northeast-1"}, "http://localhost:5000", "ap-northeast-1" },
+    { "http://localhost:4566", "", ConfToml{EndpointURL: "http://localhost:5000", Region: "ap-northeast-1"}, "http://localhost:4566", "ap-northeast-1" },
+  }
+
+  for idx, p := range patterns {
+    config := NewAWSConfig(p.endpointURL, p.region, p.confToml)
+    if aws.StringValue(config.Endpoint) != p.expectedEndpoint {
+      t.Errorf("pattern %d: want %s, actual %s", idx, p.expectedEndpoint, aws.StringValue(config.Endpoint))
+    }
+    if aws.StringValue(config.Region) != p.expectedRegion {
+      t.Errorf("pattern %d: want %s, actual %s", idx, p.expectedRegion, aws.StringValue(config.Region))
+    }
+  }
+}
+
+func TestAWSConfigFromContextShadowedRegion(t *testing.T) {
+  var config *aws.Config
+  var policy RoutingPolicy
+  app := &cli.App{
+    Flags: []cli.Flag{
+      &cli.StringFlag{Name: "conf"},
+      &cli.StringFlag{Name: "endpoint-url"},
+      &cli.StringFlag{Name: "region"},
+    },
+    Commands: []*cli.Command{
+      {
+        Name: "add",
+        Flags: RoutingPolicyFlags,
+        Action: func(c *cli.Context) (err error) {
+          config, err = awsConfigFromContext(c)
+          if err != nil {
+            return err
+          }
+          policy, err = RoutingPolicyFromContext(c)
+          return err
+        },
+      },
+    },
+  }
+
+  err := app.Run([]string{"cli-test", "--region", "us-east-1", "--endpoint-url", "http://localhost:4566", "add", "--set-id", "tokyo", "--region", "ap-northeast-1"})
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if aws.StringValue(config.Region) != "us-east-1" {
+    t.Errorf("want us-east-1, actual %s", aws.StringValue(config.Region))
+  }
+  if aws.StringValue(config.Endpoint) != "http://localhost:4566" {
+    t.Errorf("want http://localhost:4566, actual %s", aws.StringValue(config.Endpoint))
+  }
+  if policy.Region != "ap-northeast-1" {
+    t.Errorf("want ap-northeast-1, actual %s", policy.Region)
+  }
+}
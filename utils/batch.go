@@ -0,0 +1,165 @@
+package utils
+
+import (
+  "fmt"
+  "sync"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// Limits of a change batch. Route53 counts the ResourceRecord elements and
+// the characters of their values, and counts UPSERT changes twice.
+const (
+  MaxBatchRecords = 1000
+  MaxBatchValueChars = 32000
+)
+
+// changeSize returns the ResourceRecord elements and value characters
+// change counts for against the limits of a change batch.
+func changeSize(change *route53.Change) (records int, chars int) {
+  rrset := change.ResourceRecordSet
+  if rrset.AliasTarget != nil {
+    records++
+  }
+  for _, rr := range rrset.ResourceRecords {
+    records++
+    chars += len(aws.StringValue(rr.Value))
+  }
+  if aws.StringValue(change.Action) == route53.ChangeActionUpsert {
+    records, chars = records*2, chars*2
+  }
+  return records, chars
+}
+
+// ChunkChangeStep splits step into steps of the same hosted zone which are
+// within the limits of a change batch. A step within the limits is returned
+// as it is. The chunks are no longer atomic as a whole, which is why a
+// change which alone is over the limits is an error rather than a chunk.
+func ChunkChangeStep(step *ChangeStep) (chunks []*ChangeStep, err error) {
+  if len(step.Rollback) > 0 && len(step.Rollback) != len(step.Changes) {
+    return nil, fmt.Errorf("step of %s has %d changes but %d rollback changes", step.HostedZoneID, len(step.Changes), len(step.Rollback))
+  }
+
+  var chunk *ChangeStep
+  records, chars := 0, 0
+  for idx, change := range step.Changes {
+    r, c := changeSize(change)
+    if r > MaxBatchRecords || c > MaxBatchValueChars {
+      rrset := change.ResourceRecordSet
+      return nil, fmt.Errorf("%s %s %s is over the limits of a change batch", aws.StringValue(change.Action), aws.StringValue(rrset.Name), aws.StringValue(rrset.Type))
+    }
+    if chunk == nil || records+r > MaxBatchRecords || chars+c > MaxBatchValueChars {
+      chunk = &ChangeStep{HostedZoneID: step.HostedZoneID, HostedZoneName: step.HostedZoneName}
+      chunks = append(chunks, chunk)
+      records, chars = 0, 0
+    }
+    records += r
+    chars += c
+    chunk.Changes = append(chunk.Changes, change)
+    if len(step.Rollback) > 0 {
+      // Rollback is in the reverse order of Changes
+      inverse := step.Rollback[len(step.Rollback)-1-idx]
+      chunk.Rollback = append([]*route53.Change{inverse}, chunk.Rollback...)
+    }
+  }
+  if len(chunks) <= 1 {
+    return []*ChangeStep{step}, nil
+  }
+  return chunks, nil
+}
+
+// ChunkChangePlan returns plan with every step split by ChunkChangeStep.
+func ChunkChangePlan(plan *ChangePlan) (chunked *ChangePlan, err error) {
+  chunked = &ChangePlan{}
+  for _, step := range plan.Steps {
+    chunks, err := ChunkChangeStep(step)
+    if err != nil {
+      return nil, err
+    }
+    chunked.Steps = append(chunked.Steps, chunks...)
+  }
+  return chunked, nil
+}
+
+// ApplyChangePlanConcurrently applies a plan whose steps do not depend on
+// each other, such as the forward and reverse changes of many hosts. All
+// the steps are submitted before any is waited for, and the waits run
+// concurrently, so the plan takes about as long as its slowest step. When a
+// step fails, the submitted steps are rolled back.
+func (client *AWSClientImpl) ApplyChangePlanConcurrently(plan *ChangePlan) (err error) {
+  plan, err = ChunkChangePlan(plan)
+  if err != nil {
+    return err
+  }
+  if client.dryRun {
+    return PrintChangePlan(client.out, plan, client.planFormat)
+  }
+
+  entry, err := client.beginJournal(plan)
+  if err != nil {
+    return err
+  }
+  var changeIDs []string
+  // settled is 1 when the step which failed ambiguously was committed
+  settled := 0
+  var settleErr error
+  for idx, step := range plan.Steps {
+    var changeID string
+    changeID, err = client.submitChangeStep(idx, step, entry)
+    if err != nil {
+      if client.ctx.Err() == nil && IsAmbiguousError(err) {
+        var applied bool
+        applied, settleErr = client.settleChangeStep(idx, step, entry)
+        if applied {
+          settled = 1
+        }
+      }
+      break
+    }
+    changeIDs = append(changeIDs, changeID)
+  }
+  waitErr := client.waitChangeSteps(changeIDs, entry)
+  if settleErr != nil {
+    // the step is left applying for resume or abort to settle
+    return fmt.Errorf("%v (step %d may have been applied: %v)", err, len(changeIDs)+1, settleErr)
+  }
+  if err == nil {
+    err = waitErr
+  }
+  if err != nil {
+    return client.rollbackOnError(plan.Steps[:len(changeIDs)+settled], entry, err)
+  }
+  return entry.update(-1, JournalApplied)
+}
+
+// waitChangeSteps waits for the changes of the first len(changeIDs) steps
+// concurrently, and returns the first error in the order of the steps.
+func (client *AWSClientImpl) waitChangeSteps(changeIDs []string, entry *JournalEntry) (err error) {
+  if client.noWait {
+    // nothing to wait for, the change IDs are printed in order
+    for idx, changeID := range changeIDs {
+      err = client.waitChangeStep(idx, changeID, entry)
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  }
+  errs := make([]error, len(changeIDs))
+  var wg sync.WaitGroup
+  for idx, changeID := range changeIDs {
+    wg.Add(1)
+    go func(idx int, changeID string) {
+      defer wg.Done()
+      errs[idx] = client.waitChangeStep(idx, changeID, entry)
+    }(idx, changeID)
+  }
+  wg.Wait()
+  for _, err := range errs {
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+  "fmt"
+  "net"
+  "strings"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// newHostsStep returns a step creating n A records, with their rollback.
+func newHostsStep(n int) *ChangeStep {
+  step := &ChangeStep{HostedZoneID: "ABC123", HostedZoneName: "example.com."}
+  for i := 0; i < n; i++ {
+    ip := net.IPv4(10, 0, byte(i/256), byte(i%256))
+    step.AppendChange(route53.ChangeActionCreate, newAddressResourceRecordSet(ip, fmt.Sprintf("host%d.example.com.", i)), nil)
+  }
+  return step
+}
+
+func TestChunkChangeStep(t *testing.T) {
+  // 4 values of 2500 characters, counted twice
+  txt := &route53.ResourceRecordSet{
+    Name: aws.String("txt.example.com."),
+    Type: aws.String(route53.RRTypeTxt),
+    TTL: aws.Int64(300),
+  }
+  for i := 0; i < 4; i++ {
+    txt.ResourceRecords = append(txt.ResourceRecords, &route53.ResourceRecord{Value: aws.String(`"` + strings.Repeat("x", 2498) + `"`)})
+  }
+  upserts := &ChangeStep{HostedZoneID: "ABC123"}
+  for i := 0; i < 3; i++ {
+    upserts.Changes = append(upserts.Changes, newChange(route53.ChangeActionUpsert, txt))
+  }
+  huge := &route53.ResourceRecordSet{
+    Name: txt.Name,
+    Type: txt.Type,
+    TTL: txt.TTL,
+    ResourceRecords: append(append([]*route53.ResourceRecord{}, txt.ResourceRecords...), txt.ResourceRecords...),
+  }
+
+  patterns := []struct {
+    step *ChangeStep
+
+    expectedSizes []int
+    expectedError string
+  }{
+    {
+      step: newHostsStep(3),
+      expectedSizes: []int{3},
+    },
+    {
+      step: newHostsStep(2500),
+      expectedSizes: []int{1000, 1000, 500},
+    },
+    {
+      step: upserts,
+      expectedSizes: []int{1, 1, 1},
+    },
+    {
+      step: &ChangeStep{
+        HostedZoneID: "ABC123",
+        Changes: []*route53.Change{newChange(route53.ChangeActionUpsert, huge)},
+      },
+      expectedError: "UPSERT txt.example.com. TXT is over the limits of a change batch",
+    },
+  }
+
+  for idx, pattern := range patterns {
+    chunks, err := ChunkChangeStep(pattern.step)
+    if len(pattern.expectedError) > 0 {
+      if err == nil || err.Error() != pattern.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, pattern.expectedError, err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, nil, err)
+      continue
+    }
+    if len(chunks) != len(pattern.expectedSizes) {
+      t.Errorf("pattern %d: want %d chunks, actual %d", idx, len(pattern.expectedSizes), len(chunks))
+      continue
+    }
+    if len(chunks) == 1 && chunks[0] != pattern.step {
+      t.Errorf("pattern %d: want the step itself", idx)
+    }
+    total := 0
+    for i, chunk := range chunks {
+      if len(chunk.Changes) != pattern.expectedSizes[i] {
+        t.Errorf("pattern %d: chunk %d: want %d changes, actual %d", idx, i, pattern.expectedSizes[i], len(chunk.Changes))
+      }
+      if chunk.HostedZoneID != pattern.step.HostedZoneID {
+        t.Errorf("pattern %d: chunk %d: want hosted zone %s, actual %s", idx, i, pattern.step.HostedZoneID, chunk.HostedZoneID)
+      }
+      // the rollback of a chunk reverts the changes of the chunk
+      for j, change := range chunk.Changes {
+        if len(pattern.step.Rollback) == 0 {
+          break
+        }
+        inverse := chunk.Rollback[len(chunk.Rollback)-1-j]
+        if aws.StringValue(inverse.Action) != route53.ChangeActionDelete || inverse.ResourceRecordSet != change.ResourceRecordSet {
+          t.Errorf("pattern %d: chunk %d: change %d: unexpected rollback %v", idx, i, j, inverse)
+        }
+      }
+      total += len(chunk.Changes)
+    }
+    if total != len(pattern.step.Changes) {
+      t.Errorf("pattern %d: want %d changes, actual %d", idx, len(pattern.step.Changes), total)
+    }
+  }
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// BulkHost is a host of the --from-file input of add and delete.
+type BulkHost struct {
+  Hostname string `json:"hostname"`
+  IP string `json:"ip"`
+}
+
+// ReadBulkHosts reads the hosts of path, a JSON array of
+// {"hostname": ..., "ip": ...} objects when it ends in ".json", and CSV
+// rows of hostname and ip otherwise. A CSV header row starting with
+// "hostname" and lines starting with "#" are skipped.
+func ReadBulkHosts(path string) (hosts []BulkHost, err error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  if strings.EqualFold(filepath.Ext(path), ".json") {
+    err = json.NewDecoder(f).Decode(&hosts)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %v", path, err)
+    }
+  } else {
+    hosts, err = readBulkHostsCSV(f)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %v", path, err)
+    }
+  }
+  if len(hosts) == 0 {
+    return nil, fmt.Errorf("%s: no hosts", path)
+  }
+  return hosts, nil
+}
+
+func readBulkHostsCSV(r io.Reader) (hosts []BulkHost, err error) {
+  reader := csv.NewReader(r)
+  reader.Comment = '#'
+  reader.FieldsPerRecord = -1
+  reader.TrimLeadingSpace = true
+  for row := 1; ; row++ {
+    record, err := reader.Read()
+    if err == io.EOF {
+      return hosts, nil
+    }
+    if err != nil {
+      return nil, err
+    }
+    if row == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "hostname") {
+      continue
+    }
+    if len(record) > 2 {
+      return nil, fmt.Errorf("row %d: want hostname and ip, got %d fields", row, len(record))
+    }
+    host := BulkHost{Hostname: strings.TrimSpace(record[0])}
+    if len(record) > 1 {
+      host.IP = strings.TrimSpace(record[1])
+    }
+    hosts = append(hosts, host)
+  }
+}
+
+// PlanBulkAdd builds the plan which creates the address records of hosts in
+// the hosted zone and their PTR records, with one step per hosted zone.
+// Every host needs an IP address, and the hostnames and addresses must be
+// unique.
+func (client *AWSClientImpl) PlanBulkAdd(hosts []BulkHost, hostedZoneID string, hostedZoneName string, rInfos ReverseHostedZoneInfos) (plan *ChangePlan, err error) {
+  forward := &changeSteps{}
+  reverse := &changeSteps{}
+  hostnames := map[string]bool{}
+  ips := map[string]bool{}
+  for idx, host := range hosts {
+    if len(host.Hostname) == 0 {
+      return nil, fmt.Errorf("host %d: no hostname", idx+1)
+    }
+    hostname := Fqdn(host.Hostname, hostedZoneName)
+    ip := net.ParseIP(host.IP)
+    if ip == nil {
+      return nil, fmt.Errorf("%s: invalid ip: %s", hostname, host.IP)
+    }
+    if hostnames[strings.ToLower(hostname)] {
+      return nil, fmt.Errorf("%s: duplicate hostname", hostname)
+    }
+    if ips[ip.String()] {
+      return nil, fmt.Errorf("%s: duplicate ip: %s", hostname, ip.String())
+    }
+    hostnames[strings.ToLower(hostname)] = true
+    ips[ip.String()] = true
+
+    rInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %v", hostname, err)
+    }
+    forward.step(hostedZoneID, hostedZoneName).AppendChange(route53.ChangeActionCreate, newAddressResourceRecordSet(ip, hostname), nil)
+    reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName).AppendChange(route53.ChangeActionCreate, rInfo.ptrResourceRecordSet(ip, hostname), nil)
+    err = client.planParentCname(reverse, rInfo, ip)
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  plan = &ChangePlan{}
+  forward.addTo(plan)
+  reverse.addTo(plan)
+  return plan, nil
+}
+
+// PlanBulkRemove builds the plan which deletes the only record set at each
+// hostname of hosts from the hosted zone, and the PTR records of every
+// address of address records, with one step per hosted zone. The ip of the
+// hosts is not used.
+func (client *AWSClientImpl) PlanBulkRemove(hosts []BulkHost, hostedZoneID string, hostedZoneName string, rInfos ReverseHostedZoneInfos) (plan *ChangePlan, err error) {
+  // the record sets of each hosted zone, listed once
+  zoneRecords := map[string][]*route53.ResourceRecordSet{}
+  find := func(name string, rrType string, zoneID string) (rrset *route53.ResourceRecordSet, err error) {
+    rrsets, ok := zoneRecords[zoneID]
+    if !ok {
+      rrsets, err = client.ListAllResourceRecords(zoneID)
+      if err != nil {
+        return nil, err
+      }
+      zoneRecords[zoneID] = rrsets
+    }
+    matches, err := FilterResourceRecordSets(rrsets, name, rrType, "")
+    if err != nil {
+      return nil, err
+    }
+    return onlyResourceRecordSet(matches)
+  }
+
+  forward := &changeSteps{}
+  reverse := &changeSteps{}
+  hostnames := map[string]bool{}
+  ips := map[string]bool{}
+  for idx, host := range hosts {
+    if len(host.Hostname) == 0 {
+      return nil, fmt.Errorf("host %d: no hostname", idx+1)
+    }
+    hostname := Fqdn(host.Hostname, hostedZoneName)
+    if hostnames[strings.ToLower(hostname)] {
+      return nil, fmt.Errorf("%s: duplicate hostname", hostname)
+    }
+    hostnames[strings.ToLower(hostname)] = true
+
+    rrset, err := find(hostname, "", hostedZoneID)
+    if err != nil {
+      return nil, err
+    }
+    forward.step(hostedZoneID, hostedZoneName).AppendChange(route53.ChangeActionDelete, rrset, nil)
+
+    rrType := aws.StringValue(rrset.Type)
+    if rrset.AliasTarget != nil || (rrType != route53.RRTypeA && rrType != route53.RRTypeAaaa) {
+      continue
+    }
+    for _, rr := range rrset.ResourceRecords {
+      ip := net.ParseIP(aws.StringValue(rr.Value))
+      if ip == nil || ips[ip.String()] {
+        // the PTR record is deleted with another host of the same address
+        continue
+      }
+      ips[ip.String()] = true
+      rInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %v", hostname, err)
+      }
+      ptr, err := find(rInfo.PtrRecordName(ip), route53.RRTypePtr, rInfo.HostedZoneID)
+      if err != nil {
+        return nil, err
+      }
+      reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName).AppendChange(route53.ChangeActionDelete, ptr, nil)
+      err = client.planRemoveParentCname(reverse, rInfo, ip)
+      if err != nil {
+        return nil, err
+      }
+    }
+  }
+
+  plan = &ChangePlan{}
+  forward.addTo(plan)
+  reverse.addTo(plan)
+  return plan, nil
+}
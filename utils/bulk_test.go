@@ -0,0 +1,81 @@
+package utils
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestReadBulkHosts(t *testing.T) {
+  dir, err := ioutil.TempDir("", "bulk-test")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  patterns := []struct {
+    name string
+    content string
+
+    expected []BulkHost
+    expectedError string
+  }{
+    {
+      name: "hosts.csv",
+      content: "hostname,ip\n# web servers\nweb1,10.0.1.1\nweb2.example.com., 10.0.1.2\n\ndb1\n",
+      expected: []BulkHost{
+        {Hostname: "web1", IP: "10.0.1.1"},
+        {Hostname: "web2.example.com.", IP: "10.0.1.2"},
+        {Hostname: "db1"},
+      },
+    },
+    {
+      name: "hosts.json",
+      content: `[{"hostname": "web1", "ip": "10.0.1.1"}, {"hostname": "web2", "ip": "2001:db8::2"}]`,
+      expected: []BulkHost{
+        {Hostname: "web1", IP: "10.0.1.1"},
+        {Hostname: "web2", IP: "2001:db8::2"},
+      },
+    },
+    {
+      name: "extra.csv",
+      content: "web1,10.0.1.1,A\n",
+      expectedError: "row 1: want hostname and ip, got 3 fields",
+    },
+    {
+      name: "empty.csv",
+      content: "hostname,ip\n",
+      expectedError: "no hosts",
+    },
+    {
+      name: "broken.json",
+      content: `{"hostname": "web1"}`,
+      expectedError: "cannot unmarshal object",
+    },
+  }
+
+  for idx, pattern := range patterns {
+    path := filepath.Join(dir, pattern.name)
+    err := ioutil.WriteFile(path, []byte(pattern.content), 0644)
+    if err != nil {
+      t.Fatal(err)
+    }
+    actual, err := ReadBulkHosts(path)
+    if len(pattern.expectedError) > 0 {
+      if err == nil || !strings.Contains(err.Error(), pattern.expectedError) {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, pattern.expectedError, err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, nil, err)
+      continue
+    }
+    if !reflect.DeepEqual(actual, pattern.expected) {
+      t.Errorf("pattern %d: want %v, actual %v", idx, pattern.expected, actual)
+    }
+  }
+}
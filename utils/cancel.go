@@ -0,0 +1,54 @@
+package utils
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "os/signal"
+  "syscall"
+)
+
+// SignalContext returns a context which is cancelled on SIGINT or SIGTERM,
+// so that the step in flight is rolled back. The default handling is
+// restored after the first signal, so a second one kills the process; the
+// journal then tells resume or abort where it stopped.
+func SignalContext(parent context.Context) (ctx context.Context, stop func()) {
+  ctx, cancel := context.WithCancel(parent)
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+  go func() {
+    select {
+    case sig := <-signals:
+      signal.Stop(signals)
+      fmt.Fprintf(os.Stderr, "%v: cancelling, send it again to exit immediately\n", sig)
+      cancel()
+    case <-ctx.Done():
+    }
+  }()
+  return ctx, func() {
+    signal.Stop(signals)
+    cancel()
+  }
+}
+
+// rollbackContext returns the context of a rollback, which is bound by the
+// --timeout of the command but not by its cancellation.
+func (client *AWSClientImpl) rollbackContext() (ctx context.Context, cancel context.CancelFunc) {
+  if client.rollbackTimeout > 0 {
+    return context.WithTimeout(context.Background(), client.rollbackTimeout)
+  }
+  return context.WithCancel(context.Background())
+}
+
+// canceledError replaces err with a plain message when it was caused by the
+// cancellation of ctx, as the SDK reports it as a failed request.
+func canceledError(ctx context.Context, err error) error {
+  switch ctx.Err() {
+  case nil:
+    return err
+  case context.DeadlineExceeded:
+    return fmt.Errorf("timed out: %v", err)
+  default:
+    return fmt.Errorf("canceled: %v", err)
+  }
+}
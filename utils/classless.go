@@ -0,0 +1,165 @@
+package utils
+
+import (
+  "fmt"
+  "net"
+  "strconv"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// IsClassless reports whether the reverse hosted zone is an RFC 2317
+// classless zone, such as "0-63.2.0.192.in-addr.arpa.", whose PTR records
+// are not named after the full reversed address.
+func (rInfo ReverseHostedZoneInfo) IsClassless() bool {
+  if rInfo.Network == nil || rInfo.Network.IP.To4() == nil {
+    return false
+  }
+  return !InZone(GenerateReverseRecord(rInfo.Network.IP), rInfo.HostedZoneName)
+}
+
+// PtrRecordName returns the name of the PTR record of ip in the reverse
+// hosted zone. In a classless zone it is the last octet of ip under the
+// zone name, as in "5.0-63.2.0.192.in-addr.arpa.".
+func (rInfo ReverseHostedZoneInfo) PtrRecordName(ip net.IP) string {
+  if !rInfo.IsClassless() || ip.To4() == nil {
+    return GenerateReverseRecord(ip)
+  }
+  return fmt.Sprintf("%d.%s", ip.To4()[3], Fqdn("@", rInfo.HostedZoneName))
+}
+
+// ptrResourceRecordSet builds the PTR record set of ip in the reverse hosted
+// zone.
+func (rInfo ReverseHostedZoneInfo) ptrResourceRecordSet(ip net.IP, hostname string) *route53.ResourceRecordSet {
+  rrset := newPtrResourceRecordSet(ip, hostname)
+  rrset.Name = aws.String(rInfo.PtrRecordName(ip))
+  return rrset
+}
+
+// parentCnameResourceRecordSet builds the CNAME which points the name of ip
+// in the parent zone at the PTR record in the classless zone, or returns nil
+// when the parent zone is not maintained.
+func (rInfo ReverseHostedZoneInfo) parentCnameResourceRecordSet(ip net.IP) *route53.ResourceRecordSet {
+  if len(rInfo.ParentHostedZoneID) == 0 || !rInfo.IsClassless() {
+    return nil
+  }
+  return &route53.ResourceRecordSet{
+    Name: aws.String(GenerateReverseRecord(ip)),
+    ResourceRecords: []*route53.ResourceRecord{
+      {
+        Value: aws.String(rInfo.PtrRecordName(ip)),
+      },
+    },
+    TTL: aws.Int64(600),
+    Type: aws.String(route53.RRTypeCname),
+  }
+}
+
+// checkClassless checks that a classless zone covers an IPv4 network
+// smaller than a /24, the only networks RFC 2317 delegates.
+func (rInfo ReverseHostedZoneInfo) checkClassless() (err error) {
+  if !rInfo.IsClassless() {
+    return nil
+  }
+  ones, _ := rInfo.Network.Mask.Size()
+  if ones <= 24 {
+    return fmt.Errorf("%s is not a reverse zone of %s", rInfo.HostedZoneName, rInfo.NetworkCIDR)
+  }
+  return nil
+}
+
+// changeSteps collects one change step per hosted zone, in the order the
+// hosted zones are first used.
+type changeSteps struct {
+  steps map[string]*ChangeStep
+  order []string
+}
+
+func (s *changeSteps) step(hostedZoneID string, hostedZoneName string) *ChangeStep {
+  if s.steps == nil {
+    s.steps = map[string]*ChangeStep{}
+  }
+  step, ok := s.steps[hostedZoneID]
+  if !ok {
+    step = &ChangeStep{HostedZoneID: hostedZoneID, HostedZoneName: hostedZoneName}
+    s.steps[hostedZoneID] = step
+    s.order = append(s.order, hostedZoneID)
+  }
+  return step
+}
+
+func (s *changeSteps) addTo(plan *ChangePlan) {
+  for _, id := range s.order {
+    plan.AddChangeStep(s.steps[id])
+  }
+}
+
+// upsertParentCname adds the parent zone CNAME of ip to steps unless
+// previous already is that record set.
+func upsertParentCname(steps *changeSteps, rInfo ReverseHostedZoneInfo, ip net.IP, previous *route53.ResourceRecordSet) {
+  cname := rInfo.parentCnameResourceRecordSet(ip)
+  if cname == nil || (previous != nil && EqualResourceRecordSet(previous, cname)) {
+    return
+  }
+  steps.step(rInfo.ParentHostedZoneID, rInfo.ParentHostedZoneName).AppendChange(route53.ChangeActionUpsert, cname, previous)
+}
+
+// deleteParentCname adds the deletion of previous, the parent zone CNAME of
+// ip, to steps when it points at the PTR record of ip.
+func deleteParentCname(steps *changeSteps, rInfo ReverseHostedZoneInfo, ip net.IP, previous *route53.ResourceRecordSet) {
+  if rInfo.parentCnameResourceRecordSet(ip) == nil || previous == nil || !ptrPointsAt(previous, rInfo.PtrRecordName(ip)) {
+    return
+  }
+  steps.step(rInfo.ParentHostedZoneID, rInfo.ParentHostedZoneName).AppendChange(route53.ChangeActionDelete, previous, nil)
+}
+
+// planParentCname looks up the parent zone CNAME of ip and adds its UPSERT
+// to steps when the parent zone is maintained.
+func (client *AWSClientImpl) planParentCname(steps *changeSteps, rInfo ReverseHostedZoneInfo, ip net.IP) (err error) {
+  if rInfo.parentCnameResourceRecordSet(ip) == nil {
+    return nil
+  }
+  previous, err := client.getResourceRecordSet(GenerateReverseRecord(ip), route53.RRTypeCname, rInfo.ParentHostedZoneID)
+  if err != nil {
+    return err
+  }
+  upsertParentCname(steps, rInfo, ip, previous)
+  return nil
+}
+
+// planRemoveParentCname looks up the parent zone CNAME of ip and adds its
+// deletion to steps when the parent zone is maintained.
+func (client *AWSClientImpl) planRemoveParentCname(steps *changeSteps, rInfo ReverseHostedZoneInfo, ip net.IP) (err error) {
+  if rInfo.parentCnameResourceRecordSet(ip) == nil {
+    return nil
+  }
+  previous, err := client.getResourceRecordSet(GenerateReverseRecord(ip), route53.RRTypeCname, rInfo.ParentHostedZoneID)
+  if err != nil {
+    return err
+  }
+  deleteParentCname(steps, rInfo, ip, previous)
+  return nil
+}
+
+// PtrRecordIP returns the address of the PTR record name in the reverse
+// hosted zone, or nil when name is not the name of an address of the zone.
+func (rInfo ReverseHostedZoneInfo) PtrRecordIP(name string) net.IP {
+  if !rInfo.IsClassless() {
+    return ParseReverseRecord(name)
+  }
+  zoneName := Fqdn("@", rInfo.HostedZoneName)
+  label := strings.TrimSuffix(strings.ToLower(Fqdn(name, zoneName)), "."+strings.ToLower(zoneName))
+  octet, err := strconv.ParseUint(label, 10, 8)
+  if err != nil || label != strconv.FormatUint(octet, 10) {
+    return nil
+  }
+  ip := make(net.IP, net.IPv4len)
+  copy(ip, rInfo.Network.IP.To4())
+  ip[3] = byte(octet)
+  if !rInfo.Network.Contains(ip) {
+    return nil
+  }
+  return ip
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+  "net"
+  "strings"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestPtrRecordName(t *testing.T) {
+  classless := newReverseHostedZoneInfo("192.0.2.0/26", "CLS123", "0-63.2.0.192.in-addr.arpa.")
+  octet := newReverseHostedZoneInfo("192.0.2.0/24", "REV123", "2.0.192.in-addr.arpa.")
+  subnet := newReverseHostedZoneInfo("192.0.2.64/26", "REV123", "2.0.192.in-addr.arpa.")
+  ip6 := newReverseHostedZoneInfo("2001:db8::/32", "REV456", "8.b.d.0.1.0.0.2.ip6.arpa.")
+
+  patterns := []struct{
+    rInfo ReverseHostedZoneInfo
+    ip net.IP
+    expectedClassless bool
+    expected string
+  }{
+    { classless, net.ParseIP("192.0.2.5"), true, "5.0-63.2.0.192.in-addr.arpa." },
+    { octet, net.ParseIP("192.0.2.5"), false, "5.2.0.192.in-addr.arpa." },
+    { subnet, net.ParseIP("192.0.2.70"), false, "70.2.0.192.in-addr.arpa." },
+    { ip6, net.ParseIP("2001:db8::1"), false, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa." },
+  }
+
+  for idx, p := range patterns {
+    if p.rInfo.IsClassless() != p.expectedClassless {
+      t.Errorf("pattern %d: want classless %v, actual %v", idx, p.expectedClassless, p.rInfo.IsClassless())
+    }
+    actual := p.rInfo.PtrRecordName(p.ip)
+    if actual != p.expected {
+      t.Errorf("pattern %d: want %s, actual %s", idx, p.expected, actual)
+    }
+  }
+
+  wide := newReverseHostedZoneInfo("192.0.0.0/16", "CLS123", "0-63.2.0.192.in-addr.arpa.")
+  err := wide.checkClassless()
+  if err == nil || err.Error() != "0-63.2.0.192.in-addr.arpa. is not a reverse zone of 192.0.0.0/16" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
+
+func TestBuildSyncPlanClassless(t *testing.T) {
+  rInfo := newReverseHostedZoneInfo("192.0.2.0/26", "CLS123", "0-63.2.0.192.in-addr.arpa.")
+  rInfo.ParentHostedZoneID = "REV123"
+  rInfo.ParentHostedZoneName = "2.0.192.in-addr.arpa."
+  rInfos := ReverseHostedZoneInfos{ReverseHostedZoneInfo: []ReverseHostedZoneInfo{rInfo}}
+
+  current := []*route53.ResourceRecordSet{
+    newAddressResourceRecordSet(net.ParseIP("192.0.2.1"), "old.example.com."),
+  }
+  records := map[string][]*route53.ResourceRecordSet{
+    "CLS123": {
+      rInfo.ptrResourceRecordSet(net.ParseIP("192.0.2.1"), "old.example.com."),
+    },
+    "REV123": {
+      rInfo.parentCnameResourceRecordSet(net.ParseIP("192.0.2.1")),
+      // a CNAME maintained by hand is left as it is
+      {
+        Name: aws.String("2.2.0.192.in-addr.arpa."),
+        Type: aws.String(route53.RRTypeCname),
+        TTL: aws.Int64(600),
+        ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("2.0-63.2.0.192.in-addr.arpa.")}},
+      },
+    },
+  }
+  desired, err := DesiredResourceRecordSets(DesiredState{
+    Hosts: []DesiredHost{
+      { Name: "web1", IP: "192.0.2.2" },
+    },
+  }, "example.com.")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  plan, err := buildSyncPlan("ABC123", "example.com.", desired, current, records, rInfos, true, false)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  var out strings.Builder
+  err = PrintChangePlan(&out, plan, "text")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  expected := strings.Join([]string{
+    "@@ step 1: example.com. (ABC123) @@",
+    "- old.example.com. 600 A 192.0.2.1",
+    "+ web1.example.com. 600 A 192.0.2.2",
+    "@@ step 2: 0-63.2.0.192.in-addr.arpa. (CLS123) @@",
+    "- 1.0-63.2.0.192.in-addr.arpa. 600 PTR old.example.com.",
+    "~ 2.0-63.2.0.192.in-addr.arpa. 600 PTR web1.example.com.",
+    "@@ step 3: 2.0.192.in-addr.arpa. (REV123) @@",
+    "- 1.2.0.192.in-addr.arpa. 600 CNAME 1.0-63.2.0.192.in-addr.arpa.",
+    "",
+  }, "\n")
+  if out.String() != expected {
+    t.Errorf("unexpected plan: expected\n%s\nactual\n%s", expected, out.String())
+  }
+}
@@ -4,7 +4,14 @@ import (
   "github.com/BurntSushi/toml"
 )
 
+// The config file is optional. Reverse hosted zones are discovered from the
+// hosted zones of the account, and a ReverseHostedZone entry overrides the
+// discovered zone of the same network.
+//
 // ```
+// EndpointURL = "http://localhost:4566"
+// Region = "us-east-1"
+//
 // [[ReverseHostedZone]]
 // NetworkCIDR = "10.0.0.0/8"
 // ZoneName = "10.in-addr.arpa."
@@ -14,21 +21,55 @@ import (
 // [[ReverseHostedZone]]
 // NetworkCIDR = "172.16.0.0/12"
 // ZoneName = "16.172.in-addr.arpa."
+// [[ReverseHostedZone]]
+// NetworkCIDR = "2001:db8::/32"
+// ZoneName = "8.b.d.0.1.0.0.2.ip6.arpa."
+// [[ReverseHostedZone]]
+// NetworkCIDR = "192.0.2.0/26"
+// ZoneName = "0-63.2.0.192.in-addr.arpa."
+// ParentZoneName = "2.0.192.in-addr.arpa."
+//
+// [[Pool]]
+// Name = "app-subnet"
+// NetworkCIDR = "10.0.1.0/24"
+// Reserved = ["10.0.1.2-10.0.1.9", "10.0.1.240/28"]
+// Gateways = ["10.0.1.1"]
 // ```
 
 // ConfToml ...
 type ConfToml struct {
+  EndpointURL string `toml:"EndpointURL"`
+  Region string `toml:"Region"`
   ReverseHostedZones []ReverseHostedZone `toml:"ReverseHostedZone"`
+  Pools []IPPool `toml:"Pool"`
 }
 
 // ReverseHostedZone ...
 type ReverseHostedZone struct {
   NetworkCIDR string `toml:"NetworkCIDR"`
   ZoneName string `toml:"ZoneName"`
+  // ParentZoneName is the octet-aligned zone a classless zone is delegated
+  // from. When it is set, the CNAMEs pointing at the PTR records of the
+  // classless zone are maintained in it.
+  ParentZoneName string `toml:"ParentZoneName"`
+}
+
+// IPPool is a network which add --pool allocates addresses from.
+type IPPool struct {
+  Name string `toml:"Name"`
+  NetworkCIDR string `toml:"NetworkCIDR"`
+  // Reserved are addresses, CIDRs or ranges "first-last" which are never
+  // allocated.
+  Reserved []string `toml:"Reserved"`
+  Gateways []string `toml:"Gateways"`
 }
 
-// LoadConf ...
+// LoadConf reads the config file at confPath. The config file is optional,
+// so an empty confPath leaves confToml as it is.
 func LoadConf(confPath string, confToml *ConfToml) (err error) {
+  if len(confPath) == 0 {
+    return nil
+  }
   if _, err := toml.DecodeFile(confPath, confToml); err != nil {
     return err
   }
@@ -0,0 +1,213 @@
+package utils
+
+import (
+  "fmt"
+  "io"
+  "sort"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// DefaultDelegationTTL is the TTL of the NS records which delegate a
+// subdomain, the one Route53 gives the apex NS record of a new zone.
+const DefaultDelegationTTL = 172800
+
+// Status of the delegation of a child zone in its parent zone.
+const (
+  DelegationInSync = "in-sync"
+  DelegationMissing = "missing"
+  DelegationMismatch = "mismatch"
+)
+
+// Delegation is the delegation of a child hosted zone by the NS record set
+// of its parent hosted zone.
+type Delegation struct {
+  Parent string `json:"parent" yaml:"parent"`
+  ParentID string `json:"parentId" yaml:"parentId"`
+  Child string `json:"child" yaml:"child"`
+  ChildID string `json:"childId" yaml:"childId"`
+  // NameServers are the name servers of the child zone.
+  NameServers []string `json:"nameServers" yaml:"nameServers"`
+  // DelegatedTo are the values of the NS record set in the parent zone.
+  DelegatedTo []string `json:"delegatedTo,omitempty" yaml:"delegatedTo,omitempty"`
+  Status string `json:"status" yaml:"status"`
+  // Action is what Delegate did to the NS record set, "created" or
+  // "updated", or empty when it was in sync.
+  Action string `json:"action,omitempty" yaml:"action,omitempty"`
+
+  // current is the NS record set in the parent zone, if any.
+  current *route53.ResourceRecordSet
+}
+
+// InSync reports whether the parent zone delegates to the name servers of
+// the child zone.
+func (d Delegation) InSync() bool {
+  return d.Status == DelegationInSync
+}
+
+// CheckDelegation compares the NS record set of the child zone name in the
+// parent zone with the name servers of the child zone. Both zones must be
+// public.
+func (client *AWSClientImpl) CheckDelegation(parentID string, childID string) (d Delegation, err error) {
+  parent, err := client.GetHostedZone(parentID)
+  if err != nil {
+    return d, err
+  }
+  child, err := client.GetHostedZone(childID)
+  if err != nil {
+    return d, err
+  }
+  for _, zone := range []HostedZone{parent, child} {
+    if zone.Private {
+      return d, fmt.Errorf("%s is a private hosted zone, which can not be delegated", zone.Name)
+    }
+  }
+  if compareHostedZoneName(parent.Name, child.Name) || !InZone(child.Name, parent.Name) {
+    return d, fmt.Errorf("%s is not a subdomain of %s", child.Name, parent.Name)
+  }
+
+  d = Delegation{
+    Parent: parent.Name,
+    ParentID: parent.ID,
+    Child: child.Name,
+    ChildID: child.ID,
+    NameServers: child.NameServers,
+    Status: DelegationMissing,
+  }
+  d.current, err = client.FindResourceRecordSet(child.Name, route53.RRTypeNs, "", parent.ID)
+  if IsRecordSetNotFound(err) {
+    return d, nil
+  }
+  if err != nil {
+    return d, err
+  }
+  for _, rr := range d.current.ResourceRecords {
+    d.DelegatedTo = append(d.DelegatedTo, aws.StringValue(rr.Value))
+  }
+  if sameNameServers(d.DelegatedTo, d.NameServers) {
+    d.Status = DelegationInSync
+  } else {
+    d.Status = DelegationMismatch
+  }
+  return d, nil
+}
+
+// PlanDelegation builds the plan which creates the NS record set of d in the
+// parent zone, or replaces the one which does not match the child zone.
+// The plan is empty when the delegation is in sync.
+func PlanDelegation(d Delegation, ttl int64) (plan *ChangePlan) {
+  plan = &ChangePlan{}
+  if d.InSync() {
+    return plan
+  }
+  rrset := &route53.ResourceRecordSet{
+    Name: aws.String(d.Child),
+    Type: aws.String(route53.RRTypeNs),
+    TTL: aws.Int64(ttl),
+  }
+  for _, nameServer := range d.NameServers {
+    rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(nameServer)})
+  }
+  step := &ChangeStep{HostedZoneID: d.ParentID, HostedZoneName: d.Parent}
+  if d.current == nil {
+    step.AppendChange(route53.ChangeActionCreate, rrset, nil)
+  } else {
+    step.AppendChange(route53.ChangeActionUpsert, rrset, d.current)
+  }
+  plan.AddChangeStep(step)
+  return plan
+}
+
+// Delegate delegates childName to its own hosted zone from the parent
+// zone. The child zone is created with opts when there is none, and the NS
+// record set in the parent zone is created or corrected to match its name
+// servers. Running it again changes nothing. It returns the delegation
+// once it is in sync, or as it was found in dry-run mode.
+func (client *AWSClientImpl) Delegate(parentName string, childName string, opts CreateHostedZoneOptions, ttl int64) (d Delegation, err error) {
+  parentID, err := client.GetHostedZoneID(parentName)
+  if err != nil {
+    return d, err
+  }
+  if compareHostedZoneName(Fqdn("@", parentName), Fqdn("@", childName)) || !InZone(childName, parentName) {
+    return d, fmt.Errorf("%s is not a subdomain of %s", Fqdn("@", childName), Fqdn("@", parentName))
+  }
+  childID, err := client.GetHostedZoneID(childName)
+  if IsHostedZoneNotFound(err) {
+    opts.Name = childName
+    child, err := client.CreateHostedZone(opts)
+    if err != nil {
+      return d, err
+    }
+    if client.dryRun {
+      _, err = fmt.Fprintf(client.out, "create NS %s in %s with the name servers of the new zone\n", Fqdn("@", childName), Fqdn("@", parentName))
+      return d, err
+    }
+    childID = child.ID
+  } else if err != nil {
+    return d, err
+  }
+
+  d, err = client.CheckDelegation(parentID, childID)
+  if err != nil {
+    return d, err
+  }
+  if d.InSync() {
+    return d, nil
+  }
+  err = client.ApplyChangePlan(PlanDelegation(d, ttl))
+  if err != nil || client.dryRun {
+    return d, err
+  }
+  d.Action = "updated"
+  if d.current == nil {
+    d.Action = "created"
+  }
+  d.DelegatedTo = d.NameServers
+  d.Status = DelegationInSync
+  return d, nil
+}
+
+// sameNameServers reports whether a and b hold the same name servers,
+// ignoring their order, case and trailing dots.
+func sameNameServers(a []string, b []string) bool {
+  normalize := func(names []string) string {
+    normalized := make([]string, 0, len(names))
+    for _, name := range names {
+      normalized = append(normalized, strings.ToLower(strings.TrimSuffix(name, ".")))
+    }
+    sort.Strings(normalized)
+    return strings.Join(normalized, " ")
+  }
+  return normalize(a) == normalize(b)
+}
+
+// WriteDelegation writes d to w as text, json or yaml.
+func WriteDelegation(w io.Writer, d Delegation, format string) (err error) {
+  switch format {
+  case "", "text":
+    lines := []string{
+      fmt.Sprintf("parent\t%s\t%s", d.Parent, d.ParentID),
+      fmt.Sprintf("child\t%s\t%s", d.Child, d.ChildID),
+      "status\t" + d.Status,
+    }
+    if len(d.Action) > 0 {
+      lines = append(lines, "action\t"+d.Action)
+    }
+    for _, nameServer := range d.NameServers {
+      lines = append(lines, "name server\t"+nameServer)
+    }
+    for _, nameServer := range d.DelegatedTo {
+      lines = append(lines, "delegated to\t"+nameServer)
+    }
+    _, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+    return err
+  case "json":
+    return WriteJSON(w, d)
+  case "yaml":
+    return WriteYAML(w, d)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+  "fmt"
+  "io"
+  "net"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// IPLookup is what the hosted zones know about an address: its PTR record
+// and the address records holding it.
+type IPLookup struct {
+  IP string `json:"ip"`
+  ReverseZone string `json:"reverse_zone,omitempty"`
+  PtrRecord string `json:"ptr_record,omitempty"`
+  PtrHostnames []string `json:"ptr_hostnames"`
+  AddressRecords []IPLookupRecord `json:"address_records"`
+  Mismatches []string `json:"mismatches"`
+}
+
+// IPLookupRecord is an address record holding the looked up address.
+type IPLookupRecord struct {
+  Zone string `json:"zone"`
+  Name string `json:"name"`
+  Type string `json:"type"`
+  SetIdentifier string `json:"set_identifier,omitempty"`
+}
+
+// ForwardHostedZones returns the hosted zones of the account which are not
+// reverse zones.
+func (client *AWSClientImpl) ForwardHostedZones() (zones []*route53.HostedZone, err error) {
+  all, err := client.ListHostedZones()
+  if err != nil {
+    return nil, err
+  }
+  for _, zone := range all {
+    if !IsReverseZone(aws.StringValue(zone.Name)) {
+      zones = append(zones, zone)
+    }
+  }
+  return zones, nil
+}
+
+// LookupIP fetches the PTR record of ip from its reverse hosted zone and
+// searches the forward hosted zones for address records holding ip.
+func (client *AWSClientImpl) LookupIP(ip net.IP, zones []*route53.HostedZone, rInfos ReverseHostedZoneInfos) (result IPLookup, err error) {
+  result.IP = ip.String()
+  rInfo, rErr := GetReverseHostedZoneInfo(ip, rInfos)
+  if rErr == nil {
+    result.ReverseZone = rInfo.HostedZoneName
+    result.PtrRecord = rInfo.PtrRecordName(ip)
+    rrsets, err := client.FindResourceRecordSets(result.PtrRecord, route53.RRTypePtr, "", rInfo.HostedZoneID)
+    if err != nil && !IsRecordSetNotFound(err) {
+      return result, err
+    }
+    for _, rrset := range rrsets {
+      result.PtrHostnames = append(result.PtrHostnames, ptrValues(rrset)...)
+    }
+  }
+
+  var zoneNames []string
+  for _, zone := range zones {
+    zoneName := aws.StringValue(zone.Name)
+    zoneNames = append(zoneNames, zoneName)
+    idParts := strings.Split(aws.StringValue(zone.Id), "/")
+    rrsets, err := client.ListAllResourceRecords(idParts[len(idParts)-1])
+    if err != nil {
+      return result, err
+    }
+    result.AddressRecords = append(result.AddressRecords, addressRecordsOf(ip, zoneName, rrsets)...)
+  }
+
+  if rErr != nil {
+    result.Mismatches = append(result.Mismatches, fmt.Sprintf("reverse hosted zone: %v", rErr))
+  }
+  result.Mismatches = append(result.Mismatches, compareIPLookup(result, zoneNames)...)
+  return result, nil
+}
+
+func addressRecordsOf(ip net.IP, zoneName string, rrsets []*route53.ResourceRecordSet) (records []IPLookupRecord) {
+  for _, rrset := range rrsets {
+    rrType := aws.StringValue(rrset.Type)
+    if rrType != route53.RRTypeA && rrType != route53.RRTypeAaaa {
+      continue
+    }
+    for _, rr := range rrset.ResourceRecords {
+      if ip.Equal(net.ParseIP(aws.StringValue(rr.Value))) {
+        records = append(records, IPLookupRecord{
+          Zone: zoneName,
+          Name: aws.StringValue(rrset.Name),
+          Type: rrType,
+          SetIdentifier: aws.StringValue(rrset.SetIdentifier),
+        })
+        break
+      }
+    }
+  }
+  return records
+}
+
+// compareIPLookup returns the disagreements between the PTR record and the
+// address records. PTR targets outside zoneNames are not checked, as their
+// address records were not searched.
+func compareIPLookup(result IPLookup, zoneNames []string) (mismatches []string) {
+  hosts := map[string]bool{}
+  for _, record := range result.AddressRecords {
+    hosts[strings.ToLower(strings.TrimSuffix(record.Name, "."))] = true
+  }
+
+  if len(result.PtrRecord) > 0 {
+    if len(result.PtrHostnames) == 0 && len(result.AddressRecords) > 0 {
+      mismatches = append(mismatches, fmt.Sprintf("no PTR record %s", result.PtrRecord))
+    }
+    pointed := false
+    for _, target := range result.PtrHostnames {
+      if hosts[strings.ToLower(strings.TrimSuffix(target, "."))] {
+        pointed = true
+        continue
+      }
+      for _, zoneName := range zoneNames {
+        if InZone(target, zoneName) {
+          mismatches = append(mismatches, fmt.Sprintf("PTR record points at %s, which has no address record with %s", target, result.IP))
+          break
+        }
+      }
+    }
+    if len(result.PtrHostnames) > 0 && len(result.AddressRecords) > 0 && !pointed {
+      mismatches = append(mismatches, "PTR record points at none of the address records")
+    }
+  }
+  return mismatches
+}
+
+// WriteIPLookup writes result to w as text or json.
+func WriteIPLookup(w io.Writer, result IPLookup, format string) (err error) {
+  switch format {
+  case "", "text":
+    var lines []string
+    if len(result.PtrRecord) > 0 {
+      targets := strings.Join(result.PtrHostnames, " ")
+      if len(targets) == 0 {
+        targets = "-"
+      }
+      lines = append(lines, fmt.Sprintf("PTR\t%s\t%s", result.PtrRecord, targets))
+    }
+    for _, record := range result.AddressRecords {
+      line := fmt.Sprintf("%s\t%s\t%s", record.Type, record.Name, record.Zone)
+      if len(record.SetIdentifier) > 0 {
+        line += "\t" + record.SetIdentifier
+      }
+      lines = append(lines, line)
+    }
+    for _, mismatch := range result.Mismatches {
+      lines = append(lines, "MISMATCH\t"+mismatch)
+    }
+    for _, line := range lines {
+      _, err = fmt.Fprintln(w, line)
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  case "json":
+    if result.PtrHostnames == nil {
+      result.PtrHostnames = []string{}
+    }
+    if result.AddressRecords == nil {
+      result.AddressRecords = []IPLookupRecord{}
+    }
+    if result.Mismatches == nil {
+      result.Mismatches = []string{}
+    }
+    return WriteJSON(w, result)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
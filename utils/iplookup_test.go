@@ -0,0 +1,46 @@
+package utils
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestCompareIPLookup(t *testing.T) {
+  web := IPLookupRecord{Zone: "example.com.", Name: "web.example.com.", Type: "A"}
+  db := IPLookupRecord{Zone: "example.com.", Name: "db.example.com.", Type: "A"}
+
+  patterns := []struct{
+    result IPLookup
+    expected []string
+  }{
+    {
+      result: IPLookup{IP: "10.0.1.5", PtrRecord: "5.1.0.10.in-addr.arpa.", PtrHostnames: []string{"web.example.com."}, AddressRecords: []IPLookupRecord{web, db}},
+    },
+    {
+      result: IPLookup{IP: "10.0.1.5", PtrRecord: "5.1.0.10.in-addr.arpa.", AddressRecords: []IPLookupRecord{web}},
+      expected: []string{"no PTR record 5.1.0.10.in-addr.arpa."},
+    },
+    {
+      result: IPLookup{IP: "10.0.1.5", PtrRecord: "5.1.0.10.in-addr.arpa.", PtrHostnames: []string{"old.example.com."}, AddressRecords: []IPLookupRecord{web}},
+      expected: []string{
+        "PTR record points at old.example.com., which has no address record with 10.0.1.5",
+        "PTR record points at none of the address records",
+      },
+    },
+    {
+      result: IPLookup{IP: "10.0.1.5", PtrRecord: "5.1.0.10.in-addr.arpa.", PtrHostnames: []string{"old.example.com."}},
+      expected: []string{"PTR record points at old.example.com., which has no address record with 10.0.1.5"},
+    },
+    {
+      // the address records of example.org. were not searched
+      result: IPLookup{IP: "10.0.1.5", PtrRecord: "5.1.0.10.in-addr.arpa.", PtrHostnames: []string{"www.example.org."}},
+    },
+  }
+
+  for idx, p := range patterns {
+    actual := compareIPLookup(p.result, []string{"example.com."})
+    if strings.Join(actual, "\n") != strings.Join(p.expected, "\n") {
+      t.Errorf("pattern %d: want %q, actual %q", idx, p.expected, actual)
+    }
+  }
+}
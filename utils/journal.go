@@ -0,0 +1,291 @@
+package utils
+
+import (
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "os"
+  "os/user"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+)
+
+// Journal entry and step statuses.
+const (
+  // JournalPending is an entry whose steps are being applied, or whose
+  // command died before it finished.
+  JournalPending = "pending"
+  // JournalApplying is a step which is being submitted. It has a change ID
+  // once Route53 accepted it.
+  JournalApplying = "applying"
+  // JournalRollingBack is a step whose inverse is being submitted. It has a
+  // rollback change ID once Route53 accepted it.
+  JournalRollingBack = "rolling-back"
+  // JournalApplied is an entry whose steps are all applied, or a step
+  // which is applied.
+  JournalApplied = "applied"
+  // JournalRolledBack is an entry which failed and whose applied steps are
+  // rolled back, or a step which is rolled back.
+  JournalRolledBack = "rolled-back"
+  // JournalFailed is an entry which failed and could not be rolled back.
+  JournalFailed = "failed"
+  // JournalUndone is an entry which is reverted by undo, or a step which is
+  // reverted.
+  JournalUndone = "undone"
+)
+
+// DefaultJournalDir returns the directory the journal is written to when
+// --journal-dir is not given. Tests replace it to keep the journal out of
+// the home directory.
+var DefaultJournalDir = func() string {
+  home, err := os.UserHomeDir()
+  if err != nil {
+    return ""
+  }
+  return filepath.Join(home, ".cli-tool-example", "journal")
+}
+
+// JournalEntry records a change plan applied by a command, with the inverse
+// of each step and the Route53 change IDs, so that undo can revert it.
+type JournalEntry struct {
+  ID string
+  Time time.Time
+  User string
+  Command string
+  Status string
+  // UndoOf is the ID of the entry this entry reverts.
+  UndoOf string `json:",omitempty"`
+  Steps []*JournalStep
+
+  path string
+  // mu serializes the updates of steps which are waited for concurrently
+  mu sync.Mutex
+}
+
+// JournalStep is a step of the change plan and its inverse.
+type JournalStep struct {
+  Step *ChangeStep
+  Inverse *ChangeStep
+  Status string
+  ChangeID string `json:",omitempty"`
+  RollbackChangeID string `json:",omitempty"`
+}
+
+// newJournalEntry builds the entry of plan with the inverse of every step.
+// It fails when a step can not be inverted.
+func newJournalEntry(dir string, command string, plan *ChangePlan) (entry *JournalEntry, err error) {
+  entry = &JournalEntry{
+    ID: newJournalID(time.Now()),
+    Time: time.Now().UTC(),
+    User: currentUser(),
+    Command: command,
+    Status: JournalPending,
+  }
+  entry.path = filepath.Join(dir, entry.ID+".json")
+  for _, step := range plan.Steps {
+    inverse, err := step.Inverse()
+    if err != nil {
+      return nil, err
+    }
+    entry.Steps = append(entry.Steps, &JournalStep{Step: step, Inverse: inverse, Status: JournalPending})
+  }
+  return entry, nil
+}
+
+func newJournalID(now time.Time) string {
+  b := make([]byte, 3)
+  _, err := rand.Read(b)
+  if err != nil {
+    return now.UTC().Format("20060102-150405.000000")
+  }
+  return now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+func currentUser() string {
+  u, err := user.Current()
+  if err == nil && len(u.Username) > 0 {
+    return u.Username
+  }
+  return os.Getenv("USER")
+}
+
+// save writes the entry. The file is replaced by a rename, so a crash never
+// leaves a truncated entry behind.
+func (entry *JournalEntry) save() (err error) {
+  if entry == nil {
+    return nil
+  }
+  err = os.MkdirAll(filepath.Dir(entry.path), 0700)
+  if err != nil {
+    return err
+  }
+  b, err := json.MarshalIndent(entry, "", "  ")
+  if err != nil {
+    return err
+  }
+  tmp := entry.path + ".tmp"
+  err = ioutil.WriteFile(tmp, b, 0600)
+  if err != nil {
+    return err
+  }
+  return os.Rename(tmp, entry.path)
+}
+
+// update sets the status of the step at idx, or of the entry when idx is
+// negative, and saves the entry.
+func (entry *JournalEntry) update(idx int, status string) (err error) {
+  if entry == nil {
+    return nil
+  }
+  entry.mu.Lock()
+  defer entry.mu.Unlock()
+  if idx < 0 {
+    entry.Status = status
+  } else {
+    entry.Steps[idx].Status = status
+  }
+  return entry.save()
+}
+
+// setChangeID saves the change ID of the step at idx.
+func (entry *JournalEntry) setChangeID(idx int, changeID string) (err error) {
+  entry.mu.Lock()
+  defer entry.mu.Unlock()
+  entry.Steps[idx].ChangeID = changeID
+  return entry.save()
+}
+
+// AppliedSteps returns the indexes of the steps which are applied.
+func (entry *JournalEntry) AppliedSteps() (idxs []int) {
+  for idx, step := range entry.Steps {
+    if step.Status == JournalApplied {
+      idxs = append(idxs, idx)
+    }
+  }
+  return idxs
+}
+
+// LoadJournalEntry reads the entry id from the journal in dir.
+func LoadJournalEntry(dir string, id string) (entry *JournalEntry, err error) {
+  if len(id) == 0 || strings.ContainsAny(id, `/\`) {
+    return nil, fmt.Errorf("invalid journal id: %s", id)
+  }
+  path := filepath.Join(dir, id+".json")
+  b, err := ioutil.ReadFile(path)
+  if os.IsNotExist(err) {
+    return nil, fmt.Errorf("journal entry not found: %s", id)
+  }
+  if err != nil {
+    return nil, err
+  }
+  entry = &JournalEntry{}
+  err = json.Unmarshal(b, entry)
+  if err != nil {
+    return nil, fmt.Errorf("journal entry %s: %v", id, err)
+  }
+  entry.path = path
+  return entry, nil
+}
+
+// ListJournalEntries reads the entries of the journal in dir, oldest first.
+// A missing directory is an empty journal.
+func ListJournalEntries(dir string) (entries []*JournalEntry, err error) {
+  paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+  if err != nil {
+    return nil, err
+  }
+  for _, path := range paths {
+    entry, err := LoadJournalEntry(dir, strings.TrimSuffix(filepath.Base(path), ".json"))
+    if err != nil {
+      return nil, err
+    }
+    entries = append(entries, entry)
+  }
+  sort.SliceStable(entries, func(i, j int) bool {
+    if entries[i].Time.Equal(entries[j].Time) {
+      return entries[i].ID < entries[j].ID
+    }
+    return entries[i].Time.Before(entries[j].Time)
+  })
+  return entries, nil
+}
+
+// WriteJournalEntries writes one line per entry to w.
+func WriteJournalEntries(w io.Writer, entries []*JournalEntry) (err error) {
+  for _, entry := range entries {
+    line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s", entry.ID, entry.Time.Local().Format(time.RFC3339), entry.User, entry.Status, entry.Command)
+    if len(entry.UndoOf) > 0 {
+      line += " " + entry.UndoOf
+    }
+    _, err = fmt.Fprintln(w, line)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// UndoPlan returns the plan which reverts the applied steps of entry, in
+// reverse order.
+func UndoPlan(entry *JournalEntry) (plan *ChangePlan, err error) {
+  switch entry.Status {
+  case JournalUndone:
+    return nil, fmt.Errorf("journal entry %s is already undone", entry.ID)
+  case JournalRolledBack:
+    return nil, fmt.Errorf("journal entry %s is already rolled back", entry.ID)
+  }
+  if entry.Interrupted() {
+    return nil, fmt.Errorf("journal entry %s is interrupted, resume or abort it first", entry.ID)
+  }
+  plan = &ChangePlan{}
+  applied := entry.AppliedSteps()
+  for i := len(applied) - 1; i >= 0; i-- {
+    inverse := entry.Steps[applied[i]].Inverse
+    if inverse == nil || len(inverse.Changes) == 0 {
+      return nil, fmt.Errorf("journal entry %s: step %d has no inverse", entry.ID, applied[i]+1)
+    }
+    undo := *inverse
+    if len(undo.Rollback) == 0 && entry.Steps[applied[i]].Step != nil {
+      // the inverse of an UPSERT change is only known from the step it
+      // undoes, and the undo is journaled and can be undone in turn
+      undo.Rollback = entry.Steps[applied[i]].Step.Changes
+    }
+    plan.Steps = append(plan.Steps, &undo)
+  }
+  if len(plan.Steps) == 0 {
+    return nil, fmt.Errorf("journal entry %s has no applied changes", entry.ID)
+  }
+  return plan, nil
+}
+
+// Undo applies the inverse of the applied steps of entry and marks it
+// undone. The undo is journaled as well, and can be undone in turn.
+func (client *AWSClientImpl) Undo(entry *JournalEntry) (err error) {
+  plan, err := UndoPlan(entry)
+  if err != nil {
+    return err
+  }
+  if client.dryRun {
+    return PrintChangePlan(client.out, plan, client.planFormat)
+  }
+  client.undoOf = entry.ID
+  defer func() { client.undoOf = "" }()
+  err = client.ApplyChangePlan(plan)
+  if err != nil {
+    return err
+  }
+  return markUndone(entry)
+}
+
+func markUndone(entry *JournalEntry) (err error) {
+  for _, idx := range entry.AppliedSteps() {
+    entry.Steps[idx].Status = JournalUndone
+  }
+  return entry.update(-1, JournalUndone)
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+  "io/ioutil"
+  "net"
+  "os"
+  "strings"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestUndoPlan(t *testing.T) {
+  plan := &ChangePlan{}
+  plan.AddStep("ABC123", "example.com.",
+    newChange(route53.ChangeActionCreate, newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")))
+  plan.AddStep("REV123", "10.in-addr.arpa.",
+    newChange(route53.ChangeActionCreate, newPtrResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")))
+
+  patterns := []struct{
+    status string
+    stepStatuses []string
+    expectedError string
+    expected string
+  }{
+    {
+      status: JournalApplied,
+      stepStatuses: []string{JournalApplied, JournalApplied},
+      expected: "@@ step 1: 10.in-addr.arpa. (REV123) @@\n" +
+        "- 15.1.0.10.in-addr.arpa. 600 PTR www.example.com.\n" +
+        "@@ step 2: example.com. (ABC123) @@\n" +
+        "- www.example.com. 600 A 10.0.1.15\n",
+    },
+    {
+      // the command died before the second step
+      status: JournalPending,
+      stepStatuses: []string{JournalApplied, JournalPending},
+      expectedError: "journal entry ID is interrupted, resume or abort it first",
+    },
+    {
+      // the rollback of the first step failed
+      status: JournalFailed,
+      stepStatuses: []string{JournalApplied, JournalPending},
+      expected: "@@ step 1: example.com. (ABC123) @@\n" +
+        "- www.example.com. 600 A 10.0.1.15\n",
+    },
+    {
+      status: JournalRolledBack,
+      stepStatuses: []string{JournalRolledBack, JournalPending},
+      expectedError: "journal entry ID is already rolled back",
+    },
+    {
+      status: JournalUndone,
+      stepStatuses: []string{JournalUndone, JournalUndone},
+      expectedError: "journal entry ID is already undone",
+    },
+    {
+      status: JournalFailed,
+      stepStatuses: []string{JournalPending, JournalPending},
+      expectedError: "journal entry ID has no applied changes",
+    },
+  }
+
+  for idx, p := range patterns {
+    entry, err := newJournalEntry("", "add", plan)
+    if err != nil {
+      t.Fatalf("unexpected error: %v", err)
+    }
+    entry.ID = "ID"
+    entry.Status = p.status
+    for i, status := range p.stepStatuses {
+      entry.Steps[i].Status = status
+    }
+
+    undo, err := UndoPlan(entry)
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+      continue
+    }
+    var out strings.Builder
+    PrintChangePlan(&out, undo, "text")
+    if out.String() != p.expected {
+      t.Errorf("pattern %d: want %q, actual %q", idx, p.expected, out.String())
+    }
+  }
+}
+
+func TestJournalEntrySaveLoad(t *testing.T) {
+  dir, err := ioutil.TempDir("", "journal")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  plan := &ChangePlan{}
+  step := &ChangeStep{HostedZoneID: "ABC123"}
+  step.AppendChange(route53.ChangeActionUpsert,
+    newAddressResourceRecordSet(net.ParseIP("10.0.1.16"), "www.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com."))
+  plan.AddChangeStep(step)
+
+  entry, err := newJournalEntry(dir, "update", plan)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  err = entry.update(0, JournalApplied)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  loaded, err := LoadJournalEntry(dir, entry.ID)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if loaded.Status != JournalPending || loaded.Steps[0].Status != JournalApplied || loaded.Command != "update" {
+    t.Errorf("unexpected entry: %+v", loaded)
+  }
+  inverse := loaded.Steps[0].Inverse.Changes[0]
+  if FormatResourceRecordSet(inverse.ResourceRecordSet) != "www.example.com. 600 A 10.0.1.15" {
+    t.Errorf("unexpected inverse: %v", inverse)
+  }
+
+  _, err = LoadJournalEntry(dir, "../"+entry.ID)
+  if err == nil {
+    t.Errorf("want error for a path as journal id")
+  }
+  _, err = LoadJournalEntry(dir, "unknown")
+  if err == nil || err.Error() != "journal entry not found: unknown" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+  "fmt"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// RecordSetNotFoundError is returned when no record set matches a lookup.
+type RecordSetNotFoundError struct {
+  Name string
+  Type string
+  SetIdentifier string
+}
+
+func (e *RecordSetNotFoundError) Error() string {
+  msg := fmt.Sprintf("record set not found: %s", e.Name)
+  if len(e.Type) > 0 {
+    msg += fmt.Sprintf(" (type %s)", e.Type)
+  }
+  if len(e.SetIdentifier) > 0 {
+    msg += fmt.Sprintf(" (set-id %s)", e.SetIdentifier)
+  }
+  return msg
+}
+
+// IsRecordSetNotFound reports whether err is a RecordSetNotFoundError.
+func IsRecordSetNotFound(err error) bool {
+  _, ok := err.(*RecordSetNotFoundError)
+  return ok
+}
+
+// FindResourceRecordSets returns the record sets with name in the hosted
+// zone. An empty rrType or setIdentifier matches any type or set
+// identifier. A RecordSetNotFoundError is returned when nothing matches.
+func (client *AWSClientImpl) FindResourceRecordSets(name string, rrType string, setIdentifier string, hostedZoneID string) (rrsets []*route53.ResourceRecordSet, err error) {
+  input := route53.ListResourceRecordSetsInput{
+    HostedZoneId: aws.String(hostedZoneID),
+    StartRecordName: aws.String(name),
+  }
+  if len(rrType) > 0 {
+    input.StartRecordType = aws.String(rrType)
+  }
+
+  var listed []*route53.ResourceRecordSet
+  for done := false; !done; {
+    var resp *route53.ListResourceRecordSetsOutput
+    resp, err = client.r53.ListResourceRecordSetsWithContext(client.ctx, &input)
+    if err != nil {
+      return nil, err
+    }
+    for _, rrset := range resp.ResourceRecordSets {
+      // record sets are sorted by name and then by type
+      if !equalRecordName(aws.StringValue(rrset.Name), name) ||
+        (len(rrType) > 0 && aws.StringValue(rrset.Type) != rrType) {
+        done = true
+        break
+      }
+      listed = append(listed, rrset)
+    }
+
+    if !aws.BoolValue(resp.IsTruncated) {
+      break
+    }
+    input.StartRecordName = resp.NextRecordName
+    input.StartRecordType = resp.NextRecordType
+    input.StartRecordIdentifier = resp.NextRecordIdentifier
+  }
+
+  return FilterResourceRecordSets(listed, name, rrType, setIdentifier)
+}
+
+// FindResourceRecordSet returns the only record set which matches the
+// lookup, or an error when none or several match.
+func (client *AWSClientImpl) FindResourceRecordSet(name string, rrType string, setIdentifier string, hostedZoneID string) (rrset *route53.ResourceRecordSet, err error) {
+  rrsets, err := client.FindResourceRecordSets(name, rrType, setIdentifier, hostedZoneID)
+  if err != nil {
+    return nil, err
+  }
+  return onlyResourceRecordSet(rrsets)
+}
+
+// FilterResourceRecordSets returns the record sets in rrsets with name.
+// An empty rrType or setIdentifier matches any type or set identifier.
+// A RecordSetNotFoundError is returned when nothing matches.
+func FilterResourceRecordSets(rrsets []*route53.ResourceRecordSet, name string, rrType string, setIdentifier string) (matches []*route53.ResourceRecordSet, err error) {
+  for _, rrset := range rrsets {
+    if !equalRecordName(aws.StringValue(rrset.Name), name) {
+      continue
+    }
+    if len(rrType) > 0 && aws.StringValue(rrset.Type) != rrType {
+      continue
+    }
+    if len(setIdentifier) > 0 && aws.StringValue(rrset.SetIdentifier) != setIdentifier {
+      continue
+    }
+    matches = append(matches, rrset)
+  }
+  if len(matches) == 0 {
+    return nil, &RecordSetNotFoundError{Name: name, Type: rrType, SetIdentifier: setIdentifier}
+  }
+  return matches, nil
+}
+
+func onlyResourceRecordSet(rrsets []*route53.ResourceRecordSet) (rrset *route53.ResourceRecordSet, err error) {
+  if len(rrsets) > 1 {
+    var ids []string
+    for _, r := range rrsets {
+      id := aws.StringValue(r.Type)
+      if r.SetIdentifier != nil {
+        id += "/" + aws.StringValue(r.SetIdentifier)
+      }
+      ids = append(ids, id)
+    }
+    return nil, fmt.Errorf("%d record sets match %s (%s), choose one with type or set-id",
+      len(rrsets), aws.StringValue(rrsets[0].Name), strings.Join(ids, ", "))
+  }
+  return rrsets[0], nil
+}
+
+// equalRecordName compares domain names the way Route53 does, ignoring case,
+// the trailing dot and the escaped asterisk of wildcard records.
+func equalRecordName(a string, b string) bool {
+  normalize := func(name string) string {
+    return strings.TrimSuffix(strings.Replace(name, `\052`, "*", -1), ".")
+  }
+  return strings.EqualFold(normalize(a), normalize(b))
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+  "net"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestFilterResourceRecordSets(t *testing.T) {
+  blue := newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")
+  RoutingPolicy{SetIdentifier: "blue", Weight: aws.Int64(100)}.Apply(blue)
+  green := newAddressResourceRecordSet(net.ParseIP("10.0.1.16"), "www.example.com.")
+  RoutingPolicy{SetIdentifier: "green", Weight: aws.Int64(0)}.Apply(green)
+  txt := &route53.ResourceRecordSet{
+    Name: aws.String("www.example.com."),
+    Type: aws.String(route53.RRTypeTxt),
+    TTL: aws.Int64(600),
+    ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(`"hello"`)}},
+  }
+  wildcard := newAddressResourceRecordSet(net.ParseIP("10.0.1.17"), `\052.example.com.`)
+  rrsets := []*route53.ResourceRecordSet{blue, green, txt, wildcard}
+
+  patterns := []struct{
+    name string
+    rrType string
+    setID string
+    expected []*route53.ResourceRecordSet
+    expectedError string
+  }{
+    { "www.example.com.", "A", "green", []*route53.ResourceRecordSet{green}, "" },
+    { "www.example.com.", "", "blue", []*route53.ResourceRecordSet{blue}, "" },
+    { "www.example.com.", "TXT", "", []*route53.ResourceRecordSet{txt}, "" },
+    { "WWW.example.com", "A", "", []*route53.ResourceRecordSet{blue, green}, "" },
+    { "www.example.com.", "", "", []*route53.ResourceRecordSet{blue, green, txt}, "" },
+    { "*.example.com.", "A", "", []*route53.ResourceRecordSet{wildcard}, "" },
+    { "www.example.com.", "CNAME", "", nil, "record set not found: www.example.com. (type CNAME)" },
+    { "www.example.com.", "A", "red", nil, "record set not found: www.example.com. (type A) (set-id red)" },
+    { "mail.example.com.", "", "", nil, "record set not found: mail.example.com." },
+  }
+
+  for idx, p := range patterns {
+    actual, err := FilterResourceRecordSets(rrsets, p.name, p.rrType, p.setID)
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      if !IsRecordSetNotFound(err) {
+        t.Errorf("pattern %d: want RecordSetNotFoundError, actual %T", idx, err)
+      }
+      continue
+    }
+    if len(actual) != len(p.expected) {
+      t.Errorf("pattern %d: want %d record sets, actual %d", idx, len(p.expected), len(actual))
+      continue
+    }
+    for i := range actual {
+      if actual[i] != p.expected[i] {
+        t.Errorf("pattern %d: want %v, actual %v", idx, p.expected[i], actual[i])
+      }
+    }
+  }
+}
+
+func TestFindResourceRecordSet(t *testing.T) {
+  a := newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")
+  txt := &route53.ResourceRecordSet{
+    Name: aws.String("www.example.com."),
+    Type: aws.String(route53.RRTypeTxt),
+    TTL: aws.Int64(600),
+    ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(`"hello"`)}},
+  }
+  next := newAddressResourceRecordSet(net.ParseIP("10.0.1.16"), "www2.example.com.")
+
+  patterns := []struct{
+    rrType string
+    listedRRs []*route53.ResourceRecordSet
+    listInput *route53.ListResourceRecordSetsInput
+    expected *route53.ResourceRecordSet
+    expectedError string
+  }{
+    {
+      rrType: "TXT",
+      listedRRs: []*route53.ResourceRecordSet{txt, next},
+      listInput: &route53.ListResourceRecordSetsInput{
+        HostedZoneId: aws.String("ABC123"),
+        StartRecordName: aws.String("www.example.com."),
+        StartRecordType: aws.String("TXT"),
+      },
+      expected: txt,
+    },
+    {
+      rrType: "",
+      listedRRs: []*route53.ResourceRecordSet{a, txt, next},
+      listInput: &route53.ListResourceRecordSetsInput{
+        HostedZoneId: aws.String("ABC123"),
+        StartRecordName: aws.String("www.example.com."),
+      },
+      expectedError: "2 record sets match www.example.com. (A, TXT), choose one with type or set-id",
+    },
+    {
+      rrType: "A",
+      listedRRs: []*route53.ResourceRecordSet{next},
+      listInput: &route53.ListResourceRecordSetsInput{
+        HostedZoneId: aws.String("ABC123"),
+        StartRecordName: aws.String("www.example.com."),
+        StartRecordType: aws.String("A"),
+      },
+      expectedError: "record set not found: www.example.com. (type A)",
+    },
+    {
+      rrType: "A",
+      listedRRs: []*route53.ResourceRecordSet{},
+      listInput: &route53.ListResourceRecordSetsInput{
+        HostedZoneId: aws.String("ABC123"),
+        StartRecordName: aws.String("www.example.com."),
+        StartRecordType: aws.String("A"),
+      },
+      expectedError: "record set not found: www.example.com. (type A)",
+    },
+  }
+
+  for idx, p := range patterns {
+    awsClient := &AWSClientImpl{
+      r53: &DummyRoute53Client{
+        t: t,
+
+        listResourceRecordSetsInput: p.listInput,
+        listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
+          IsTruncated: aws.Bool(false),
+          ResourceRecordSets: p.listedRRs,
+        },
+      },
+    }
+    actual, err := awsClient.FindResourceRecordSet("www.example.com.", p.rrType, "", "ABC123")
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+    }
+    if actual != p.expected {
+      t.Errorf("pattern %d: want %v, actual %v", idx, p.expected, actual)
+    }
+  }
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+  "fmt"
   "net"
   "strings"
   "strconv"
@@ -9,6 +10,9 @@ import (
 // GenerateReverseRecord ...
 func GenerateReverseRecord(ip net.IP) (reverseRecord string) {
   ipv4 := ip.To4()
+  if ipv4 == nil {
+    return generateIP6ReverseRecord(ip)
+  }
   r1 := []string{
     strconv.Itoa(int(ipv4[3])),
     strconv.Itoa(int(ipv4[2])),
@@ -19,3 +23,229 @@ func GenerateReverseRecord(ip net.IP) (reverseRecord string) {
   reverseRecord = strings.Join([]string{r2, "."}, "")
   return reverseRecord
 }
+
+// generateIP6ReverseRecord returns the nibble format name under ip6.arpa.
+func generateIP6ReverseRecord(ip net.IP) (reverseRecord string) {
+  ipv6 := ip.To16()
+  r1 := make([]string, 0, 34)
+  for i := len(ipv6) - 1; i >= 0; i-- {
+    r1 = append(r1,
+      strconv.FormatInt(int64(ipv6[i]&0x0f), 16),
+      strconv.FormatInt(int64(ipv6[i]>>4), 16))
+  }
+  r1 = append(r1, "ip6", "arpa")
+  r2 := strings.Join(r1[:], ".")
+  reverseRecord = strings.Join([]string{r2, "."}, "")
+  return reverseRecord
+}
+
+// IsIPv6 reports whether ip is an IPv6 address (and not an IPv4-mapped one).
+func IsIPv6(ip net.IP) bool {
+  return ip.To4() == nil && ip.To16() != nil
+}
+
+// AddressRecordType returns the address record type for ip, A or AAAA.
+func AddressRecordType(ip net.IP) string {
+  if IsIPv6(ip) {
+    return "AAAA"
+  }
+  return "A"
+}
+
+// Fqdn returns name as a fully qualified domain name. Names without a
+// trailing dot are relative to zoneName unless they already end with
+// zoneName, and "@" is zoneName itself.
+func Fqdn(name string, zoneName string) string {
+  zoneName = strings.TrimSuffix(zoneName, ".") + "."
+  if name == "@" || len(name) == 0 {
+    return zoneName
+  }
+  if strings.HasSuffix(name, ".") {
+    return name
+  }
+  if InZone(name, zoneName) {
+    return name + "."
+  }
+  return name + "." + zoneName
+}
+
+// InZone reports whether name is zoneName or a name under it.
+func InZone(name string, zoneName string) bool {
+  n := strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+  z := strings.ToLower(strings.TrimSuffix(zoneName, ".")) + "."
+  return n == z || strings.HasSuffix(n, "."+z)
+}
+
+// ReverseZoneNetwork returns the network covered by a reverse zone, for
+// example 10.0.1.0/24 for "1.0.10.in-addr.arpa." and 2001:db8::/32 for
+// "8.b.d.0.1.0.0.2.ip6.arpa.".
+func ReverseZoneNetwork(zoneName string) (network *net.IPNet, err error) {
+  name := strings.ToLower(strings.TrimSuffix(zoneName, "."))
+  switch {
+  case strings.HasSuffix(name, ".in-addr.arpa"):
+    labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+    if len(labels) == 4 && strings.ContainsAny(labels[0], `-/\`) {
+      return classlessZoneNetwork(labels, zoneName)
+    }
+    if len(labels) > 4 {
+      return nil, fmt.Errorf("not a reverse zone of a network: %s", zoneName)
+    }
+    ip := make(net.IP, net.IPv4len)
+    for i, label := range labels {
+      octet, err := strconv.ParseUint(label, 10, 8)
+      if err != nil || label != strconv.FormatUint(octet, 10) {
+        return nil, fmt.Errorf("not a reverse zone of a network: %s", zoneName)
+      }
+      ip[len(labels)-1-i] = byte(octet)
+    }
+    return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(labels), 32)}, nil
+  case strings.HasSuffix(name, ".ip6.arpa"):
+    labels := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+    if len(labels) > 32 {
+      return nil, fmt.Errorf("not a reverse zone of a network: %s", zoneName)
+    }
+    ip := make(net.IP, net.IPv6len)
+    for i, label := range labels {
+      nibble, err := strconv.ParseUint(label, 16, 4)
+      if err != nil || len(label) != 1 {
+        return nil, fmt.Errorf("not a reverse zone of a network: %s", zoneName)
+      }
+      pos := len(labels) - 1 - i
+      if pos%2 == 0 {
+        ip[pos/2] |= byte(nibble) << 4
+      } else {
+        ip[pos/2] |= byte(nibble)
+      }
+    }
+    return &net.IPNet{IP: ip, Mask: net.CIDRMask(4*len(labels), 128)}, nil
+  default:
+    return nil, fmt.Errorf("not a reverse zone: %s", zoneName)
+  }
+}
+
+// ReverseZoneName returns the name of the reverse zone of network, the
+// inverse of ReverseZoneNetwork. IPv4 networks longer than a /24 get the
+// RFC 2317 "<first>-<last>" name, other networks must end on an octet (or a
+// nibble for IPv6) boundary.
+func ReverseZoneName(network *net.IPNet) (zoneName string, err error) {
+  ones, bits := network.Mask.Size()
+  if ipv4 := network.IP.To4(); ipv4 != nil && bits == 32 {
+    if ones > 24 && ones < 32 {
+      size := 1 << uint(32-ones)
+      first := int(ipv4[3])
+      return fmt.Sprintf("%d-%d.%d.%d.%d.in-addr.arpa.", first, first+size-1, ipv4[2], ipv4[1], ipv4[0]), nil
+    }
+    if ones%8 != 0 || ones == 0 {
+      return "", fmt.Errorf("no reverse zone for %s: the prefix length must be a multiple of 8 or longer than 24", network.String())
+    }
+    labels := strings.Split(GenerateReverseRecord(ipv4), ".")
+    return strings.Join(labels[4-ones/8:], "."), nil
+  }
+  if ones%4 != 0 || ones == 0 || bits != 128 {
+    return "", fmt.Errorf("no reverse zone for %s: the prefix length must be a multiple of 4", network.String())
+  }
+  labels := strings.Split(GenerateReverseRecord(network.IP), ".")
+  return strings.Join(labels[32-ones/4:], "."), nil
+}
+
+// IsReverseZone reports whether zoneName is under in-addr.arpa. or
+// ip6.arpa.
+func IsReverseZone(zoneName string) bool {
+  name := strings.ToLower(strings.TrimSuffix(zoneName, ".")) + "."
+  return strings.HasSuffix(name, ".in-addr.arpa.") || strings.HasSuffix(name, ".ip6.arpa.")
+}
+
+// classlessZoneNetwork returns the network of an RFC 2317 classless zone
+// whose first label is "<first>-<last>" or "<first>/<prefix length>", such
+// as "0-63.2.0.192.in-addr.arpa." for 192.0.2.0/26.
+func classlessZoneNetwork(labels []string, zoneName string) (network *net.IPNet, err error) {
+  invalid := fmt.Errorf("not a reverse zone of a network: %s", zoneName)
+  ip := make(net.IP, net.IPv4len)
+  for i, label := range labels[1:] {
+    octet, err := strconv.ParseUint(label, 10, 8)
+    if err != nil || label != strconv.FormatUint(octet, 10) {
+      return nil, invalid
+    }
+    ip[2-i] = byte(octet)
+  }
+
+  var first, size uint64
+  block := strings.Replace(labels[0], `\057`, "/", 1)
+  if parts := strings.SplitN(block, "/", 2); len(parts) == 2 {
+    first, err = strconv.ParseUint(parts[0], 10, 8)
+    if err != nil {
+      return nil, invalid
+    }
+    ones, err := strconv.ParseUint(parts[1], 10, 8)
+    if err != nil || ones < 25 || ones > 32 {
+      return nil, invalid
+    }
+    size = 1 << (32 - ones)
+  } else {
+    parts := strings.SplitN(block, "-", 2)
+    if len(parts) != 2 {
+      return nil, invalid
+    }
+    first, err = strconv.ParseUint(parts[0], 10, 8)
+    if err != nil {
+      return nil, invalid
+    }
+    last, err := strconv.ParseUint(parts[1], 10, 8)
+    if err != nil || last < first {
+      return nil, invalid
+    }
+    size = last - first + 1
+  }
+  // the block must be a network smaller than a /24
+  if size >= 256 || size&(size-1) != 0 || first%size != 0 {
+    return nil, invalid
+  }
+  ip[3] = byte(first)
+  ones := 32
+  for n := size; n > 1; n >>= 1 {
+    ones--
+  }
+  return &net.IPNet{IP: ip, Mask: net.CIDRMask(ones, 32)}, nil
+}
+
+// ParseReverseRecord returns the address of a PTR record name generated by
+// GenerateReverseRecord, or nil when name is not the name of an address.
+func ParseReverseRecord(name string) net.IP {
+  name = strings.ToLower(strings.TrimSuffix(name, "."))
+  switch {
+  case strings.HasSuffix(name, ".in-addr.arpa"):
+    labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+    if len(labels) != 4 {
+      return nil
+    }
+    for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+      labels[i], labels[j] = labels[j], labels[i]
+    }
+    ip := net.ParseIP(strings.Join(labels, "."))
+    if ip == nil || ip.String() != strings.Join(labels, ".") {
+      return nil
+    }
+    return ip
+  case strings.HasSuffix(name, ".ip6.arpa"):
+    labels := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+    if len(labels) != 32 {
+      return nil
+    }
+    ip := make(net.IP, net.IPv6len)
+    for i, label := range labels {
+      nibble, err := strconv.ParseUint(label, 16, 4)
+      if err != nil || len(label) != 1 {
+        return nil
+      }
+      pos := 31 - i
+      if pos%2 == 0 {
+        ip[pos/2] |= byte(nibble) << 4
+      } else {
+        ip[pos/2] |= byte(nibble)
+      }
+    }
+    return ip
+  default:
+    return nil
+  }
+}
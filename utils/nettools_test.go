@@ -12,6 +12,9 @@ func TestGenerateReverseRecord(t *testing.T) {
     expected string
   }{
     { net.IPv4(192, 168, 0, 1), "1.0.168.192.in-addr.arpa." },
+    { net.ParseIP("10.0.5.10"), "10.5.0.10.in-addr.arpa." },
+    { net.ParseIP("2001:db8::567:89ab"), "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa." },
+    { net.ParseIP("::1"), "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa." },
   }
 
   for idx, pattern := range patterns {
@@ -21,3 +24,143 @@ func TestGenerateReverseRecord(t *testing.T) {
     }
   }
 }
+
+func TestAddressRecordType(t *testing.T) {
+  patterns := []struct {
+    ip net.IP
+    expected string
+  }{
+    { net.IPv4(192, 168, 0, 1), "A" },
+    { net.ParseIP("10.0.5.10"), "A" },
+    { net.ParseIP("::ffff:10.0.5.10"), "A" },
+    { net.ParseIP("2001:db8::1"), "AAAA" },
+  }
+
+  for idx, pattern := range patterns {
+    actual := AddressRecordType(pattern.ip)
+    if pattern.expected != actual {
+      t.Errorf("pattern %d: want %s, actual %s", idx, pattern.expected, actual)
+    }
+  }
+}
+
+func TestInZone(t *testing.T) {
+  patterns := []struct {
+    name string
+    zoneName string
+    expected bool
+  }{
+    { "example.com", "example.com.", true },
+    { "www.example.com.", "example.com", true },
+    { "WWW.Example.Com", "example.com.", true },
+    { "www.myexample.com", "example.com", false },
+    { "dualstack.my-elb-123.us-east-1.elb.amazonaws.com.", "example.com", false },
+  }
+
+  for idx, pattern := range patterns {
+    actual := InZone(pattern.name, pattern.zoneName)
+    if pattern.expected != actual {
+      t.Errorf("pattern %d: want %t, actual %t", idx, pattern.expected, actual)
+    }
+  }
+}
+
+func TestReverseZoneNetwork(t *testing.T) {
+  patterns := []struct {
+    zoneName string
+    expected string
+    expectedError string
+  }{
+    { "10.in-addr.arpa.", "10.0.0.0/8", "" },
+    { "1.0.10.in-addr.arpa", "10.0.1.0/24", "" },
+    { "168.192.IN-ADDR.ARPA.", "192.168.0.0/16", "" },
+    { "15.1.0.10.in-addr.arpa.", "10.0.1.15/32", "" },
+    { "8.b.d.0.1.0.0.2.ip6.arpa.", "2001:db8::/32", "" },
+    { "1.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "2001:db8:1::/48", "" },
+    { "0-63.1.0.10.in-addr.arpa.", "10.0.1.0/26", "" },
+    { "128-159.1.0.10.in-addr.arpa.", "10.0.1.128/27", "" },
+    { "64/26.1.0.10.in-addr.arpa.", "10.0.1.64/26", "" },
+    { `64\05726.1.0.10.in-addr.arpa.`, "10.0.1.64/26", "" },
+    { "0-62.1.0.10.in-addr.arpa.", "", "not a reverse zone of a network: 0-62.1.0.10.in-addr.arpa." },
+    { "32-95.1.0.10.in-addr.arpa.", "", "not a reverse zone of a network: 32-95.1.0.10.in-addr.arpa." },
+    { "0-255.1.0.10.in-addr.arpa.", "", "not a reverse zone of a network: 0-255.1.0.10.in-addr.arpa." },
+    { "256.in-addr.arpa.", "", "not a reverse zone of a network: 256.in-addr.arpa." },
+    { "db8.ip6.arpa.", "", "not a reverse zone of a network: db8.ip6.arpa." },
+    { "example.com.", "", "not a reverse zone: example.com." },
+  }
+
+  for idx, pattern := range patterns {
+    actual, err := ReverseZoneNetwork(pattern.zoneName)
+    if err != nil {
+      if err.Error() != pattern.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, pattern.expectedError, err)
+      }
+      continue
+    }
+    if actual.String() != pattern.expected {
+      t.Errorf("pattern %d: want %s, actual %s", idx, pattern.expected, actual.String())
+    }
+  }
+}
+
+func TestReverseZoneName(t *testing.T) {
+  patterns := []struct {
+    network string
+    expected string
+    expectedError string
+  }{
+    { "10.0.0.0/8", "10.in-addr.arpa.", "" },
+    { "10.0.1.0/24", "1.0.10.in-addr.arpa.", "" },
+    { "192.168.0.0/16", "168.192.in-addr.arpa.", "" },
+    { "10.0.1.0/26", "0-63.1.0.10.in-addr.arpa.", "" },
+    { "10.0.1.128/27", "128-159.1.0.10.in-addr.arpa.", "" },
+    { "2001:db8::/32", "8.b.d.0.1.0.0.2.ip6.arpa.", "" },
+    { "2001:db8:1::/48", "1.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "" },
+    { "10.0.0.0/12", "", "no reverse zone for 10.0.0.0/12: the prefix length must be a multiple of 8 or longer than 24" },
+    { "2001:db8::/30", "", "no reverse zone for 2001:db8::/30: the prefix length must be a multiple of 4" },
+  }
+
+  for idx, pattern := range patterns {
+    _, network, err := net.ParseCIDR(pattern.network)
+    if err != nil {
+      t.Fatal(err)
+    }
+    actual, err := ReverseZoneName(network)
+    if err != nil {
+      if err.Error() != pattern.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, pattern.expectedError, err)
+      }
+      continue
+    }
+    if len(pattern.expectedError) > 0 {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, pattern.expectedError, err)
+      continue
+    }
+    if pattern.expected != actual {
+      t.Errorf("pattern %d: want %s, actual %s", idx, pattern.expected, actual)
+    }
+  }
+}
+
+func TestParseReverseRecord(t *testing.T) {
+  patterns := []struct {
+    name string
+    expected net.IP
+  }{
+    { "1.0.168.192.in-addr.arpa.", net.ParseIP("192.168.0.1") },
+    { "10.5.0.10.IN-ADDR.ARPA", net.ParseIP("10.0.5.10") },
+    { "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", net.ParseIP("2001:db8::567:89ab") },
+    { "5.0.168.192.in-addr.arpa.", net.ParseIP("192.168.0.5") },
+    { "0.168.192.in-addr.arpa.", nil },
+    { "01.0.168.192.in-addr.arpa.", nil },
+    { "5.0-63.2.0.192.in-addr.arpa.", nil },
+    { "www.example.com.", nil },
+  }
+
+  for idx, pattern := range patterns {
+    actual := ParseReverseRecord(pattern.name)
+    if !pattern.expected.Equal(actual) || (pattern.expected == nil) != (actual == nil) {
+      t.Errorf("pattern %d: want %v, actual %v", idx, pattern.expected, actual)
+    }
+  }
+}
@@ -0,0 +1,272 @@
+package utils
+
+import (
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+
+  "gopkg.in/yaml.v2"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// OutputFormats are the formats accepted by WriteResourceRecordSets.
+var OutputFormats = []string{"text", "json", "yaml", "csv", "tsv", "table"}
+
+// RecordSetView is the serializable form of route53.ResourceRecordSet.
+type RecordSetView struct {
+  Name string `json:"name" yaml:"name"`
+  Type string `json:"type" yaml:"type"`
+  TTL *int64 `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+  Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+  AliasTarget *AliasTargetView `json:"aliasTarget,omitempty" yaml:"aliasTarget,omitempty"`
+  SetIdentifier string `json:"setIdentifier,omitempty" yaml:"setIdentifier,omitempty"`
+  Weight *int64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+  Region string `json:"region,omitempty" yaml:"region,omitempty"`
+  Failover string `json:"failover,omitempty" yaml:"failover,omitempty"`
+  GeoLocation *GeoLocationView `json:"geoLocation,omitempty" yaml:"geoLocation,omitempty"`
+  MultiValueAnswer *bool `json:"multiValueAnswer,omitempty" yaml:"multiValueAnswer,omitempty"`
+  HealthCheckID string `json:"healthCheckId,omitempty" yaml:"healthCheckId,omitempty"`
+  TrafficPolicyInstanceID string `json:"trafficPolicyInstanceId,omitempty" yaml:"trafficPolicyInstanceId,omitempty"`
+}
+
+// AliasTargetView ...
+type AliasTargetView struct {
+  DNSName string `json:"dnsName" yaml:"dnsName"`
+  HostedZoneID string `json:"hostedZoneId" yaml:"hostedZoneId"`
+  EvaluateTargetHealth bool `json:"evaluateTargetHealth" yaml:"evaluateTargetHealth"`
+}
+
+// GeoLocationView ...
+type GeoLocationView struct {
+  ContinentCode string `json:"continentCode,omitempty" yaml:"continentCode,omitempty"`
+  CountryCode string `json:"countryCode,omitempty" yaml:"countryCode,omitempty"`
+  SubdivisionCode string `json:"subdivisionCode,omitempty" yaml:"subdivisionCode,omitempty"`
+}
+
+// recordSetColumns is the header of the csv, tsv and table formats.
+var recordSetColumns = []string{
+  "name", "type", "ttl", "values",
+  "alias_dns_name", "alias_hosted_zone_id", "alias_evaluate_target_health",
+  "set_identifier", "weight", "region", "failover",
+  "geo_continent_code", "geo_country_code", "geo_subdivision_code",
+  "multi_value_answer", "health_check_id", "traffic_policy_instance_id",
+}
+
+// NewRecordSetView ...
+func NewRecordSetView(rrset *route53.ResourceRecordSet) RecordSetView {
+  view := RecordSetView{
+    Name: aws.StringValue(rrset.Name),
+    Type: aws.StringValue(rrset.Type),
+    TTL: rrset.TTL,
+    SetIdentifier: aws.StringValue(rrset.SetIdentifier),
+    Weight: rrset.Weight,
+    Region: aws.StringValue(rrset.Region),
+    Failover: aws.StringValue(rrset.Failover),
+    MultiValueAnswer: rrset.MultiValueAnswer,
+    HealthCheckID: aws.StringValue(rrset.HealthCheckId),
+    TrafficPolicyInstanceID: aws.StringValue(rrset.TrafficPolicyInstanceId),
+  }
+  for _, rr := range rrset.ResourceRecords {
+    view.Values = append(view.Values, aws.StringValue(rr.Value))
+  }
+  if rrset.AliasTarget != nil {
+    view.AliasTarget = &AliasTargetView{
+      DNSName: aws.StringValue(rrset.AliasTarget.DNSName),
+      HostedZoneID: aws.StringValue(rrset.AliasTarget.HostedZoneId),
+      EvaluateTargetHealth: aws.BoolValue(rrset.AliasTarget.EvaluateTargetHealth),
+    }
+  }
+  if rrset.GeoLocation != nil {
+    view.GeoLocation = &GeoLocationView{
+      ContinentCode: aws.StringValue(rrset.GeoLocation.ContinentCode),
+      CountryCode: aws.StringValue(rrset.GeoLocation.CountryCode),
+      SubdivisionCode: aws.StringValue(rrset.GeoLocation.SubdivisionCode),
+    }
+  }
+  return view
+}
+
+func (view RecordSetView) columns() []string {
+  row := make([]string, len(recordSetColumns))
+  row[0] = view.Name
+  row[1] = view.Type
+  if view.TTL != nil {
+    row[2] = strconv.FormatInt(*view.TTL, 10)
+  }
+  row[3] = strings.Join(view.Values, " ")
+  if view.AliasTarget != nil {
+    row[4] = view.AliasTarget.DNSName
+    row[5] = view.AliasTarget.HostedZoneID
+    row[6] = strconv.FormatBool(view.AliasTarget.EvaluateTargetHealth)
+  }
+  row[7] = view.SetIdentifier
+  if view.Weight != nil {
+    row[8] = strconv.FormatInt(*view.Weight, 10)
+  }
+  row[9] = view.Region
+  row[10] = view.Failover
+  if view.GeoLocation != nil {
+    row[11] = view.GeoLocation.ContinentCode
+    row[12] = view.GeoLocation.CountryCode
+    row[13] = view.GeoLocation.SubdivisionCode
+  }
+  if view.MultiValueAnswer != nil {
+    row[14] = strconv.FormatBool(*view.MultiValueAnswer)
+  }
+  row[15] = view.HealthCheckID
+  row[16] = view.TrafficPolicyInstanceID
+  return row
+}
+
+// WriteResourceRecordSets writes rrsets to w in the given format.
+func WriteResourceRecordSets(w io.Writer, rrsets []*route53.ResourceRecordSet, format string) (err error) {
+  views := make([]RecordSetView, 0, len(rrsets))
+  for _, rrset := range rrsets {
+    views = append(views, NewRecordSetView(rrset))
+  }
+
+  switch format {
+  case "", "text":
+    for _, rrset := range rrsets {
+      _, err = fmt.Fprintf(w, "%s\t%s", aws.StringValue(rrset.Type), aws.StringValue(rrset.Name))
+      if err != nil {
+        return err
+      }
+      if rrset.AliasTarget != nil {
+        _, err = fmt.Fprintf(w, "\tALIAS\t%s\t%s", aws.StringValue(rrset.AliasTarget.DNSName), aws.StringValue(rrset.AliasTarget.HostedZoneId))
+        if err != nil {
+          return err
+        }
+      }
+      for _, rr := range rrset.ResourceRecords {
+        _, err = fmt.Fprintf(w, "\t%s", aws.StringValue(rr.Value))
+        if err != nil {
+          return err
+        }
+      }
+      _, err = fmt.Fprintf(w, "\n")
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  case "json":
+    return WriteJSON(w, views)
+  case "yaml":
+    return WriteYAML(w, views)
+  case "csv":
+    cw := csv.NewWriter(w)
+    err = cw.Write(recordSetColumns)
+    if err != nil {
+      return err
+    }
+    for _, view := range views {
+      err = cw.Write(view.columns())
+      if err != nil {
+        return err
+      }
+    }
+    cw.Flush()
+    return cw.Error()
+  case "tsv":
+    return writeTSV(w, views)
+  case "table":
+    return writeTable(w, views)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
+
+// tsvEscaper keeps a value on its own field and line of the tsv format.
+// Values are written as they are otherwise, quotes included.
+var tsvEscaper = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+// writeTSV writes views as tab separated values with a header.
+func writeTSV(w io.Writer, views []RecordSetView) (err error) {
+  _, err = fmt.Fprintln(w, strings.Join(recordSetColumns, "\t"))
+  if err != nil {
+    return err
+  }
+  for _, view := range views {
+    row := view.columns()
+    for i := range row {
+      row[i] = tsvEscaper.Replace(row[i])
+    }
+    _, err = fmt.Fprintln(w, strings.Join(row, "\t"))
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// writeTable writes views as aligned columns under an upper case header.
+// The columns after the values are left out when no record set has them.
+func writeTable(w io.Writer, views []RecordSetView) (err error) {
+  rows := make([][]string, 0, len(views))
+  used := make([]bool, len(recordSetColumns))
+  for i := 0; i < 4; i++ {
+    used[i] = true
+  }
+  for _, view := range views {
+    row := view.columns()
+    for i, column := range row {
+      row[i] = tsvEscaper.Replace(column)
+      if len(column) > 0 {
+        used[i] = true
+      }
+    }
+    rows = append(rows, row)
+  }
+
+  tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+  header := []string{}
+  for i, column := range recordSetColumns {
+    if used[i] {
+      header = append(header, strings.ToUpper(column))
+    }
+  }
+  _, err = fmt.Fprintln(tw, strings.Join(header, "\t"))
+  if err != nil {
+    return err
+  }
+  for _, row := range rows {
+    cells := []string{}
+    for i, cell := range row {
+      if used[i] {
+        cells = append(cells, cell)
+      }
+    }
+    _, err = fmt.Fprintln(tw, strings.Join(cells, "\t"))
+    if err != nil {
+      return err
+    }
+  }
+  return tw.Flush()
+}
+
+// WriteJSON writes v to w as indented JSON.
+func WriteJSON(w io.Writer, v interface{}) (err error) {
+  b, err := json.MarshalIndent(v, "", "  ")
+  if err != nil {
+    return err
+  }
+  _, err = fmt.Fprintln(w, string(b))
+  return err
+}
+
+// WriteYAML writes v to w as YAML.
+func WriteYAML(w io.Writer, v interface{}) (err error) {
+  b, err := yaml.Marshal(v)
+  if err != nil {
+    return err
+  }
+  _, err = w.Write(b)
+  return err
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+  "bytes"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestWriteResourceRecordSets(t *testing.T) {
+  rrsets := []*route53.ResourceRecordSet{
+    {
+      Name: aws.String("www.example.com."),
+      ResourceRecords: []*route53.ResourceRecord{
+        {
+          Value: aws.String("10.0.1.15"),
+        },
+        {
+          Value: aws.String("10.0.1.16"),
+        },
+      },
+      TTL: aws.Int64(600),
+      Type: aws.String(route53.RRTypeA),
+    },
+    {
+      Name: aws.String("example.com."),
+      Type: aws.String(route53.RRTypeA),
+      AliasTarget: &route53.AliasTarget{
+        DNSName: aws.String("d111111abcdef8.cloudfront.net."),
+        HostedZoneId: aws.String("Z2FDTNDATAQYW2"),
+        EvaluateTargetHealth: aws.Bool(false),
+      },
+      SetIdentifier: aws.String("blue"),
+      Weight: aws.Int64(10),
+    },
+  }
+
+  patterns := []struct{
+    format string
+    expected string
+    expectedError string
+  }{
+    {
+      format: "text",
+      expected: "A\twww.example.com.\t10.0.1.15\t10.0.1.16\n" +
+        "A\texample.com.\tALIAS\td111111abcdef8.cloudfront.net.\tZ2FDTNDATAQYW2\n",
+    },
+    {
+      format: "csv",
+      expected: "name,type,ttl,values,alias_dns_name,alias_hosted_zone_id,alias_evaluate_target_health,set_identifier,weight,region,failover,geo_continent_code,geo_country_code,geo_subdivision_code,multi_value_answer,health_check_id,traffic_policy_instance_id\n" +
+        "www.example.com.,A,600,10.0.1.15 10.0.1.16,,,,,,,,,,,,,\n" +
+        "example.com.,A,,,d111111abcdef8.cloudfront.net.,Z2FDTNDATAQYW2,false,blue,10,,,,,,,,\n",
+    },
+    {
+      format: "json",
+      expected: `[
+  {
+    "name": "www.example.com.",
+    "type": "A",
+    "ttl": 600,
+    "values": [
+      "10.0.1.15",
+      "10.0.1.16"
+    ]
+  },
+  {
+    "name": "example.com.",
+    "type": "A",
+    "aliasTarget": {
+      "dnsName": "d111111abcdef8.cloudfront.net.",
+      "hostedZoneId": "Z2FDTNDATAQYW2",
+      "evaluateTargetHealth": false
+    },
+    "setIdentifier": "blue",
+    "weight": 10
+  }
+]
+`,
+    },
+    {
+      format: "yaml",
+      expected: `- name: www.example.com.
+  type: A
+  ttl: 600
+  values:
+  - 10.0.1.15
+  - 10.0.1.16
+- name: example.com.
+  type: A
+  aliasTarget:
+    dnsName: d111111abcdef8.cloudfront.net.
+    hostedZoneId: Z2FDTNDATAQYW2
+    evaluateTargetHealth: false
+  setIdentifier: blue
+  weight: 10
+`,
+    },
+    {
+      format: "xml",
+      expectedError: "unknown output format: xml",
+    },
+  }
+
+  for idx, p := range patterns {
+    var out bytes.Buffer
+    err := WriteResourceRecordSets(&out, rrsets, p.format)
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if out.String() != p.expected {
+      t.Errorf("unexpected output (%d): expected\n%s\nactual\n%s", idx, p.expected, out.String())
+    }
+  }
+}
+
+func TestWriteResourceRecordSetsTable(t *testing.T) {
+  rrsets := []*route53.ResourceRecordSet{
+    {
+      Name: aws.String("example.com."),
+      ResourceRecords: []*route53.ResourceRecord{
+        {
+          Value: aws.String(`"v=spf1 -all"`),
+        },
+      },
+      TTL: aws.Int64(300),
+      Type: aws.String(route53.RRTypeTxt),
+    },
+    {
+      Name: aws.String("www.example.com."),
+      ResourceRecords: []*route53.ResourceRecord{
+        {
+          Value: aws.String("10.0.1.15"),
+        },
+      },
+      TTL: aws.Int64(60),
+      Type: aws.String(route53.RRTypeA),
+      SetIdentifier: aws.String("blue"),
+      Weight: aws.Int64(10),
+    },
+  }
+
+  patterns := []struct{
+    format string
+    expected string
+  }{
+    {
+      format: "tsv",
+      expected: "name\ttype\tttl\tvalues\talias_dns_name\talias_hosted_zone_id\talias_evaluate_target_health\tset_identifier\tweight\tregion\tfailover\tgeo_continent_code\tgeo_country_code\tgeo_subdivision_code\tmulti_value_answer\thealth_check_id\ttraffic_policy_instance_id\n" +
+        "example.com.\tTXT\t300\t\"v=spf1 -all\"\t\t\t\t\t\t\t\t\t\t\t\t\t\n" +
+        "www.example.com.\tA\t60\t10.0.1.15\t\t\t\tblue\t10\t\t\t\t\t\t\t\t\n",
+    },
+    {
+      format: "table",
+      expected: "NAME              TYPE  TTL  VALUES         SET_IDENTIFIER  WEIGHT\n" +
+        "example.com.      TXT   300  \"v=spf1 -all\"                  \n" +
+        "www.example.com.  A     60   10.0.1.15      blue            10\n",
+    },
+  }
+
+  outputs := map[string]string{}
+  for idx, p := range patterns {
+    var out bytes.Buffer
+    err := WriteResourceRecordSets(&out, rrsets, p.format)
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+      continue
+    }
+    if out.String() != p.expected {
+      t.Errorf("unexpected output (%d): expected\n%q\nactual\n%q", idx, p.expected, out.String())
+    }
+    outputs[p.format] = out.String()
+  }
+  if outputs["tsv"] == outputs["table"] {
+    t.Errorf("table is the same as tsv: %q", outputs["table"])
+  }
+}
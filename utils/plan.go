@@ -0,0 +1,378 @@
+package utils
+
+import (
+  "fmt"
+  "io"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// ChangeStep is a single change batch submitted to one hosted zone.
+type ChangeStep struct {
+  HostedZoneID string `json:"HostedZoneId"`
+  HostedZoneName string `json:",omitempty"`
+  Changes []*route53.Change
+  // Rollback holds the changes which revert Changes. It is required when
+  // the step contains UPSERT changes, which can not be inverted without
+  // knowing the previous record set.
+  Rollback []*route53.Change `json:",omitempty"`
+}
+
+// ChangePlan is the ordered list of change batches a command applies.
+// The steps are applied one by one, and the applied steps are rolled back
+// in reverse order when a later step fails.
+type ChangePlan struct {
+  Steps []*ChangeStep
+}
+
+// AddStep appends a change batch for hostedZoneID to the plan.
+func (plan *ChangePlan) AddStep(hostedZoneID string, hostedZoneName string, changes ...*route53.Change) {
+  plan.Steps = append(plan.Steps, &ChangeStep{
+    HostedZoneID: hostedZoneID,
+    HostedZoneName: hostedZoneName,
+    Changes: changes,
+  })
+}
+
+// AddChangeStep appends step to the plan unless it has no changes.
+func (plan *ChangePlan) AddChangeStep(step *ChangeStep) {
+  if len(step.Changes) > 0 {
+    plan.Steps = append(plan.Steps, step)
+  }
+}
+
+// Input builds the ChangeResourceRecordSets request for the step.
+func (step *ChangeStep) Input() *route53.ChangeResourceRecordSetsInput {
+  return &route53.ChangeResourceRecordSetsInput{
+    HostedZoneId: aws.String(step.HostedZoneID),
+    ChangeBatch: &route53.ChangeBatch{
+      Changes: step.Changes,
+    },
+  }
+}
+
+// AppendChange appends a change to the step and records its inverse in
+// Rollback. previous is the record set replaced by an UPSERT change, or nil
+// when the UPSERT creates a new record set.
+func (step *ChangeStep) AppendChange(action string, rrset *route53.ResourceRecordSet, previous *route53.ResourceRecordSet) {
+  var inverse *route53.Change
+  switch action {
+  case route53.ChangeActionCreate:
+    inverse = newChange(route53.ChangeActionDelete, rrset)
+  case route53.ChangeActionDelete:
+    inverse = newChange(route53.ChangeActionCreate, rrset)
+  default:
+    if previous != nil {
+      inverse = newChange(route53.ChangeActionUpsert, previous)
+    } else {
+      inverse = newChange(route53.ChangeActionDelete, rrset)
+    }
+  }
+  step.Changes = append(step.Changes, newChange(action, rrset))
+  step.Rollback = append([]*route53.Change{inverse}, step.Rollback...)
+}
+
+// Inverse returns the step which reverts the step.
+func (step *ChangeStep) Inverse() (inverse *ChangeStep, err error) {
+  inverse = &ChangeStep{
+    HostedZoneID: step.HostedZoneID,
+    HostedZoneName: step.HostedZoneName,
+  }
+  if len(step.Rollback) > 0 {
+    inverse.Changes = step.Rollback
+    return inverse, nil
+  }
+  for i := len(step.Changes) - 1; i >= 0; i-- {
+    change := step.Changes[i]
+    var action string
+    switch aws.StringValue(change.Action) {
+    case route53.ChangeActionCreate:
+      action = route53.ChangeActionDelete
+    case route53.ChangeActionDelete:
+      action = route53.ChangeActionCreate
+    default:
+      return nil, fmt.Errorf("can not invert %s change", aws.StringValue(change.Action))
+    }
+    inverse.Changes = append(inverse.Changes, newChange(action, change.ResourceRecordSet))
+  }
+  return inverse, nil
+}
+
+func newChange(action string, rrset *route53.ResourceRecordSet) *route53.Change {
+  return &route53.Change{
+    Action: aws.String(action),
+    ResourceRecordSet: rrset,
+  }
+}
+
+// ApplyChangePlan applies the steps of plan in order. When a step fails, the
+// steps which are already applied are rolled back in reverse order.
+// Steps over the limits of a change batch are split first. In dry-run mode
+// the plan is printed instead. When the client has a journal, the plan is
+// written to it before anything is applied.
+func (client *AWSClientImpl) ApplyChangePlan(plan *ChangePlan) (err error) {
+  plan, err = ChunkChangePlan(plan)
+  if err != nil {
+    return err
+  }
+  if client.dryRun {
+    return PrintChangePlan(client.out, plan, client.planFormat)
+  }
+
+  entry, err := client.beginJournal(plan)
+  if err != nil {
+    return err
+  }
+  return client.applyChangeSteps(plan.Steps, entry)
+}
+
+// applyChangeSteps applies the steps which are not applied yet according to
+// entry, which may be nil, and rolls the applied ones back on failure.
+func (client *AWSClientImpl) applyChangeSteps(steps []*ChangeStep, entry *JournalEntry) (err error) {
+  for idx, step := range steps {
+    if entry != nil && entry.Steps[idx].Status == JournalApplied {
+      continue
+    }
+    var changeID string
+    changeID, err = client.submitChangeStep(idx, step, entry)
+    if err != nil {
+      if client.ctx.Err() == nil && IsAmbiguousError(err) {
+        applied, settleErr := client.settleChangeStep(idx, step, entry)
+        if settleErr != nil {
+          // the step is left applying for resume or abort to settle
+          return fmt.Errorf("%v (step %d may have been applied: %v)", err, idx+1, settleErr)
+        }
+        if applied {
+          return client.rollbackOnError(steps[:idx+1], entry, err)
+        }
+      }
+      return client.rollbackOnError(steps[:idx], entry, err)
+    }
+    err = client.waitChangeStep(idx, changeID, entry)
+    if err != nil {
+      // the batch was accepted, only waiting for it failed
+      return client.rollbackOnError(steps[:idx+1], entry, err)
+    }
+  }
+  return entry.update(-1, JournalApplied)
+}
+
+// rollbackOnError rolls applied back after err, and marks entry rolled back
+// or failed.
+func (client *AWSClientImpl) rollbackOnError(applied []*ChangeStep, entry *JournalEntry, err error) error {
+  rollbackErr := client.rollbackChangeSteps(applied, entry)
+  if rollbackErr != nil {
+    entry.update(-1, JournalFailed)
+    return fmt.Errorf("%v (rollback failed: %v)", err, rollbackErr)
+  }
+  entry.update(-1, JournalRolledBack)
+  return err
+}
+
+// submitChangeStep submits the step and returns its change ID. The step is
+// marked applying in the journal before it is submitted, and its change ID
+// is saved as soon as Route53 accepts it, so that an interrupted step can be
+// settled later.
+func (client *AWSClientImpl) submitChangeStep(idx int, step *ChangeStep, entry *JournalEntry) (changeID string, err error) {
+  err = entry.update(idx, JournalApplying)
+  if err != nil {
+    return "", fmt.Errorf("journal %s: %v", entry.ID, err)
+  }
+  resp, err := client.r53.ChangeResourceRecordSetsWithContext(client.ctx, step.Input())
+  if err != nil {
+    if client.ctx.Err() != nil {
+      // the request may have reached Route53, so the step is left applying
+      // for resume or abort to settle
+      return "", canceledError(client.ctx, err)
+    }
+    if IsAmbiguousError(err) {
+      // the batch may have been committed before the response failed, the
+      // step is left applying for settleChangeStep
+      return "", err
+    }
+    entry.update(idx, JournalPending)
+    return "", err
+  }
+  changeID = aws.StringValue(resp.ChangeInfo.Id)
+  if entry != nil {
+    entry.setChangeID(idx, changeID)
+  }
+  return changeID, nil
+}
+
+// settleChangeStep finds out from the record sets whether a step whose
+// submission failed with an ambiguous error was committed, and marks it
+// applied or pending.
+func (client *AWSClientImpl) settleChangeStep(idx int, step *ChangeStep, entry *JournalEntry) (applied bool, err error) {
+  applied, err = client.changeStepApplied(step)
+  if err != nil {
+    return false, err
+  }
+  status := JournalPending
+  if applied {
+    status = JournalApplied
+  }
+  err = entry.update(idx, status)
+  if err != nil {
+    return applied, fmt.Errorf("journal %s: %v", entry.ID, err)
+  }
+  return applied, nil
+}
+
+// waitChangeStep waits until the change of the submitted step is in sync
+// and marks the step applied. With --no-wait the change ID is printed
+// instead, as a change batch accepted by Route53 is applied as a whole.
+func (client *AWSClientImpl) waitChangeStep(idx int, changeID string, entry *JournalEntry) (err error) {
+  err = client.waitChange(changeID)
+  if err != nil {
+    return err
+  }
+  err = entry.update(idx, JournalApplied)
+  if err != nil {
+    return fmt.Errorf("journal %s: %v", entry.ID, err)
+  }
+  return nil
+}
+
+// waitChange waits until the change is in sync, or prints its ID with
+// --no-wait.
+func (client *AWSClientImpl) waitChange(changeID string) (err error) {
+  if client.noWait {
+    _, err = fmt.Fprintln(client.out, changeID)
+  } else {
+    err = client.r53.WaitUntilResourceRecordSetsChangedWithContext(client.ctx, &route53.GetChangeInput{Id: aws.String(changeID)})
+  }
+  if err != nil {
+    return canceledError(client.ctx, err)
+  }
+  return nil
+}
+
+// rollbackChangeSteps applies the inverse of steps in reverse order. With a
+// journal, only the steps marked applied are rolled back, and each one is
+// marked rolling back before its inverse is submitted. The rollback is not
+// bound to the context of the client, since it usually runs because that
+// context is cancelled.
+func (client *AWSClientImpl) rollbackChangeSteps(steps []*ChangeStep, entry *JournalEntry) (err error) {
+  ctx, cancel := client.rollbackContext()
+  defer cancel()
+  for i := len(steps) - 1; i >= 0; i-- {
+    if entry != nil && entry.Steps[i].Status != JournalApplied && entry.Steps[i].Status != JournalApplying {
+      continue
+    }
+    inverse, err := steps[i].Inverse()
+    if err != nil {
+      return err
+    }
+    err = entry.update(i, JournalRollingBack)
+    if err != nil {
+      return fmt.Errorf("journal %s: %v", entry.ID, err)
+    }
+    resp, err := client.r53.ChangeResourceRecordSetsWithContext(ctx, inverse.Input())
+    if err != nil {
+      entry.update(i, JournalApplied)
+      return canceledError(ctx, err)
+    }
+    if entry != nil {
+      entry.Steps[i].RollbackChangeID = aws.StringValue(resp.ChangeInfo.Id)
+      entry.update(i, JournalRollingBack)
+    }
+    err = client.r53.WaitUntilResourceRecordSetsChangedWithContext(ctx, &route53.GetChangeInput{Id: resp.ChangeInfo.Id})
+    if err != nil {
+      return canceledError(ctx, err)
+    }
+    entry.update(i, JournalRolledBack)
+  }
+  return nil
+}
+
+// beginJournal writes the journal entry of plan, or returns nil when the
+// client has no journal.
+func (client *AWSClientImpl) beginJournal(plan *ChangePlan) (entry *JournalEntry, err error) {
+  if len(client.journalDir) == 0 || len(plan.Steps) == 0 {
+    return nil, nil
+  }
+  entry, err = newJournalEntry(client.journalDir, client.command, plan)
+  if err != nil {
+    return nil, err
+  }
+  entry.UndoOf = client.undoOf
+  err = entry.save()
+  if err != nil {
+    return nil, fmt.Errorf("journal: %v", err)
+  }
+  return entry, nil
+}
+
+// PrintChangePlan writes plan to w in the given format ("text" or "json").
+func PrintChangePlan(w io.Writer, plan *ChangePlan, format string) (err error) {
+  switch format {
+  case "", "text":
+    return printChangePlanText(w, plan)
+  case "json":
+    return WriteJSON(w, plan)
+  default:
+    return fmt.Errorf("unknown plan format: %s", format)
+  }
+}
+
+func printChangePlanText(w io.Writer, plan *ChangePlan) (err error) {
+  if len(plan.Steps) == 0 {
+    _, err = fmt.Fprintln(w, "no changes")
+    return err
+  }
+  for idx, step := range plan.Steps {
+    zone := step.HostedZoneID
+    if len(step.HostedZoneName) > 0 {
+      zone = fmt.Sprintf("%s (%s)", step.HostedZoneName, step.HostedZoneID)
+    }
+    _, err = fmt.Fprintf(w, "@@ step %d: %s @@\n", idx+1, zone)
+    if err != nil {
+      return err
+    }
+    for _, change := range step.Changes {
+      _, err = fmt.Fprintf(w, "%s %s\n", changeMarker(aws.StringValue(change.Action)), FormatResourceRecordSet(change.ResourceRecordSet))
+      if err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
+
+func changeMarker(action string) string {
+  switch action {
+  case route53.ChangeActionCreate:
+    return "+"
+  case route53.ChangeActionDelete:
+    return "-"
+  default:
+    return "~"
+  }
+}
+
+// FormatResourceRecordSet formats rrset as a single line
+// "name TTL type value...". Alias records are formatted as
+// "name ALIAS type target zone-id".
+func FormatResourceRecordSet(rrset *route53.ResourceRecordSet) string {
+  if rrset.AliasTarget != nil {
+    return strings.Join([]string{
+      aws.StringValue(rrset.Name),
+      "ALIAS",
+      aws.StringValue(rrset.Type),
+      aws.StringValue(rrset.AliasTarget.DNSName),
+      aws.StringValue(rrset.AliasTarget.HostedZoneId),
+    }, " ")
+  }
+  fields := []string{
+    aws.StringValue(rrset.Name),
+    fmt.Sprintf("%d", aws.Int64Value(rrset.TTL)),
+    aws.StringValue(rrset.Type),
+  }
+  for _, rr := range rrset.ResourceRecords {
+    fields = append(fields, aws.StringValue(rr.Value))
+  }
+  return strings.Join(fields, " ")
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+  "bytes"
+  "errors"
+  "net"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestChangeStepInverse(t *testing.T) {
+  a := newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")
+  ptr := newPtrResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")
+
+  patterns := []struct{
+    step *ChangeStep
+
+    expected *ChangeStep
+    expectedError error
+  }{
+    {
+      step: &ChangeStep{
+        HostedZoneID: "ABC123",
+        Changes: []*route53.Change{
+          newChange(route53.ChangeActionCreate, a),
+          newChange(route53.ChangeActionDelete, ptr),
+        },
+      },
+      expected: &ChangeStep{
+        HostedZoneID: "ABC123",
+        Changes: []*route53.Change{
+          newChange(route53.ChangeActionCreate, ptr),
+          newChange(route53.ChangeActionDelete, a),
+        },
+      },
+      expectedError: nil,
+    },
+    {
+      step: &ChangeStep{
+        HostedZoneID: "ABC123",
+        Changes: []*route53.Change{
+          newChange(route53.ChangeActionUpsert, a),
+        },
+      },
+      expected: nil,
+      expectedError: errors.New("can not invert UPSERT change"),
+    },
+  }
+
+  for idx, p := range patterns {
+    actual, err := p.step.Inverse()
+    if err != nil && (p.expectedError == nil || err.Error() != p.expectedError.Error()) {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+    }
+    if p.expected == nil {
+      if actual != nil {
+        t.Errorf("unexpected inverse (%d): expected nil, actual %v", idx, actual)
+      }
+    } else if awsutil.StringValue(actual) != awsutil.StringValue(p.expected) {
+      t.Errorf("unexpected inverse (%d): expected %v, actual %v", idx, p.expected, actual)
+    }
+  }
+}
+
+func TestPlanAddAResourceRecordSet(t *testing.T) {
+  rInfos := ReverseHostedZoneInfos{
+    ReverseHostedZoneInfo: []ReverseHostedZoneInfo{
+      {
+        Network: &net.IPNet{
+          IP: net.IPv4(10,0,0,0),
+          Mask: net.IPv4Mask(255,0,0,0),
+        },
+        NetworkCIDR: "10.0.0.0/8",
+        HostedZoneID: "REV123",
+        HostedZoneName: "10.in-addr.arpa.",
+      },
+    },
+  }
+
+  awsClient := &AWSClientImpl{}
+  plan, err := awsClient.PlanAddAResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.", "ABC123", rInfos, RoutingPolicy{})
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  expected := &ChangePlan{
+    Steps: []*ChangeStep{
+      {
+        HostedZoneID: "ABC123",
+        Changes: []*route53.Change{
+          {
+            Action: aws.String(route53.ChangeActionCreate),
+            ResourceRecordSet: &route53.ResourceRecordSet{
+              Name: aws.String("www.example.com."),
+              ResourceRecords: []*route53.ResourceRecord{
+                {
+                  Value: aws.String("10.0.1.15"),
+                },
+              },
+              TTL: aws.Int64(600),
+              Type: aws.String(route53.RRTypeA),
+            },
+          },
+        },
+      },
+      {
+        HostedZoneID: "REV123",
+        HostedZoneName: "10.in-addr.arpa.",
+        Changes: []*route53.Change{
+          {
+            Action: aws.String(route53.ChangeActionCreate),
+            ResourceRecordSet: &route53.ResourceRecordSet{
+              Name: aws.String("15.1.0.10.in-addr.arpa."),
+              ResourceRecords: []*route53.ResourceRecord{
+                {
+                  Value: aws.String("www.example.com."),
+                },
+              },
+              TTL: aws.Int64(600),
+              Type: aws.String(route53.RRTypePtr),
+            },
+          },
+        },
+      },
+    },
+  }
+  if awsutil.StringValue(plan) != awsutil.StringValue(expected) {
+    t.Errorf("unexpected plan: expected %v, actual %v", expected, plan)
+  }
+
+  _, err = awsClient.PlanAddAResourceRecordSet(net.ParseIP("192.168.1.15"), "www.example.com.", "ABC123", rInfos, RoutingPolicy{})
+  if err == nil || err.Error() != "not found (192.168.1.15)" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
+
+func TestApplyChangePlanDryRun(t *testing.T) {
+  plan := &ChangePlan{}
+  plan.AddStep("ABC123", "",
+    newChange(route53.ChangeActionCreate, newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")))
+  plan.AddStep("REV123", "10.in-addr.arpa.",
+    newChange(route53.ChangeActionCreate, newPtrResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")))
+
+  patterns := []struct{
+    format string
+    expected string
+  }{
+    {
+      format: "text",
+      expected: "@@ step 1: ABC123 @@\n" +
+        "+ www.example.com. 600 A 10.0.1.15\n" +
+        "@@ step 2: 10.in-addr.arpa. (REV123) @@\n" +
+        "+ 15.1.0.10.in-addr.arpa. 600 PTR www.example.com.\n",
+    },
+  }
+
+  for idx, p := range patterns {
+    var out bytes.Buffer
+    awsClient := &AWSClientImpl{
+      // no Route53 calls may happen in dry-run mode
+      r53: nil,
+      dryRun: true,
+      planFormat: p.format,
+      out: &out,
+    }
+    err := awsClient.ApplyChangePlan(plan)
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+    }
+    if out.String() != p.expected {
+      t.Errorf("unexpected output (%d): expected %q, actual %q", idx, p.expected, out.String())
+    }
+  }
+}
+
+func TestFormatResourceRecordSet(t *testing.T) {
+  patterns := []struct{
+    rrset *route53.ResourceRecordSet
+    expected string
+  }{
+    {
+      rrset: newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com."),
+      expected: "www.example.com. 600 A 10.0.1.15",
+    },
+    {
+      rrset: NewAliasResourceRecordSet("example.com.", "A", "www.example.com.", "ABC123", true),
+      expected: "example.com. ALIAS A www.example.com. ABC123",
+    },
+  }
+
+  for idx, p := range patterns {
+    actual := FormatResourceRecordSet(p.rrset)
+    if actual != p.expected {
+      t.Errorf("pattern %d: want %s, actual %s", idx, p.expected, actual)
+    }
+  }
+}
@@ -0,0 +1,319 @@
+package utils
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "math/big"
+  "net"
+  "sort"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// Pool is an IP pool of the config file with its network and exclusions
+// parsed.
+type Pool struct {
+  Name string
+  Network *net.IPNet
+  excluded []ipRange
+}
+
+// PoolStatus is the utilization of a pool. Size and Free are big numbers,
+// as an IPv6 pool holds up to 2^64 addresses and more.
+type PoolStatus struct {
+  Name string `json:"name"`
+  Network string `json:"network"`
+  Size *big.Int `json:"size"`
+  Used int `json:"used"`
+  Free *big.Int `json:"free"`
+  NextFree string `json:"next_free,omitempty"`
+}
+
+type ipRange struct {
+  first net.IP
+  last net.IP
+}
+
+func (r ipRange) contains(ip net.IP) bool {
+  ip = ip.To16()
+  return bytes.Compare(r.first, ip) <= 0 && bytes.Compare(ip, r.last) <= 0
+}
+
+// parseIPRange parses an address, a CIDR or a range "first-last".
+func parseIPRange(s string) (r ipRange, err error) {
+  s = strings.TrimSpace(s)
+  if strings.Contains(s, "/") {
+    _, network, err := net.ParseCIDR(s)
+    if err != nil {
+      return r, err
+    }
+    return ipRange{first: network.IP.To16(), last: lastIP(network)}, nil
+  }
+  parts := strings.SplitN(s, "-", 2)
+  r.first = net.ParseIP(strings.TrimSpace(parts[0])).To16()
+  r.last = r.first
+  if len(parts) == 2 {
+    r.last = net.ParseIP(strings.TrimSpace(parts[1])).To16()
+  }
+  if r.first == nil || r.last == nil || bytes.Compare(r.first, r.last) > 0 {
+    return r, fmt.Errorf("invalid address range: %s", s)
+  }
+  return r, nil
+}
+
+// lastIP returns the last address of network.
+func lastIP(network *net.IPNet) net.IP {
+  ip := make(net.IP, len(network.IP))
+  for i := range network.IP {
+    ip[i] = network.IP[i] | ^network.Mask[i]
+  }
+  return ip.To16()
+}
+
+// nextIP returns the address following ip.
+func nextIP(ip net.IP) net.IP {
+  next := make(net.IP, len(ip))
+  copy(next, ip)
+  for i := len(next) - 1; i >= 0; i-- {
+    next[i]++
+    if next[i] != 0 {
+      break
+    }
+  }
+  return next
+}
+
+// NewPool parses the IP pool p. The network and broadcast addresses of IPv4
+// pools are excluded together with the reserved ranges and the gateways.
+func NewPool(p IPPool) (pool Pool, err error) {
+  pool.Name = p.Name
+  _, pool.Network, err = net.ParseCIDR(p.NetworkCIDR)
+  if err != nil {
+    return pool, fmt.Errorf("pool %s: %v", p.Name, err)
+  }
+  ones, bits := pool.Network.Mask.Size()
+  if bits == 32 && bits-ones > 1 {
+    pool.excluded = append(pool.excluded,
+      ipRange{first: pool.Network.IP.To16(), last: pool.Network.IP.To16()},
+      ipRange{first: lastIP(pool.Network), last: lastIP(pool.Network)})
+  }
+  for _, s := range append(append([]string{}, p.Reserved...), p.Gateways...) {
+    r, err := parseIPRange(s)
+    if err != nil {
+      return pool, fmt.Errorf("pool %s: %v", p.Name, err)
+    }
+    pool.excluded = append(pool.excluded, r)
+  }
+  return pool, nil
+}
+
+// GetPool returns the IP pool named name.
+func (confToml ConfToml) GetPool(name string) (pool Pool, err error) {
+  for _, p := range confToml.Pools {
+    if p.Name == name {
+      return NewPool(p)
+    }
+  }
+  return pool, fmt.Errorf("pool not found: %s", name)
+}
+
+// Excluded returns true when ip is reserved or a gateway of the pool.
+func (pool Pool) Excluded(ip net.IP) bool {
+  for _, r := range pool.excluded {
+    if r.contains(ip) {
+      return true
+    }
+  }
+  return false
+}
+
+// Status counts the addresses of the pool and the ones in used. Only the
+// used and excluded addresses are looked at, so that it takes no longer for
+// an IPv6 /64 than for an IPv4 /24.
+func (pool Pool) Status(used map[string]bool) (status PoolStatus) {
+  status.Name = pool.Name
+  status.Network = pool.Network.String()
+  status.Size = rangeSize(pool.Network.IP.To16(), lastIP(pool.Network))
+  status.Size.Sub(status.Size, pool.excludedSize())
+  for s := range used {
+    ip := net.ParseIP(s)
+    if ip != nil && pool.Network.Contains(ip) && !pool.Excluded(ip) {
+      status.Used++
+    }
+  }
+  status.Free = new(big.Int).Sub(status.Size, big.NewInt(int64(status.Used)))
+  if ip := pool.nextFree(used); ip != nil {
+    status.NextFree = ip.String()
+  }
+  return status
+}
+
+// NextFree returns the lowest address of the pool which is not in used.
+func (pool Pool) NextFree(used map[string]bool) (ip net.IP, err error) {
+  ip = pool.nextFree(used)
+  if ip == nil {
+    return nil, fmt.Errorf("no free address in pool %s (%s)", pool.Name, pool.Network.String())
+  }
+  return ip, nil
+}
+
+// nextFree walks the pool from its first address, jumping over the excluded
+// ranges, until an address is not in used. It stops after at most one step
+// per used address and excluded range, or returns nil when the pool is full.
+func (pool Pool) nextFree(used map[string]bool) net.IP {
+  ip := pool.Network.IP.To16()
+  for pool.Network.Contains(ip) {
+    if r, ok := pool.excludedRange(ip); ok {
+      if bytes.Compare(r.last, lastIP(pool.Network)) >= 0 {
+        return nil
+      }
+      ip = nextIP(r.last)
+      continue
+    }
+    if !used[ip.String()] {
+      return ip
+    }
+    ip = nextIP(ip)
+  }
+  return nil
+}
+
+// excludedRange returns the excluded range which contains ip, the one
+// reaching furthest when several do.
+func (pool Pool) excludedRange(ip net.IP) (found ipRange, ok bool) {
+  for _, r := range pool.excluded {
+    if r.contains(ip) && (!ok || bytes.Compare(r.last, found.last) > 0) {
+      found, ok = r, true
+    }
+  }
+  return found, ok
+}
+
+// excludedSize counts the addresses of the pool network which are excluded,
+// once even when they are in several excluded ranges.
+func (pool Pool) excludedSize() *big.Int {
+  first, last := pool.Network.IP.To16(), lastIP(pool.Network)
+  var ranges []ipRange
+  for _, r := range pool.excluded {
+    if bytes.Compare(r.last, first) < 0 || bytes.Compare(last, r.first) < 0 {
+      continue
+    }
+    if bytes.Compare(r.first, first) < 0 {
+      r.first = first
+    }
+    if bytes.Compare(r.last, last) > 0 {
+      r.last = last
+    }
+    ranges = append(ranges, r)
+  }
+  sort.Slice(ranges, func(i, j int) bool {
+    return bytes.Compare(ranges[i].first, ranges[j].first) < 0
+  })
+
+  size := new(big.Int)
+  var end net.IP
+  for _, r := range ranges {
+    if end != nil && bytes.Compare(r.first, end) <= 0 {
+      if bytes.Compare(r.last, end) <= 0 {
+        continue
+      }
+      r.first = nextIP(end)
+    }
+    size.Add(size, rangeSize(r.first, r.last))
+    end = r.last
+  }
+  return size
+}
+
+// rangeSize returns the number of addresses from first to last.
+func rangeSize(first net.IP, last net.IP) *big.Int {
+  size := new(big.Int).Sub(new(big.Int).SetBytes(last), new(big.Int).SetBytes(first))
+  return size.Add(size, big.NewInt(1))
+}
+
+// PoolUsedAddresses returns the addresses of the pool which have an address
+// record in the hosted zone or a PTR record in a reverse hosted zone.
+func (client *AWSClientImpl) PoolUsedAddresses(pool Pool, hostedZoneID string, rInfos ReverseHostedZoneInfos) (used map[string]bool, err error) {
+  forward, err := client.ListAllResourceRecords(hostedZoneID)
+  if err != nil {
+    return nil, err
+  }
+  records := map[string][]*route53.ResourceRecordSet{}
+  for _, rInfo := range rInfos.ReverseHostedZoneInfo {
+    if _, ok := records[rInfo.HostedZoneID]; ok {
+      continue
+    }
+    if !rInfo.Network.Contains(pool.Network.IP) && !pool.Network.Contains(rInfo.Network.IP) {
+      continue
+    }
+    records[rInfo.HostedZoneID], err = client.ListAllResourceRecords(rInfo.HostedZoneID)
+    if err != nil {
+      return nil, err
+    }
+  }
+  return poolUsedAddresses(pool, forward, records, rInfos), nil
+}
+
+func poolUsedAddresses(pool Pool, forward []*route53.ResourceRecordSet, records map[string][]*route53.ResourceRecordSet, rInfos ReverseHostedZoneInfos) (used map[string]bool) {
+  used = map[string]bool{}
+  for _, rrset := range forward {
+    rrType := aws.StringValue(rrset.Type)
+    if rrType != route53.RRTypeA && rrType != route53.RRTypeAaaa {
+      continue
+    }
+    for _, rr := range rrset.ResourceRecords {
+      ip := net.ParseIP(aws.StringValue(rr.Value))
+      if ip != nil && pool.Network.Contains(ip) {
+        used[ip.String()] = true
+      }
+    }
+  }
+  for _, rInfo := range rInfos.ReverseHostedZoneInfo {
+    for _, rrset := range records[rInfo.HostedZoneID] {
+      if aws.StringValue(rrset.Type) != route53.RRTypePtr {
+        continue
+      }
+      ip := rInfo.PtrRecordIP(aws.StringValue(rrset.Name))
+      if ip != nil && rInfo.Network.Contains(ip) && pool.Network.Contains(ip) {
+        used[ip.String()] = true
+      }
+    }
+  }
+  return used
+}
+
+// WritePoolStatuses writes the utilization of pools to w as text or json.
+func WritePoolStatuses(w io.Writer, statuses []PoolStatus, format string) (err error) {
+  switch format {
+  case "", "text":
+    for _, s := range statuses {
+      utilization := 0.0
+      if s.Size.Sign() > 0 {
+        size, _ := new(big.Float).SetInt(s.Size).Float64()
+        utilization = float64(s.Used) * 100 / size
+      }
+      _, err = fmt.Fprintf(w, "%s\t%s\t%d/%d used (%.1f%%)\tnext free: %s\n", s.Name, s.Network, s.Used, s.Size, utilization, nextFreeText(s))
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  case "json":
+    if statuses == nil {
+      statuses = []PoolStatus{}
+    }
+    return WriteJSON(w, statuses)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
+
+func nextFreeText(s PoolStatus) string {
+  if len(s.NextFree) == 0 {
+    return "none"
+  }
+  return s.NextFree
+}
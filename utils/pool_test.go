@@ -0,0 +1,144 @@
+package utils
+
+import (
+  "net"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestNewPool(t *testing.T) {
+  patterns := []struct{
+    pool IPPool
+    expectedError string
+    expectedSize string
+    expectedNextFree string
+  }{
+    {
+      pool: IPPool{Name: "app", NetworkCIDR: "10.0.1.0/24", Gateways: []string{"10.0.1.1"}},
+      expectedSize: "253",
+      expectedNextFree: "10.0.1.2",
+    },
+    {
+      pool: IPPool{Name: "app", NetworkCIDR: "10.0.1.0/28", Reserved: []string{"10.0.1.1-10.0.1.4", "10.0.1.8/30"}},
+      expectedSize: "6",
+      expectedNextFree: "10.0.1.5",
+    },
+    {
+      pool: IPPool{Name: "v6", NetworkCIDR: "2001:db8::/120", Reserved: []string{"2001:db8::"}},
+      expectedSize: "255",
+      expectedNextFree: "2001:db8::1",
+    },
+    {
+      pool: IPPool{Name: "app", NetworkCIDR: "10.0.1.0/30", Reserved: []string{"10.0.1.1", "10.0.1.2"}},
+      expectedSize: "0",
+    },
+    {
+      pool: IPPool{Name: "app", NetworkCIDR: "10.0.1.0/28", Reserved: []string{"10.0.1.1-10.0.1.4", "10.0.1.3-10.0.1.6"}},
+      expectedSize: "8",
+      expectedNextFree: "10.0.1.7",
+    },
+    {
+      pool: IPPool{Name: "app", NetworkCIDR: "10.0.0.0/7"},
+      expectedSize: "33554430",
+      expectedNextFree: "10.0.0.1",
+    },
+    {
+      pool: IPPool{Name: "v6", NetworkCIDR: "2001:db8::/64", Reserved: []string{"2001:db8::-2001:db8::ff"}},
+      expectedSize: "18446744073709551360",
+      expectedNextFree: "2001:db8::100",
+    },
+    {
+      pool: IPPool{Name: "app", NetworkCIDR: "10.0.1.0/24", Reserved: []string{"10.0.1.9-10.0.1.2"}},
+      expectedError: "pool app: invalid address range: 10.0.1.9-10.0.1.2",
+    },
+  }
+
+  for idx, p := range patterns {
+    pool, err := NewPool(p.pool)
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+      continue
+    }
+    status := pool.Status(map[string]bool{})
+    if status.Size.String() != p.expectedSize || status.NextFree != p.expectedNextFree {
+      t.Errorf("pattern %d: want %s %s, actual %s %s", idx, p.expectedSize, p.expectedNextFree, status.Size, status.NextFree)
+    }
+  }
+}
+
+func TestPoolNextFree(t *testing.T) {
+  pool, err := NewPool(IPPool{Name: "app", NetworkCIDR: "10.0.1.0/29", Gateways: []string{"10.0.1.1"}})
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  rInfo := newReverseHostedZoneInfo("10.0.0.0/8", "REV123", "10.in-addr.arpa.")
+  rInfos := ReverseHostedZoneInfos{ReverseHostedZoneInfo: []ReverseHostedZoneInfo{rInfo}}
+  forward := []*route53.ResourceRecordSet{
+    newAddressResourceRecordSet(net.ParseIP("10.0.1.2"), "web1.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("10.0.2.3"), "other.example.com."),
+  }
+  records := map[string][]*route53.ResourceRecordSet{
+    "REV123": {
+      // a PTR record without an address record still takes the address
+      rInfo.ptrResourceRecordSet(net.ParseIP("10.0.1.3"), "old.example.com."),
+    },
+  }
+
+  used := poolUsedAddresses(pool, forward, records, rInfos)
+  ip, err := pool.NextFree(used)
+  if err != nil || ip.String() != "10.0.1.4" {
+    t.Errorf("want 10.0.1.4, actual %v (%v)", ip, err)
+  }
+  status := pool.Status(used)
+  if status.Size.Int64() != 5 || status.Used != 2 || status.Free.Int64() != 3 {
+    t.Errorf("unexpected status: %+v", status)
+  }
+
+  used["10.0.1.4"], used["10.0.1.5"], used["10.0.1.6"] = true, true, true
+  _, err = pool.NextFree(used)
+  if err == nil || err.Error() != "no free address in pool app (10.0.1.0/29)" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
+
+func TestPoolNextFreeIPv6(t *testing.T) {
+  pool, err := NewPool(IPPool{Name: "v6", NetworkCIDR: "2001:db8:0:1::/64", Gateways: []string{"2001:db8:0:1::1"}})
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  used := map[string]bool{
+    "2001:db8:0:1::": true,
+    "2001:db8:0:1::2": true,
+    "2001:db8:0:1::3": true,
+    "2001:db8:0:2::4": true,
+  }
+  ip, err := pool.NextFree(used)
+  if err != nil || ip.String() != "2001:db8:0:1::4" {
+    t.Errorf("want 2001:db8:0:1::4, actual %v (%v)", ip, err)
+  }
+  status := pool.Status(used)
+  if status.Size.String() != "18446744073709551615" || status.Used != 3 || status.Free.String() != "18446744073709551612" {
+    t.Errorf("unexpected status: %+v", status)
+  }
+
+  // the last address is found by jumping over the excluded range
+  pool, err = NewPool(IPPool{Name: "v6", NetworkCIDR: "2001:db8::/64", Reserved: []string{"2001:db8::-2001:db8::ffff:ffff:ffff:fffe"}})
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  ip, err = pool.NextFree(map[string]bool{})
+  if err != nil || ip.String() != "2001:db8::ffff:ffff:ffff:ffff" {
+    t.Errorf("want 2001:db8::ffff:ffff:ffff:ffff, actual %v (%v)", ip, err)
+  }
+  _, err = pool.NextFree(map[string]bool{"2001:db8::ffff:ffff:ffff:ffff": true})
+  if err == nil || err.Error() != "no free address in pool v6 (2001:db8::/64)" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
@@ -0,0 +1,314 @@
+package utils
+
+import (
+  "fmt"
+  "math/rand"
+  "sync"
+  "time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// RetryOptions controls how RetryingRoute53Client retries the Route53 calls.
+type RetryOptions struct {
+  // MaxAttempts is the number of attempts of a call, the first one
+  // included. Less than 2 disables the retries.
+  MaxAttempts int
+  // BaseDelay is the backoff before the second attempt. It doubles with
+  // every attempt up to MaxDelay, and is jittered.
+  BaseDelay time.Duration
+  MaxDelay time.Duration
+  // RateLimit is the number of calls per second, shared by all the calls of
+  // the client. Zero means no limit.
+  RateLimit float64
+}
+
+// DefaultRetryOptions stays under the Route53 limit of 5 requests per
+// second per account.
+var DefaultRetryOptions = RetryOptions{
+  MaxAttempts: 5,
+  BaseDelay: 200 * time.Millisecond,
+  MaxDelay: 20 * time.Second,
+  RateLimit: 5,
+}
+
+// RetryOptionsFromContext returns DefaultRetryOptions with the global
+// --max-attempts and --rate-limit flags applied.
+func RetryOptionsFromContext(c *cli.Context) RetryOptions {
+  root := rootContext(c)
+  opts := DefaultRetryOptions
+  if root.IsSet("max-attempts") {
+    opts.MaxAttempts = root.Int("max-attempts")
+  }
+  if root.IsSet("rate-limit") {
+    opts.RateLimit = root.Float64("rate-limit")
+  }
+  return opts
+}
+
+// retryableErrorCodes are the Route53 errors which go away by themselves,
+// besides the throttling errors known to the SDK.
+var retryableErrorCodes = map[string]bool{
+  route53.ErrCodePriorRequestNotComplete: true,
+  route53.ErrCodeThrottlingException: true,
+  "Throttling": true,
+  "ServiceUnavailable": true,
+  "InternalError": true,
+  "InternalFailure": true,
+}
+
+// IsRetryableError reports whether a call which failed with err may succeed
+// when it is sent again. Throttling, PriorRequestNotComplete, server errors
+// and network errors are retryable. Errors of the request itself, such as
+// InvalidChangeBatch or NoSuchHostedZone, and cancellation are not.
+func IsRetryableError(err error) bool {
+  aerr, ok := err.(awserr.Error)
+  if !ok {
+    return false
+  }
+  if aerr.Code() == request.CanceledErrorCode {
+    return false
+  }
+  if retryableErrorCodes[aerr.Code()] || request.IsErrorThrottle(err) {
+    return true
+  }
+  if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() >= 500 {
+    return true
+  }
+  return request.IsErrorRetryable(err)
+}
+
+// rejectedErrorCodes are the Route53 errors which reject a call before it
+// changes anything.
+var rejectedErrorCodes = map[string]bool{
+  route53.ErrCodePriorRequestNotComplete: true,
+  route53.ErrCodeThrottlingException: true,
+  "Throttling": true,
+}
+
+// IsRejectedError reports whether a call which failed with err was rejected
+// without effect, so that it is safe to send again even when it is not
+// idempotent. Only throttling and PriorRequestNotComplete are.
+func IsRejectedError(err error) bool {
+  aerr, ok := err.(awserr.Error)
+  if !ok {
+    return false
+  }
+  return rejectedErrorCodes[aerr.Code()] || request.IsErrorThrottle(err)
+}
+
+// IsAmbiguousError reports whether a call which failed with err may have
+// taken effect anyway: server and network errors can come after Route53
+// committed the request.
+func IsAmbiguousError(err error) bool {
+  return IsRetryableError(err) && !IsRejectedError(err)
+}
+
+// RetryingRoute53Client is a Route53Client which sends the calls of r53 at
+// the rate limit, and retries the ones failing with a retryable error with
+// exponential backoff.
+type RetryingRoute53Client struct {
+  r53 Route53Client
+  opts RetryOptions
+  limiter *rateLimiter
+
+  mu sync.Mutex
+  rand *rand.Rand
+}
+
+// NewRetryingRoute53Client wraps r53 with the retries and the rate limit of
+// opts.
+func NewRetryingRoute53Client(r53 Route53Client, opts RetryOptions) *RetryingRoute53Client {
+  return &RetryingRoute53Client{
+    r53: r53,
+    opts: opts,
+    limiter: newRateLimiter(opts.RateLimit),
+    rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+  }
+}
+
+// ListHostedZonesByNameWithContext ...
+func (r *RetryingRoute53Client) ListHostedZonesByNameWithContext(ctx aws.Context, input *route53.ListHostedZonesByNameInput, opts ...request.Option) (output *route53.ListHostedZonesByNameOutput, err error) {
+  err = r.retry(ctx, func() error {
+    output, err = r.r53.ListHostedZonesByNameWithContext(ctx, input, opts...)
+    return err
+  })
+  return output, err
+}
+
+// ListResourceRecordSetsWithContext ...
+func (r *RetryingRoute53Client) ListResourceRecordSetsWithContext(ctx aws.Context, input *route53.ListResourceRecordSetsInput, opts ...request.Option) (output *route53.ListResourceRecordSetsOutput, err error) {
+  err = r.retry(ctx, func() error {
+    output, err = r.r53.ListResourceRecordSetsWithContext(ctx, input, opts...)
+    return err
+  })
+  return output, err
+}
+
+// ChangeResourceRecordSetsWithContext is only retried when it was rejected,
+// since a change batch which was committed before its response failed
+// would fail or be applied twice when sent again.
+func (r *RetryingRoute53Client) ChangeResourceRecordSetsWithContext(ctx aws.Context, input *route53.ChangeResourceRecordSetsInput, opts ...request.Option) (output *route53.ChangeResourceRecordSetsOutput, err error) {
+  err = r.retryIf(ctx, IsRejectedError, func() error {
+    output, err = r.r53.ChangeResourceRecordSetsWithContext(ctx, input, opts...)
+    return err
+  })
+  return output, err
+}
+
+// GetChangeWithContext ...
+func (r *RetryingRoute53Client) GetChangeWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.Option) (output *route53.GetChangeOutput, err error) {
+  err = r.retry(ctx, func() error {
+    output, err = r.r53.GetChangeWithContext(ctx, input, opts...)
+    return err
+  })
+  return output, err
+}
+
+// WaitUntilResourceRecordSetsChangedWithContext ...
+func (r *RetryingRoute53Client) WaitUntilResourceRecordSetsChangedWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.WaiterOption) error {
+  return r.retry(ctx, func() error {
+    return r.r53.WaitUntilResourceRecordSetsChangedWithContext(ctx, input, opts...)
+  })
+}
+
+// CreateHostedZoneWithContext is only retried when it was rejected, since a
+// zone created before the response failed would be reported as
+// HostedZoneAlreadyExists when sent again.
+func (r *RetryingRoute53Client) CreateHostedZoneWithContext(ctx aws.Context, input *route53.CreateHostedZoneInput, opts ...request.Option) (output *route53.CreateHostedZoneOutput, err error) {
+  err = r.retryIf(ctx, IsRejectedError, func() error {
+    output, err = r.r53.CreateHostedZoneWithContext(ctx, input, opts...)
+    return err
+  })
+  return output, err
+}
+
+// GetHostedZoneWithContext ...
+func (r *RetryingRoute53Client) GetHostedZoneWithContext(ctx aws.Context, input *route53.GetHostedZoneInput, opts ...request.Option) (output *route53.GetHostedZoneOutput, err error) {
+  err = r.retry(ctx, func() error {
+    output, err = r.r53.GetHostedZoneWithContext(ctx, input, opts...)
+    return err
+  })
+  return output, err
+}
+
+// DeleteHostedZoneWithContext is only retried when it was rejected, since a
+// zone deleted before the response failed would be reported as
+// NoSuchHostedZone when sent again.
+func (r *RetryingRoute53Client) DeleteHostedZoneWithContext(ctx aws.Context, input *route53.DeleteHostedZoneInput, opts ...request.Option) (output *route53.DeleteHostedZoneOutput, err error) {
+  err = r.retryIf(ctx, IsRejectedError, func() error {
+    output, err = r.r53.DeleteHostedZoneWithContext(ctx, input, opts...)
+    return err
+  })
+  return output, err
+}
+
+// retry calls call until it succeeds, fails with an error which is not
+// retryable, runs out of attempts or ctx is done.
+func (r *RetryingRoute53Client) retry(ctx aws.Context, call func() error) (err error) {
+  return r.retryIf(ctx, IsRetryableError, call)
+}
+
+// retryIf is retry which only retries the errors retryable reports.
+func (r *RetryingRoute53Client) retryIf(ctx aws.Context, retryable func(error) bool, call func() error) (err error) {
+  for attempt := 1; ; attempt++ {
+    err = r.limiter.wait(ctx)
+    if err != nil {
+      return awserr.New(request.CanceledErrorCode, "request context canceled", err)
+    }
+    err = call()
+    if err == nil || !retryable(err) {
+      return err
+    }
+    if attempt >= r.opts.MaxAttempts {
+      if attempt > 1 {
+        return fmt.Errorf("giving up after %d attempts: %v", attempt, err)
+      }
+      return err
+    }
+    if sleepContext(ctx, r.backoff(attempt)) != nil {
+      return err
+    }
+  }
+}
+
+// backoff returns the delay after the attempt, between half and all of
+// BaseDelay * 2^(attempt-1), capped by MaxDelay.
+func (r *RetryingRoute53Client) backoff(attempt int) time.Duration {
+  delay := r.opts.BaseDelay
+  for i := 1; i < attempt && delay < r.opts.MaxDelay; i++ {
+    delay *= 2
+  }
+  if r.opts.MaxDelay > 0 && delay > r.opts.MaxDelay {
+    delay = r.opts.MaxDelay
+  }
+  if delay <= 0 {
+    return 0
+  }
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  return delay/2 + time.Duration(r.rand.Int63n(int64(delay/2)+1))
+}
+
+// rateLimiter spaces the calls out evenly at its rate, letting a burst of
+// up to one second of calls through at once.
+type rateLimiter struct {
+  mu sync.Mutex
+  interval time.Duration
+  burst int
+  // next is the time the calls made so far are paid off at
+  next time.Time
+}
+
+// newRateLimiter returns a limiter of perSecond calls, or nil, which does
+// not limit, when perSecond is not positive.
+func newRateLimiter(perSecond float64) *rateLimiter {
+  if perSecond <= 0 {
+    return nil
+  }
+  burst := int(perSecond)
+  if burst < 1 {
+    burst = 1
+  }
+  return &rateLimiter{
+    interval: time.Duration(float64(time.Second) / perSecond),
+    burst: burst,
+  }
+}
+
+// wait blocks until a call may be made, or until ctx is done.
+func (l *rateLimiter) wait(ctx aws.Context) error {
+  if l == nil {
+    return nil
+  }
+  l.mu.Lock()
+  now := time.Now()
+  if l.next.Before(now) {
+    l.next = now
+  }
+  delay := l.next.Sub(now) - time.Duration(l.burst-1)*l.interval
+  l.next = l.next.Add(l.interval)
+  l.mu.Unlock()
+  return sleepContext(ctx, delay)
+}
+
+// sleepContext waits for d, or returns the error of ctx when it is done
+// first.
+func sleepContext(ctx aws.Context, d time.Duration) error {
+  if d <= 0 {
+    return nil
+  }
+  timer := time.NewTimer(d)
+  defer timer.Stop()
+  select {
+  case <-timer.C:
+    return nil
+  case <-ctx.Done():
+    return ctx.Err()
+  }
+}
@@ -0,0 +1,313 @@
+package utils
+
+import (
+  "context"
+  "errors"
+  "strings"
+  "testing"
+  "time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// flakyRoute53Client fails its calls with errs, one per call, and succeeds
+// afterwards.
+type flakyRoute53Client struct {
+  DummyRoute53Client
+  errs []error
+  calls int
+}
+
+func (c *flakyRoute53Client) pop() error {
+  c.calls++
+  if len(c.errs) > 0 {
+    err := c.errs[0]
+    c.errs = c.errs[1:]
+    return err
+  }
+  return nil
+}
+
+func (c *flakyRoute53Client) ListHostedZonesByNameWithContext(ctx aws.Context, input *route53.ListHostedZonesByNameInput, opts ...request.Option) (*route53.ListHostedZonesByNameOutput, error) {
+  if err := c.pop(); err != nil {
+    return nil, err
+  }
+  return &route53.ListHostedZonesByNameOutput{}, nil
+}
+
+func (c *flakyRoute53Client) ChangeResourceRecordSetsWithContext(ctx aws.Context, input *route53.ChangeResourceRecordSetsInput, opts ...request.Option) (*route53.ChangeResourceRecordSetsOutput, error) {
+  if err := c.pop(); err != nil {
+    return nil, err
+  }
+  return &route53.ChangeResourceRecordSetsOutput{}, nil
+}
+
+func (c *flakyRoute53Client) CreateHostedZoneWithContext(ctx aws.Context, input *route53.CreateHostedZoneInput, opts ...request.Option) (*route53.CreateHostedZoneOutput, error) {
+  if err := c.pop(); err != nil {
+    return nil, err
+  }
+  return &route53.CreateHostedZoneOutput{}, nil
+}
+
+func (c *flakyRoute53Client) DeleteHostedZoneWithContext(ctx aws.Context, input *route53.DeleteHostedZoneInput, opts ...request.Option) (*route53.DeleteHostedZoneOutput, error) {
+  if err := c.pop(); err != nil {
+    return nil, err
+  }
+  return &route53.DeleteHostedZoneOutput{}, nil
+}
+
+func TestIsRetryableError(t *testing.T) {
+  patterns := []struct {
+    err error
+    expected bool
+  }{
+    {
+      err: awserr.New("Throttling", "Rate exceeded", nil),
+      expected: true,
+    },
+    {
+      err: awserr.New(route53.ErrCodePriorRequestNotComplete, "The request was rejected because Route 53 was still processing a prior request", nil),
+      expected: true,
+    },
+    {
+      err: awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "", nil), 503, "req-1"),
+      expected: true,
+    },
+    {
+      err: awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("connection refused")),
+      expected: true,
+    },
+    {
+      err: awserr.NewRequestFailure(awserr.New(route53.ErrCodeInvalidChangeBatch, "Tried to create resource record set but it already exists", nil), 400, "req-2"),
+      expected: false,
+    },
+    {
+      err: awserr.New(route53.ErrCodeNoSuchHostedZone, "No hosted zone found", nil),
+      expected: false,
+    },
+    {
+      err: awserr.New(request.CanceledErrorCode, "request context canceled", context.Canceled),
+      expected: false,
+    },
+    {
+      err: errors.New("Throttling"),
+      expected: false,
+    },
+  }
+
+  for idx, pattern := range patterns {
+    actual := IsRetryableError(pattern.err)
+    if actual != pattern.expected {
+      t.Errorf("pattern %d: want %v, actual %v (%v)", idx, pattern.expected, actual, pattern.err)
+    }
+  }
+}
+
+func TestIsRejectedError(t *testing.T) {
+  patterns := []struct {
+    err error
+    expectedRejected bool
+    expectedAmbiguous bool
+  }{
+    {
+      err: awserr.New("Throttling", "Rate exceeded", nil),
+      expectedRejected: true,
+    },
+    {
+      err: awserr.New(route53.ErrCodePriorRequestNotComplete, "The request was rejected because Route 53 was still processing a prior request", nil),
+      expectedRejected: true,
+    },
+    {
+      err: awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "", nil), 503, "req-1"),
+      expectedAmbiguous: true,
+    },
+    {
+      err: awserr.NewRequestFailure(awserr.New("InternalError", "", nil), 500, "req-2"),
+      expectedAmbiguous: true,
+    },
+    {
+      err: awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("connection reset")),
+      expectedAmbiguous: true,
+    },
+    {
+      err: awserr.NewRequestFailure(awserr.New(route53.ErrCodeInvalidChangeBatch, "invalid", nil), 400, "req-3"),
+    },
+    {
+      err: awserr.New(request.CanceledErrorCode, "request context canceled", context.Canceled),
+    },
+  }
+
+  for idx, pattern := range patterns {
+    actual := IsRejectedError(pattern.err)
+    if actual != pattern.expectedRejected {
+      t.Errorf("pattern %d: want rejected %v, actual %v (%v)", idx, pattern.expectedRejected, actual, pattern.err)
+    }
+    actual = IsAmbiguousError(pattern.err)
+    if actual != pattern.expectedAmbiguous {
+      t.Errorf("pattern %d: want ambiguous %v, actual %v (%v)", idx, pattern.expectedAmbiguous, actual, pattern.err)
+    }
+  }
+}
+
+func TestRetryingRoute53Client(t *testing.T) {
+  throttled := awserr.New("Throttling", "Rate exceeded", nil)
+  invalid := awserr.New(route53.ErrCodeInvalidChangeBatch, "invalid", nil)
+  patterns := []struct {
+    maxAttempts int
+    errs []error
+
+    expectedCalls int
+    expectedError string
+  }{
+    {
+      maxAttempts: 3,
+      errs: []error{throttled, throttled},
+      expectedCalls: 3,
+    },
+    {
+      maxAttempts: 3,
+      errs: []error{throttled, throttled, throttled},
+      expectedCalls: 3,
+      expectedError: "giving up after 3 attempts: Throttling: Rate exceeded",
+    },
+    {
+      maxAttempts: 3,
+      errs: []error{invalid},
+      expectedCalls: 1,
+      expectedError: "InvalidChangeBatch: invalid",
+    },
+    {
+      maxAttempts: 1,
+      errs: []error{throttled},
+      expectedCalls: 1,
+      expectedError: "Throttling: Rate exceeded",
+    },
+  }
+
+  for idx, pattern := range patterns {
+    r53 := &flakyRoute53Client{errs: pattern.errs}
+    client := NewRetryingRoute53Client(r53, RetryOptions{MaxAttempts: pattern.maxAttempts, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+    _, err := client.ListHostedZonesByNameWithContext(context.Background(), &route53.ListHostedZonesByNameInput{})
+    if r53.calls != pattern.expectedCalls {
+      t.Errorf("pattern %d: want %d calls, actual %d", idx, pattern.expectedCalls, r53.calls)
+    }
+    actualError := ""
+    if err != nil {
+      actualError = err.Error()
+    }
+    if actualError != pattern.expectedError {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, pattern.expectedError, err)
+    }
+  }
+
+  // the backoff gives up when the context is done
+  r53 := &flakyRoute53Client{errs: []error{throttled, throttled}}
+  client := NewRetryingRoute53Client(r53, RetryOptions{MaxAttempts: 3, BaseDelay: time.Hour})
+  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+  defer cancel()
+  _, err := client.ListHostedZonesByNameWithContext(ctx, &route53.ListHostedZonesByNameInput{})
+  if err != throttled || r53.calls != 1 {
+    t.Errorf("want throttled after 1 call, actual %v after %d", err, r53.calls)
+  }
+}
+
+func TestRetryingRoute53ClientNotIdempotent(t *testing.T) {
+  throttled := awserr.New("Throttling", "Rate exceeded", nil)
+  unavailable := awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service Unavailable", nil), 503, "req-1")
+  calls := []struct {
+    name string
+    call func(client *RetryingRoute53Client) error
+  }{
+    {
+      name: "ChangeResourceRecordSets",
+      call: func(client *RetryingRoute53Client) error {
+        _, err := client.ChangeResourceRecordSetsWithContext(context.Background(), &route53.ChangeResourceRecordSetsInput{})
+        return err
+      },
+    },
+    {
+      name: "CreateHostedZone",
+      call: func(client *RetryingRoute53Client) error {
+        _, err := client.CreateHostedZoneWithContext(context.Background(), &route53.CreateHostedZoneInput{})
+        return err
+      },
+    },
+    {
+      name: "DeleteHostedZone",
+      call: func(client *RetryingRoute53Client) error {
+        _, err := client.DeleteHostedZoneWithContext(context.Background(), &route53.DeleteHostedZoneInput{})
+        return err
+      },
+    },
+  }
+
+  for _, c := range calls {
+    // a rejected call is sent again
+    r53 := &flakyRoute53Client{errs: []error{throttled}}
+    client := NewRetryingRoute53Client(r53, RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+    if err := c.call(client); err != nil || r53.calls != 2 {
+      t.Errorf("%s: want success after 2 calls, actual %v after %d", c.name, err, r53.calls)
+    }
+
+    // a call which may have taken effect is not
+    r53 = &flakyRoute53Client{errs: []error{unavailable}}
+    client = NewRetryingRoute53Client(r53, RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+    if err := c.call(client); err != unavailable || r53.calls != 1 {
+      t.Errorf("%s: want %v after 1 call, actual %v after %d", c.name, unavailable, err, r53.calls)
+    }
+  }
+}
+
+func TestBackoff(t *testing.T) {
+  client := NewRetryingRoute53Client(nil, RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+  patterns := []struct {
+    attempt int
+    max time.Duration
+  }{
+    {attempt: 1, max: 100 * time.Millisecond},
+    {attempt: 2, max: 200 * time.Millisecond},
+    {attempt: 4, max: 800 * time.Millisecond},
+    {attempt: 10, max: time.Second},
+  }
+
+  for idx, pattern := range patterns {
+    for i := 0; i < 20; i++ {
+      actual := client.backoff(pattern.attempt)
+      if actual < pattern.max/2 || actual > pattern.max {
+        t.Errorf("pattern %d: want %v to %v, actual %v", idx, pattern.max/2, pattern.max, actual)
+      }
+    }
+  }
+}
+
+func TestRateLimiter(t *testing.T) {
+  var limiter *rateLimiter
+  if newRateLimiter(0) != limiter {
+    t.Errorf("want no limiter for 0 calls per second")
+  }
+
+  // a burst of 2 calls goes through, the next ones are 50ms apart
+  limiter = newRateLimiter(2.5)
+  limiter.interval = 50 * time.Millisecond
+  start := time.Now()
+  for i := 0; i < 4; i++ {
+    err := limiter.wait(context.Background())
+    if err != nil {
+      t.Fatalf("unexpected error: %v", err)
+    }
+  }
+  elapsed := time.Since(start)
+  if elapsed < 100*time.Millisecond || elapsed > time.Second {
+    t.Errorf("want about 100ms, actual %v", elapsed)
+  }
+
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  err := limiter.wait(ctx)
+  if err == nil || !strings.Contains(err.Error(), "canceled") {
+    t.Errorf("want canceled, actual %v", err)
+  }
+}
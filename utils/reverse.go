@@ -0,0 +1,118 @@
+package utils
+
+import (
+  "fmt"
+  "net"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// ListHostedZones returns every hosted zone of the account, ordered by name.
+func (client *AWSClientImpl) ListHostedZones() (zones []*route53.HostedZone, err error) {
+  input := route53.ListHostedZonesByNameInput{}
+  for {
+    var resp *route53.ListHostedZonesByNameOutput
+    resp, err = client.r53.ListHostedZonesByNameWithContext(client.ctx, &input)
+    if err != nil {
+      return nil, err
+    }
+    zones = append(zones, resp.HostedZones...)
+
+    if !aws.BoolValue(resp.IsTruncated) {
+      return zones, nil
+    }
+    input.DNSName = resp.NextDNSName
+    input.HostedZoneId = resp.NextHostedZoneId
+  }
+}
+
+// DiscoverReverseHostedZoneInfos returns the reverse hosted zones of the
+// account, with the networks derived from their names. Zones whose names do
+// not map to a network are skipped, and so are private zones, which only
+// answer inside their VPCs and have to be configured explicitly.
+func (client *AWSClientImpl) DiscoverReverseHostedZoneInfos() (rInfos ReverseHostedZoneInfos, err error) {
+  zones, err := client.ListHostedZones()
+  if err != nil {
+    return rInfos, err
+  }
+  for _, zone := range zones {
+    name := aws.StringValue(zone.Name)
+    if !IsReverseZone(name) {
+      continue
+    }
+    if zone.Config != nil && aws.BoolValue(zone.Config.PrivateZone) {
+      continue
+    }
+    network, err := ReverseZoneNetwork(name)
+    if err != nil {
+      continue
+    }
+    idParts := strings.Split(aws.StringValue(zone.Id), "/")
+    rInfos.ReverseHostedZoneInfo = append(rInfos.ReverseHostedZoneInfo, ReverseHostedZoneInfo{
+      Network: network,
+      NetworkCIDR: network.String(),
+      HostedZoneID: idParts[len(idParts)-1],
+      HostedZoneName: name,
+    })
+  }
+  return rInfos, nil
+}
+
+// ResolveReverseHostedZoneInfos returns the reverse hosted zones listed in
+// confToml together with the ones discovered in the account. A configured
+// zone overrides the discovered zones of the same network.
+func (client *AWSClientImpl) ResolveReverseHostedZoneInfos(confToml ConfToml) (rInfos ReverseHostedZoneInfos, err error) {
+  rInfos, err = client.LoadReverseHostedZoneInfos(confToml)
+  if err != nil {
+    return rInfos, err
+  }
+  discovered, err := client.DiscoverReverseHostedZoneInfos()
+  if err != nil {
+    return rInfos, err
+  }
+  return mergeReverseHostedZoneInfos(rInfos, discovered), nil
+}
+
+func mergeReverseHostedZoneInfos(configured ReverseHostedZoneInfos, discovered ReverseHostedZoneInfos) (rInfos ReverseHostedZoneInfos) {
+  rInfos.ReverseHostedZoneInfo = append(rInfos.ReverseHostedZoneInfo, configured.ReverseHostedZoneInfo...)
+  networks := map[string]bool{}
+  for _, rInfo := range configured.ReverseHostedZoneInfo {
+    networks[rInfo.Network.String()] = true
+  }
+  for _, rInfo := range discovered.ReverseHostedZoneInfo {
+    if !networks[rInfo.Network.String()] {
+      rInfos.ReverseHostedZoneInfo = append(rInfos.ReverseHostedZoneInfo, rInfo)
+    }
+  }
+  return rInfos
+}
+
+// longestPrefixMatch returns the reverse hosted zones with the most specific
+// network which contains ip.
+func longestPrefixMatch(ip net.IP, rInfos ReverseHostedZoneInfos) (matches []ReverseHostedZoneInfo) {
+  longest := -1
+  for _, rInfo := range rInfos.ReverseHostedZoneInfo {
+    if !rInfo.Network.Contains(ip) {
+      continue
+    }
+    ones, _ := rInfo.Network.Mask.Size()
+    if ones > longest {
+      longest = ones
+      matches = nil
+    }
+    if ones == longest {
+      matches = append(matches, rInfo)
+    }
+  }
+  return matches
+}
+
+func reverseHostedZoneNames(rInfos []ReverseHostedZoneInfo) string {
+  names := make([]string, 0, len(rInfos))
+  for _, rInfo := range rInfos {
+    names = append(names, fmt.Sprintf("%s (%s)", rInfo.HostedZoneName, rInfo.HostedZoneID))
+  }
+  return strings.Join(names, ", ")
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+  "net"
+  "testing"
+)
+
+func newReverseHostedZoneInfo(networkCIDR string, zoneID string, zoneName string) ReverseHostedZoneInfo {
+  _, network, _ := net.ParseCIDR(networkCIDR)
+  return ReverseHostedZoneInfo{
+    Network: network,
+    NetworkCIDR: networkCIDR,
+    HostedZoneID: zoneID,
+    HostedZoneName: zoneName,
+  }
+}
+
+func TestGetReverseHostedZoneInfoLongestPrefix(t *testing.T) {
+  configured := ReverseHostedZoneInfos{
+    ReverseHostedZoneInfo: []ReverseHostedZoneInfo{
+      newReverseHostedZoneInfo("10.0.0.0/8", "CONF10", "10.in-addr.arpa."),
+    },
+  }
+  discovered := ReverseHostedZoneInfos{
+    ReverseHostedZoneInfo: []ReverseHostedZoneInfo{
+      newReverseHostedZoneInfo("10.0.0.0/8", "DISC10", "10.in-addr.arpa."),
+      newReverseHostedZoneInfo("10.0.1.0/24", "DISC1", "1.0.10.in-addr.arpa."),
+      newReverseHostedZoneInfo("192.168.1.0/24", "PUBLIC", "1.168.192.in-addr.arpa."),
+      newReverseHostedZoneInfo("192.168.1.0/24", "PRIVATE", "1.168.192.in-addr.arpa."),
+    },
+  }
+  rInfos := mergeReverseHostedZoneInfos(configured, discovered)
+
+  patterns := []struct{
+    ip net.IP
+    expectedZoneID string
+    expectedError string
+  }{
+    { net.ParseIP("10.0.1.15"), "DISC1", "" },
+    { net.ParseIP("10.0.2.15"), "CONF10", "" },
+    { net.ParseIP("192.168.1.15"), "", "multiple reverse hosted zones for 192.168.1.15: 1.168.192.in-addr.arpa. (PUBLIC), 1.168.192.in-addr.arpa. (PRIVATE)" },
+    { net.ParseIP("172.16.0.1"), "", "not found (172.16.0.1)" },
+  }
+
+  for idx, p := range patterns {
+    actual, err := GetReverseHostedZoneInfo(p.ip, rInfos)
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if actual.HostedZoneID != p.expectedZoneID {
+      t.Errorf("pattern %d: want %s, actual %s", idx, p.expectedZoneID, actual.HostedZoneID)
+    }
+  }
+}
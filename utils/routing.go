@@ -0,0 +1,175 @@
+package utils
+
+import (
+  "fmt"
+  "strings"
+
+  "github.com/urfave/cli/v2"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// RoutingPolicy is the routing policy of a record set. Record sets sharing
+// a name and type are told apart by SetIdentifier.
+type RoutingPolicy struct {
+  SetIdentifier string
+  Weight *int64
+  Failover string
+  Region string
+  GeoContinentCode string
+  GeoCountryCode string
+  GeoSubdivisionCode string
+  HealthCheckID string
+}
+
+// RoutingPolicyFlags are the flags of the commands which manage record sets
+// with a routing policy.
+var RoutingPolicyFlags = []cli.Flag{
+  &cli.StringFlag{
+    Name: "set-id",
+    Usage: "set identifier of weighted, failover, latency and geolocation records",
+  },
+  &cli.Int64Flag{
+    Name: "weight",
+    Usage: "weight of a weighted record (0-255)",
+  },
+  &cli.StringFlag{
+    Name: "failover",
+    Usage: "PRIMARY or SECONDARY",
+  },
+  &cli.StringFlag{
+    Name: "region",
+    Usage: "AWS region of a latency record",
+  },
+  &cli.StringFlag{
+    Name: "geo-continent",
+    Usage: "continent code of a geolocation record",
+  },
+  &cli.StringFlag{
+    Name: "geo-country",
+    Usage: "country code of a geolocation record (\"*\" for the default location)",
+  },
+  &cli.StringFlag{
+    Name: "geo-subdivision",
+    Usage: "subdivision code of a geolocation record",
+  },
+  &cli.StringFlag{
+    Name: "health-check-id",
+    Usage: "health check associated with the record",
+  },
+}
+
+// RoutingPolicyFromContext reads the routing policy flags of a command.
+func RoutingPolicyFromContext(c *cli.Context) (policy RoutingPolicy, err error) {
+  policy = RoutingPolicy{
+    SetIdentifier: c.String("set-id"),
+    Failover: strings.ToUpper(c.String("failover")),
+    GeoContinentCode: strings.ToUpper(c.String("geo-continent")),
+    GeoCountryCode: strings.ToUpper(c.String("geo-country")),
+    GeoSubdivisionCode: strings.ToUpper(c.String("geo-subdivision")),
+    HealthCheckID: c.String("health-check-id"),
+  }
+  if c.IsSet("region") {
+    policy.Region = c.String("region")
+  }
+  if c.IsSet("weight") {
+    policy.Weight = aws.Int64(c.Int64("weight"))
+  }
+  return policy, policy.Validate()
+}
+
+// IsEmpty reports whether no routing policy is set.
+func (policy RoutingPolicy) IsEmpty() bool {
+  return policy == RoutingPolicy{}
+}
+
+// hasGeoLocation reports whether one of the geolocation codes is set.
+func (policy RoutingPolicy) hasGeoLocation() bool {
+  return len(policy.GeoContinentCode) > 0 || len(policy.GeoCountryCode) > 0 || len(policy.GeoSubdivisionCode) > 0
+}
+
+// Validate checks that at most one policy is set and that a set identifier
+// is given with it.
+func (policy RoutingPolicy) Validate() (err error) {
+  policies := 0
+  if policy.Weight != nil {
+    policies++
+    if *policy.Weight < 0 || *policy.Weight > 255 {
+      return fmt.Errorf("weight must be between 0 and 255: %d", *policy.Weight)
+    }
+  }
+  if len(policy.Failover) > 0 {
+    policies++
+    if policy.Failover != route53.ResourceRecordSetFailoverPrimary && policy.Failover != route53.ResourceRecordSetFailoverSecondary {
+      return fmt.Errorf("failover must be PRIMARY or SECONDARY: %s", policy.Failover)
+    }
+  }
+  if len(policy.Region) > 0 {
+    policies++
+  }
+  if policy.hasGeoLocation() {
+    policies++
+    if len(policy.GeoContinentCode) > 0 && len(policy.GeoCountryCode) > 0 {
+      return fmt.Errorf("choose geo-continent or geo-country")
+    }
+    if len(policy.GeoSubdivisionCode) > 0 && policy.GeoCountryCode != "US" {
+      return fmt.Errorf("geo-subdivision requires geo-country US")
+    }
+  }
+  if policies > 1 {
+    return fmt.Errorf("choose one of weight, failover, region or geolocation")
+  }
+  if policies == 1 && len(policy.SetIdentifier) == 0 {
+    return fmt.Errorf("set-id is required with a routing policy")
+  }
+  return nil
+}
+
+// HasPolicy reports whether one of weight, failover, region or geolocation
+// is set. A set identifier alone only picks an existing record set.
+func (policy RoutingPolicy) HasPolicy() bool {
+  return policy.Weight != nil || len(policy.Failover) > 0 || len(policy.Region) > 0 || policy.hasGeoLocation()
+}
+
+// Apply sets the fields of the policy which are set on rrset.
+func (policy RoutingPolicy) Apply(rrset *route53.ResourceRecordSet) {
+  if len(policy.SetIdentifier) > 0 {
+    rrset.SetIdentifier = aws.String(policy.SetIdentifier)
+  }
+  if policy.Weight != nil {
+    rrset.Weight = aws.Int64(*policy.Weight)
+  }
+  if len(policy.Failover) > 0 {
+    rrset.Failover = aws.String(policy.Failover)
+  }
+  if len(policy.Region) > 0 {
+    rrset.Region = aws.String(policy.Region)
+  }
+  if policy.hasGeoLocation() {
+    rrset.GeoLocation = &route53.GeoLocation{}
+    if len(policy.GeoContinentCode) > 0 {
+      rrset.GeoLocation.ContinentCode = aws.String(policy.GeoContinentCode)
+    }
+    if len(policy.GeoCountryCode) > 0 {
+      rrset.GeoLocation.CountryCode = aws.String(policy.GeoCountryCode)
+    }
+    if len(policy.GeoSubdivisionCode) > 0 {
+      rrset.GeoLocation.SubdivisionCode = aws.String(policy.GeoSubdivisionCode)
+    }
+  }
+  if len(policy.HealthCheckID) > 0 {
+    rrset.HealthCheckId = aws.String(policy.HealthCheckID)
+  }
+}
+
+// copyRoutingPolicy copies the routing policy fields of src to dst.
+func copyRoutingPolicy(dst *route53.ResourceRecordSet, src *route53.ResourceRecordSet) {
+  dst.SetIdentifier = src.SetIdentifier
+  dst.Weight = src.Weight
+  dst.Failover = src.Failover
+  dst.Region = src.Region
+  dst.GeoLocation = src.GeoLocation
+  dst.HealthCheckId = src.HealthCheckId
+  dst.MultiValueAnswer = src.MultiValueAnswer
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+  "net"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestRoutingPolicyValidate(t *testing.T) {
+  patterns := []struct{
+    policy RoutingPolicy
+    expectedError string
+  }{
+    { RoutingPolicy{}, "" },
+    { RoutingPolicy{SetIdentifier: "blue"}, "" },
+    { RoutingPolicy{SetIdentifier: "blue", Weight: aws.Int64(10)}, "" },
+    { RoutingPolicy{SetIdentifier: "blue", Weight: aws.Int64(256)}, "weight must be between 0 and 255: 256" },
+    { RoutingPolicy{Weight: aws.Int64(10)}, "set-id is required with a routing policy" },
+    { RoutingPolicy{SetIdentifier: "dr", Failover: "PRIMARY"}, "" },
+    { RoutingPolicy{SetIdentifier: "dr", Failover: "TERTIARY"}, "failover must be PRIMARY or SECONDARY: TERTIARY" },
+    { RoutingPolicy{SetIdentifier: "tokyo", Region: "ap-northeast-1"}, "" },
+    { RoutingPolicy{SetIdentifier: "jp", GeoCountryCode: "JP"}, "" },
+    { RoutingPolicy{SetIdentifier: "ca", GeoCountryCode: "US", GeoSubdivisionCode: "CA"}, "" },
+    { RoutingPolicy{SetIdentifier: "x", GeoCountryCode: "JP", GeoSubdivisionCode: "13"}, "geo-subdivision requires geo-country US" },
+    { RoutingPolicy{SetIdentifier: "x", GeoContinentCode: "AS", GeoCountryCode: "JP"}, "choose geo-continent or geo-country" },
+    { RoutingPolicy{SetIdentifier: "x", Weight: aws.Int64(1), Region: "us-east-1"}, "choose one of weight, failover, region or geolocation" },
+  }
+
+  for idx, p := range patterns {
+    err := p.policy.Validate()
+    if err == nil && len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+    } else if err != nil && err.Error() != p.expectedError {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+    }
+  }
+}
+
+func TestRoutingPolicyApply(t *testing.T) {
+  rrset := newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")
+  RoutingPolicy{SetIdentifier: "us", GeoCountryCode: "US", GeoSubdivisionCode: "CA", HealthCheckID: "hc-1"}.Apply(rrset)
+
+  expected := newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")
+  expected.SetIdentifier = aws.String("us")
+  expected.GeoLocation = &route53.GeoLocation{
+    CountryCode: aws.String("US"),
+    SubdivisionCode: aws.String("CA"),
+  }
+  expected.HealthCheckId = aws.String("hc-1")
+
+  if awsutil.StringValue(rrset) != awsutil.StringValue(expected) {
+    t.Errorf("unexpected record set: expected %v, actual %v", expected, rrset)
+  }
+}
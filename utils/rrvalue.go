@@ -0,0 +1,239 @@
+package utils
+
+import (
+  "fmt"
+  "net"
+  "strconv"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// maxTxtStringLength is the longest character-string a TXT record can hold.
+const maxTxtStringLength = 255
+
+// caaTags are the CAA property tags defined by RFC 8659.
+var caaTags = map[string]bool{
+  "issue": true,
+  "issuewild": true,
+  "iodef": true,
+}
+
+// NewResourceRecordSet builds a record set of rrType with the values
+// validated and normalized for Route53. Domain names in the values are
+// relative to zoneName unless they end with a dot.
+func NewResourceRecordSet(name string, rrType string, ttl int64, values []string, zoneName string) (rrset *route53.ResourceRecordSet, err error) {
+  if len(values) == 0 {
+    return nil, fmt.Errorf("%s %s: no value", name, rrType)
+  }
+  if ttl <= 0 {
+    ttl = 600
+  }
+  rrset = &route53.ResourceRecordSet{
+    Name: aws.String(Fqdn(name, zoneName)),
+    TTL: aws.Int64(ttl),
+    Type: aws.String(rrType),
+  }
+  for _, value := range values {
+    normalized, err := NormalizeRecordValue(rrType, value, zoneName)
+    if err != nil {
+      return nil, fmt.Errorf("%s %s: %v", name, rrType, err)
+    }
+    rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(normalized)})
+  }
+  if rrType == route53.RRTypeCname && len(rrset.ResourceRecords) > 1 {
+    return nil, fmt.Errorf("%s %s: only one value is allowed", name, rrType)
+  }
+  return rrset, nil
+}
+
+// NormalizeRecordValue validates value as the rdata of rrType and returns it
+// in the presentation format Route53 expects.
+func NormalizeRecordValue(rrType string, value string, zoneName string) (normalized string, err error) {
+  switch rrType {
+  case route53.RRTypeA, route53.RRTypeAaaa:
+    ip := net.ParseIP(value)
+    if ip == nil || AddressRecordType(ip) != rrType {
+      return "", fmt.Errorf("invalid %s value: %s", rrType, value)
+    }
+    return ip.String(), nil
+  case route53.RRTypeCname, route53.RRTypeNs, route53.RRTypePtr:
+    return normalizeDomainName(value, zoneName)
+  case route53.RRTypeMx:
+    fields := strings.Fields(value)
+    if len(fields) != 2 {
+      return "", fmt.Errorf("MX value must be \"priority exchange\": %s", value)
+    }
+    err = checkUint16("priority", fields[0])
+    if err != nil {
+      return "", err
+    }
+    exchange, err := normalizeDomainName(fields[1], zoneName)
+    if err != nil {
+      return "", err
+    }
+    return strings.Join([]string{fields[0], exchange}, " "), nil
+  case route53.RRTypeSrv:
+    fields := strings.Fields(value)
+    if len(fields) != 4 {
+      return "", fmt.Errorf("SRV value must be \"priority weight port target\": %s", value)
+    }
+    for idx, label := range []string{"priority", "weight", "port"} {
+      err = checkUint16(label, fields[idx])
+      if err != nil {
+        return "", err
+      }
+    }
+    target, err := normalizeDomainName(fields[3], zoneName)
+    if err != nil {
+      return "", err
+    }
+    return strings.Join([]string{fields[0], fields[1], fields[2], target}, " "), nil
+  case route53.RRTypeCaa:
+    fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+    if len(fields) != 3 {
+      return "", fmt.Errorf("CAA value must be \"flags tag value\": %s", value)
+    }
+    flags, err := strconv.ParseUint(fields[0], 10, 8)
+    if err != nil {
+      return "", fmt.Errorf("invalid CAA flags: %s", fields[0])
+    }
+    tag := strings.ToLower(fields[1])
+    if !caaTags[tag] {
+      return "", fmt.Errorf("unknown CAA tag: %s", fields[1])
+    }
+    caaValue := strings.TrimSpace(fields[2])
+    if !isQuoted(caaValue) {
+      caaValue = quoteCharacterString(caaValue)
+    }
+    return fmt.Sprintf("%d %s %s", flags, tag, caaValue), nil
+  case route53.RRTypeTxt, route53.RRTypeSpf:
+    return QuoteTxtValue(value)
+  default:
+    return "", fmt.Errorf("unsupported record type: %s", rrType)
+  }
+}
+
+// QuoteTxtValue returns value as a sequence of quoted character-strings.
+// An unquoted value is escaped and split into strings of at most 255 bytes.
+// A value which is already quoted is checked and returned as it is.
+func QuoteTxtValue(value string) (quoted string, err error) {
+  if isQuoted(value) {
+    strs, err := splitCharacterStrings(value)
+    if err != nil {
+      return "", err
+    }
+    for _, s := range strs {
+      if unescapedLength(s) > maxTxtStringLength {
+        return "", fmt.Errorf("TXT string longer than %d bytes: %s", maxTxtStringLength, s)
+      }
+    }
+    return value, nil
+  }
+
+  var chunks []string
+  for len(value) > maxTxtStringLength {
+    chunks = append(chunks, quoteCharacterString(value[:maxTxtStringLength]))
+    value = value[maxTxtStringLength:]
+  }
+  chunks = append(chunks, quoteCharacterString(value))
+  return strings.Join(chunks, " "), nil
+}
+
+func isQuoted(value string) bool {
+  return strings.HasPrefix(value, `"`)
+}
+
+// quoteCharacterString escapes and quotes s as a single character-string.
+func quoteCharacterString(s string) string {
+  var b strings.Builder
+  b.WriteByte('"')
+  for i := 0; i < len(s); i++ {
+    ch := s[i]
+    switch {
+    case ch == '"' || ch == '\\':
+      b.WriteByte('\\')
+      b.WriteByte(ch)
+    case ch < 0x20 || ch >= 0x7f:
+      fmt.Fprintf(&b, "\\%03d", ch)
+    default:
+      b.WriteByte(ch)
+    }
+  }
+  b.WriteByte('"')
+  return b.String()
+}
+
+// splitCharacterStrings splits a sequence of quoted character-strings,
+// returning the contents without the quotes.
+func splitCharacterStrings(value string) (strs []string, err error) {
+  i := 0
+  for i < len(value) {
+    if value[i] == ' ' {
+      i++
+      continue
+    }
+    if value[i] != '"' {
+      return nil, fmt.Errorf("invalid TXT value: %s", value)
+    }
+    j := i + 1
+    for j < len(value) && value[j] != '"' {
+      if value[j] == '\\' {
+        j++
+      }
+      j++
+    }
+    if j >= len(value) {
+      return nil, fmt.Errorf("unterminated TXT string: %s", value)
+    }
+    strs = append(strs, value[i+1:j])
+    i = j + 1
+  }
+  return strs, nil
+}
+
+// unescapedLength returns the length of s in bytes after resolving
+// the "\c" and "\DDD" escapes.
+func unescapedLength(s string) (n int) {
+  for i := 0; i < len(s); i++ {
+    if s[i] == '\\' {
+      if i+4 <= len(s) && isDigits(s[i+1:i+4]) {
+        i += 3
+      } else {
+        i++
+      }
+    }
+    n++
+  }
+  return n
+}
+
+func isDigits(s string) bool {
+  for _, r := range s {
+    if r < '0' || r > '9' {
+      return false
+    }
+  }
+  return len(s) > 0
+}
+
+func checkUint16(label string, value string) (err error) {
+  if _, err := strconv.ParseUint(value, 10, 16); err != nil {
+    return fmt.Errorf("invalid %s: %s", label, value)
+  }
+  return nil
+}
+
+func normalizeDomainName(name string, zoneName string) (fqdn string, err error) {
+  if len(name) == 0 || strings.ContainsAny(name, " \t\"") {
+    return "", fmt.Errorf("invalid domain name: %q", name)
+  }
+  if name == "." {
+    return name, nil
+  }
+  if strings.Contains(strings.TrimSuffix(name, "."), "..") {
+    return "", fmt.Errorf("invalid domain name: %q", name)
+  }
+  return Fqdn(name, zoneName), nil
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestNormalizeRecordValue(t *testing.T) {
+  long := strings.Repeat("a", 300)
+
+  patterns := []struct{
+    rrType string
+    value string
+    expected string
+    expectedError string
+  }{
+    { "MX", "10 mail", "10 mail.example.com.", "" },
+    { "MX", "10 mail.example.net.", "10 mail.example.net.", "" },
+    { "MX", "70000 mail", "", "invalid priority: 70000" },
+    { "MX", "mail", "", `MX value must be "priority exchange": mail` },
+    { "SRV", "10 60 5060 sip.example.com", "10 60 5060 sip.example.com.", "" },
+    { "SRV", "10 60 http sip", "", "invalid port: http" },
+    { "CAA", "0 issue letsencrypt.org", `0 issue "letsencrypt.org"`, "" },
+    { "CAA", `128 iodef "mailto:security@example.com"`, `128 iodef "mailto:security@example.com"`, "" },
+    { "CAA", "256 issue letsencrypt.org", "", "invalid CAA flags: 256" },
+    { "CAA", "0 issuer letsencrypt.org", "", "unknown CAA tag: issuer" },
+    { "NS", "ns-1.awsdns-00.com.", "ns-1.awsdns-00.com.", "" },
+    { "NS", "ns..example.com", "", `invalid domain name: "ns..example.com"` },
+    { "TXT", "v=spf1 -all", `"v=spf1 -all"`, "" },
+    { "TXT", `say "hi"`, `"say \"hi\""`, "" },
+    { "TXT", `"already" "quoted"`, `"already" "quoted"`, "" },
+    { "TXT", long, `"` + long[:255] + `" "` + long[255:] + `"`, "" },
+    { "TXT", `"` + long + `"`, "", "TXT string longer than 255 bytes: " + long },
+    { "TXT", `"unterminated`, "", `unterminated TXT string: "unterminated` },
+    { "A", "10.0.0.1", "10.0.0.1", "" },
+    { "A", "2001:db8::1", "", "invalid A value: 2001:db8::1" },
+    { "HINFO", "x86 linux", "", "unsupported record type: HINFO" },
+  }
+
+  for idx, p := range patterns {
+    actual, err := NormalizeRecordValue(p.rrType, p.value, "example.com")
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(p.expectedError) > 0 {
+      t.Errorf("expected error (%d): %s", idx, p.expectedError)
+    }
+    if actual != p.expected {
+      t.Errorf("pattern %d: want %s, actual %s", idx, p.expected, actual)
+    }
+  }
+}
+
+func TestNewResourceRecordSet(t *testing.T) {
+  rrset, err := NewResourceRecordSet("@", "MX", 0, []string{"10 mail1", "20 mail2.example.com."}, "example.com")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  expected := "example.com. 600 MX 10 mail1.example.com. 20 mail2.example.com."
+  if FormatResourceRecordSet(rrset) != expected {
+    t.Errorf("unexpected record set: expected %s, actual %s", expected, FormatResourceRecordSet(rrset))
+  }
+
+  _, err = NewResourceRecordSet("www", "TXT", 300, nil, "example.com")
+  if err == nil || err.Error() != "www TXT: no value" {
+    t.Errorf("unexpected error: %v", err)
+  }
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+  "fmt"
+  "io"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// DefaultStatusInterval is how often status --wait polls, which is what the
+// SDK waiter of WaitUntilResourceRecordSetsChanged does as well.
+const DefaultStatusInterval = 30 * time.Second
+
+// ChangeStatus is the status of a change batch submitted to Route53.
+type ChangeStatus struct {
+  ID string `json:"id"`
+  Status string `json:"status"`
+  SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// InSync reports whether the change is propagated to all the Route53 name
+// servers.
+func (status ChangeStatus) InSync() bool {
+  return status.Status == route53.ChangeStatusInsync
+}
+
+// GetChangeStatuses returns the status of the changes ids, which are given
+// as printed by --no-wait ("/change/C2682N5HXP0BZ4") or without the prefix.
+func (client *AWSClientImpl) GetChangeStatuses(ids []string) (statuses []ChangeStatus, err error) {
+  for _, id := range ids {
+    resp, err := client.r53.GetChangeWithContext(client.ctx, &route53.GetChangeInput{Id: aws.String(id)})
+    if err != nil {
+      return nil, fmt.Errorf("change %s: %v", id, canceledError(client.ctx, err))
+    }
+    statuses = append(statuses, ChangeStatus{
+      ID: aws.StringValue(resp.ChangeInfo.Id),
+      Status: aws.StringValue(resp.ChangeInfo.Status),
+      SubmittedAt: aws.TimeValue(resp.ChangeInfo.SubmittedAt),
+    })
+  }
+  return statuses, nil
+}
+
+// WaitChangeStatuses polls the status of the changes ids every interval
+// until they are all in sync, and returns their final status. It gives up
+// when the context of the client is done.
+func (client *AWSClientImpl) WaitChangeStatuses(ids []string, interval time.Duration) (statuses []ChangeStatus, err error) {
+  pending := ids
+  done := map[string]ChangeStatus{}
+  for {
+    current, err := client.GetChangeStatuses(pending)
+    if err != nil {
+      return nil, err
+    }
+    var next []string
+    for idx, status := range current {
+      if status.InSync() {
+        done[pending[idx]] = status
+      } else {
+        next = append(next, pending[idx])
+      }
+    }
+    if len(next) == 0 {
+      break
+    }
+    pending = next
+    err = sleepContext(client.ctx, interval)
+    if err != nil {
+      return nil, fmt.Errorf("%d of %d changes are not in sync: %v", len(pending), len(ids), canceledError(client.ctx, err))
+    }
+  }
+
+  for _, id := range ids {
+    statuses = append(statuses, done[id])
+  }
+  return statuses, nil
+}
+
+// WriteChangeStatuses writes statuses to w as text or json.
+func WriteChangeStatuses(w io.Writer, statuses []ChangeStatus, format string) (err error) {
+  switch format {
+  case "", "text":
+    for _, status := range statuses {
+      _, err = fmt.Fprintf(w, "%s\t%s\t%s\n", status.ID, status.Status, status.SubmittedAt.Local().Format(time.RFC3339))
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  case "json":
+    if statuses == nil {
+      statuses = []ChangeStatus{}
+    }
+    return WriteJSON(w, statuses)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
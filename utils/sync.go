@@ -0,0 +1,331 @@
+package utils
+
+import (
+  "fmt"
+  "net"
+  "sort"
+  "strings"
+
+  "github.com/BurntSushi/toml"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// ```
+// [[Host]]
+// Name = "web1"
+// IP = "10.0.0.1"
+// [[Host]]
+// Name = "web1"
+// IP = "2001:db8::1"
+// [[Host]]
+// Name = "www.example.com."
+// CNAME = "web1.example.com."
+// TTL = 300
+// ```
+
+// DesiredState is the list of hosts a hosted zone should contain.
+type DesiredState struct {
+  Hosts []DesiredHost `toml:"Host"`
+}
+
+// DesiredHost is a host in the desired state file. Name is either a FQDN
+// or a name relative to the hosted zone. Exactly one of IP and CNAME is set.
+type DesiredHost struct {
+  Name string `toml:"Name"`
+  IP string `toml:"IP"`
+  CNAME string `toml:"CNAME"`
+  TTL int64 `toml:"TTL"`
+}
+
+// syncManagedTypes are the record types sync creates, updates and deletes.
+var syncManagedTypes = map[string]bool{
+  route53.RRTypeA: true,
+  route53.RRTypeAaaa: true,
+  route53.RRTypeCname: true,
+}
+
+// LoadDesiredState ...
+func LoadDesiredState(path string, state *DesiredState) (err error) {
+  if _, err := toml.DecodeFile(path, state); err != nil {
+    return err
+  }
+  return nil
+}
+
+// DesiredResourceRecordSets converts the desired state into record sets.
+// Hosts with the same name and type are merged into one record set. An
+// address can only be used once, since it has a single PTR record.
+func DesiredResourceRecordSets(state DesiredState, zoneName string) (rrsets []*route53.ResourceRecordSet, err error) {
+  index := map[string]*route53.ResourceRecordSet{}
+  ips := map[string]bool{}
+  for _, host := range state.Hosts {
+    if len(host.IP) > 0 && len(host.CNAME) > 0 {
+      return nil, fmt.Errorf("%s: choose IP or CNAME", host.Name)
+    }
+
+    ttl := host.TTL
+    if ttl == 0 {
+      ttl = 600
+    }
+
+    var rrType, value string
+    if len(host.IP) > 0 {
+      ip := net.ParseIP(host.IP)
+      if ip == nil {
+        return nil, fmt.Errorf("%s: invalid IP: %s", host.Name, host.IP)
+      }
+      rrType = AddressRecordType(ip)
+      value = ip.String()
+      if ips[value] {
+        return nil, fmt.Errorf("%s: duplicate ip: %s", host.Name, value)
+      }
+      ips[value] = true
+    } else if len(host.CNAME) > 0 {
+      rrType = route53.RRTypeCname
+      value = host.CNAME
+    } else {
+      return nil, fmt.Errorf("%s: choose IP or CNAME", host.Name)
+    }
+
+    name := strings.ToLower(Fqdn(host.Name, zoneName))
+    key := name + " " + rrType
+    rrset, ok := index[key]
+    if !ok {
+      rrset = &route53.ResourceRecordSet{
+        Name: aws.String(name),
+        TTL: aws.Int64(ttl),
+        Type: aws.String(rrType),
+      }
+      index[key] = rrset
+      rrsets = append(rrsets, rrset)
+    } else if rrType == route53.RRTypeCname {
+      return nil, fmt.Errorf("%s: multiple CNAME records", name)
+    }
+    rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(value)})
+  }
+  return rrsets, nil
+}
+
+// PlanSync builds the plan which reconciles the hosted zone with the desired
+// state, together with the PTR records of the address records. Records of
+// other types, alias records and records with a set identifier are left
+// untouched. When prune is false, records missing from state are kept. A PTR
+// record which points at a host sync keeps is refused unless replacePtr is
+// set.
+func (client *AWSClientImpl) PlanSync(hostedZoneID string, zoneName string, state DesiredState, rInfos ReverseHostedZoneInfos, prune bool, replacePtr bool) (plan *ChangePlan, err error) {
+  desired, err := DesiredResourceRecordSets(state, zoneName)
+  if err != nil {
+    return nil, err
+  }
+
+  current, err := client.ListAllResourceRecords(hostedZoneID)
+  if err != nil {
+    return nil, err
+  }
+
+  reverse := map[string][]*route53.ResourceRecordSet{}
+  for _, rInfo := range rInfos.ReverseHostedZoneInfo {
+    for _, id := range []string{rInfo.HostedZoneID, rInfo.ParentHostedZoneID} {
+      if _, ok := reverse[id]; ok || len(id) == 0 {
+        continue
+      }
+      reverse[id], err = client.ListAllResourceRecords(id)
+      if err != nil {
+        return nil, err
+      }
+    }
+  }
+
+  return buildSyncPlan(hostedZoneID, zoneName, desired, current, reverse, rInfos, prune, replacePtr)
+}
+
+func buildSyncPlan(hostedZoneID string, zoneName string, desired []*route53.ResourceRecordSet, current []*route53.ResourceRecordSet, records map[string][]*route53.ResourceRecordSet, rInfos ReverseHostedZoneInfos, prune bool, replacePtr bool) (plan *ChangePlan, err error) {
+  // every desired address gets a PTR record, so all the addresses without
+  // a reverse hosted zone are reported at once
+  var unmapped []string
+  for _, rrset := range desired {
+    if aws.StringValue(rrset.Type) == route53.RRTypeCname {
+      continue
+    }
+    for _, rr := range rrset.ResourceRecords {
+      if len(longestPrefixMatch(net.ParseIP(aws.StringValue(rr.Value)), rInfos)) == 0 {
+        unmapped = append(unmapped, aws.StringValue(rr.Value))
+      }
+    }
+  }
+  if len(unmapped) > 0 {
+    return nil, fmt.Errorf("no reverse hosted zone for %s", strings.Join(unmapped, ", "))
+  }
+
+  currentIndex := map[string]*route53.ResourceRecordSet{}
+  for _, rrset := range current {
+    if !syncManagedTypes[aws.StringValue(rrset.Type)] || rrset.AliasTarget != nil || rrset.SetIdentifier != nil {
+      continue
+    }
+    currentIndex[recordSetKey(rrset)] = rrset
+  }
+  desiredIndex := map[string]*route53.ResourceRecordSet{}
+  for _, rrset := range desired {
+    desiredIndex[recordSetKey(rrset)] = rrset
+  }
+
+  forward := &ChangeStep{HostedZoneID: hostedZoneID, HostedZoneName: zoneName}
+  // PTR records which should exist (name -> hostname) and which should not
+  ptrWanted := map[string]string{}
+  ptrUnwanted := map[string]string{}
+
+  var deletes, upserts, creates []*route53.ResourceRecordSet
+  for _, key := range sortedRecordSetKeys(currentIndex) {
+    if _, ok := desiredIndex[key]; !ok && prune {
+      deletes = append(deletes, currentIndex[key])
+    }
+  }
+  for _, key := range sortedRecordSetKeys(desiredIndex) {
+    rrset := desiredIndex[key]
+    previous, ok := currentIndex[key]
+    if !ok {
+      creates = append(creates, rrset)
+    } else if !EqualResourceRecordSet(previous, rrset) {
+      upserts = append(upserts, rrset)
+    }
+  }
+
+  for _, rrset := range deletes {
+    forward.AppendChange(route53.ChangeActionDelete, rrset, nil)
+    collectPtrNames(rrset, ptrUnwanted)
+  }
+  for _, rrset := range upserts {
+    previous := currentIndex[recordSetKey(rrset)]
+    forward.AppendChange(route53.ChangeActionUpsert, rrset, previous)
+    collectPtrNames(previous, ptrUnwanted)
+  }
+  for _, rrset := range creates {
+    forward.AppendChange(route53.ChangeActionCreate, rrset, nil)
+  }
+  for _, rrset := range desired {
+    collectPtrNames(rrset, ptrWanted)
+  }
+
+  plan = &ChangePlan{}
+  plan.AddChangeStep(forward)
+
+  reverse := &changeSteps{}
+  reverseStep := func(ip net.IP) (ReverseHostedZoneInfo, *ChangeStep, []*route53.ResourceRecordSet, error) {
+    rInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
+    if err != nil {
+      return rInfo, nil, nil, err
+    }
+    return rInfo, reverse.step(rInfo.HostedZoneID, rInfo.HostedZoneName), records[rInfo.HostedZoneID], nil
+  }
+  parentCname := func(rInfo ReverseHostedZoneInfo, ip net.IP) *route53.ResourceRecordSet {
+    return findResourceRecordSet(records[rInfo.ParentHostedZoneID], GenerateReverseRecord(ip), route53.RRTypeCname)
+  }
+
+  for _, ipString := range sortedKeys(ptrUnwanted) {
+    if _, ok := ptrWanted[ipString]; ok {
+      continue
+    }
+    ip := net.ParseIP(ipString)
+    if len(longestPrefixMatch(ip, rInfos)) == 0 {
+      // the removed address has no PTR record to clean up
+      continue
+    }
+    rInfo, step, zoneRecords, err := reverseStep(ip)
+    if err != nil {
+      return nil, err
+    }
+    ptr := findResourceRecordSet(zoneRecords, rInfo.PtrRecordName(ip), route53.RRTypePtr)
+    // only remove PTR records which still point at the removed host
+    if ptr != nil && len(ptr.ResourceRecords) == 1 && strings.EqualFold(aws.StringValue(ptr.ResourceRecords[0].Value), ptrUnwanted[ipString]) {
+      step.AppendChange(route53.ChangeActionDelete, ptr, nil)
+      deleteParentCname(reverse, rInfo, ip, parentCname(rInfo, ip))
+    }
+  }
+  // the PTR records of the removed and updated hosts can be taken over
+  var removed []string
+  for _, ipString := range sortedKeys(ptrUnwanted) {
+    removed = append(removed, ptrUnwanted[ipString])
+  }
+  for _, ipString := range sortedKeys(ptrWanted) {
+    ip := net.ParseIP(ipString)
+    rInfo, step, zoneRecords, err := reverseStep(ip)
+    if err != nil {
+      return nil, err
+    }
+    wanted := rInfo.ptrResourceRecordSet(ip, ptrWanted[ipString])
+    previous := findResourceRecordSet(zoneRecords, aws.StringValue(wanted.Name), route53.RRTypePtr)
+    if previous != nil && !replacePtr && !ptrPointsAt(previous, ptrWanted[ipString]) && !ptrPointsAtAny(previous, removed) {
+      return nil, fmt.Errorf("PTR record %s points at %s, use --replace-ptr to point it at %s", aws.StringValue(wanted.Name), strings.Join(ptrValues(previous), " "), ptrWanted[ipString])
+    }
+    if previous == nil || !EqualResourceRecordSet(previous, wanted) {
+      step.AppendChange(route53.ChangeActionUpsert, wanted, previous)
+    }
+    upsertParentCname(reverse, rInfo, ip, parentCname(rInfo, ip))
+  }
+
+  reverse.addTo(plan)
+  return plan, nil
+}
+
+// collectPtrNames records the hostname of every address in rrset.
+func collectPtrNames(rrset *route53.ResourceRecordSet, ptrs map[string]string) {
+  switch aws.StringValue(rrset.Type) {
+  case route53.RRTypeA, route53.RRTypeAaaa:
+    for _, rr := range rrset.ResourceRecords {
+      ip := net.ParseIP(aws.StringValue(rr.Value))
+      if ip != nil {
+        ptrs[ip.String()] = aws.StringValue(rrset.Name)
+      }
+    }
+  }
+}
+
+func recordSetKey(rrset *route53.ResourceRecordSet) string {
+  return strings.ToLower(aws.StringValue(rrset.Name)) + " " + aws.StringValue(rrset.Type)
+}
+
+func sortedRecordSetKeys(index map[string]*route53.ResourceRecordSet) (keys []string) {
+  for key := range index {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+func sortedKeys(m map[string]string) (keys []string) {
+  for key := range m {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+// findResourceRecordSet returns the record set without a set identifier
+// which has the name and type.
+func findResourceRecordSet(rrsets []*route53.ResourceRecordSet, name string, rrType string) *route53.ResourceRecordSet {
+  for _, rrset := range rrsets {
+    if rrset.SetIdentifier == nil && strings.EqualFold(aws.StringValue(rrset.Name), name) && aws.StringValue(rrset.Type) == rrType {
+      return rrset
+    }
+  }
+  return nil
+}
+
+// EqualResourceRecordSet reports whether a and b have the same name, type,
+// TTL and values. The order of the values is ignored.
+func EqualResourceRecordSet(a *route53.ResourceRecordSet, b *route53.ResourceRecordSet) bool {
+  if recordSetKey(a) != recordSetKey(b) || aws.Int64Value(a.TTL) != aws.Int64Value(b.TTL) {
+    return false
+  }
+  return strings.Join(sortedValues(a), " ") == strings.Join(sortedValues(b), " ")
+}
+
+func sortedValues(rrset *route53.ResourceRecordSet) (values []string) {
+  for _, rr := range rrset.ResourceRecords {
+    values = append(values, strings.ToLower(aws.StringValue(rr.Value)))
+  }
+  sort.Strings(values)
+  return values
+}
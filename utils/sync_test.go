@@ -0,0 +1,338 @@
+package utils
+
+import (
+  "net"
+  "strings"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestFqdn(t *testing.T) {
+  patterns := []struct{
+    name string
+    zoneName string
+    expected string
+  }{
+    { "www", "example.com", "www.example.com." },
+    { "www", "example.com.", "www.example.com." },
+    { "www.example.net.", "example.com.", "www.example.net." },
+    { "@", "example.com", "example.com." },
+    { "", "example.com.", "example.com." },
+    { "www.example.com", "example.com.", "www.example.com." },
+    { "Example.COM", "example.com", "Example.COM." },
+    { "www.myexample.com", "example.com", "www.myexample.com.example.com." },
+  }
+
+  for idx, p := range patterns {
+    actual := Fqdn(p.name, p.zoneName)
+    if actual != p.expected {
+      t.Errorf("pattern %d: want %s, actual %s", idx, p.expected, actual)
+    }
+  }
+}
+
+func TestDesiredResourceRecordSets(t *testing.T) {
+  patterns := []struct{
+    state DesiredState
+    expected []string
+    expectedError string
+  }{
+    {
+      state: DesiredState{
+        Hosts: []DesiredHost{
+          { Name: "web1", IP: "10.0.0.1" },
+          { Name: "web1", IP: "10.0.0.2" },
+          { Name: "web1", IP: "2001:db8::1", TTL: 300 },
+          { Name: "www.example.com.", CNAME: "web1.example.com." },
+        },
+      },
+      expected: []string{
+        "web1.example.com. 600 A 10.0.0.1 10.0.0.2",
+        "web1.example.com. 300 AAAA 2001:db8::1",
+        "www.example.com. 600 CNAME web1.example.com.",
+      },
+    },
+    {
+      state: DesiredState{
+        Hosts: []DesiredHost{
+          { Name: "web1", IP: "10.0.0.1", CNAME: "web2.example.com." },
+        },
+      },
+      expectedError: "web1: choose IP or CNAME",
+    },
+    {
+      state: DesiredState{
+        Hosts: []DesiredHost{
+          { Name: "web1", IP: "10.0.0.256" },
+        },
+      },
+      expectedError: "web1: invalid IP: 10.0.0.256",
+    },
+    {
+      state: DesiredState{
+        Hosts: []DesiredHost{
+          { Name: "web1", IP: "10.0.0.1" },
+          { Name: "web2", IP: "10.0.0.1" },
+        },
+      },
+      expectedError: "web2: duplicate ip: 10.0.0.1",
+    },
+  }
+
+  for idx, p := range patterns {
+    rrsets, err := DesiredResourceRecordSets(p.state, "example.com.")
+    if err != nil {
+      if err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if len(rrsets) != len(p.expected) {
+      t.Errorf("unexpected record sets (%d): expected %v, actual %v", idx, p.expected, rrsets)
+      continue
+    }
+    for i, rrset := range rrsets {
+      if FormatResourceRecordSet(rrset) != p.expected[i] {
+        t.Errorf("unexpected record set (%d-%d): expected %s, actual %s", idx, i, p.expected[i], FormatResourceRecordSet(rrset))
+      }
+    }
+  }
+}
+
+func TestBuildSyncPlan(t *testing.T) {
+  rInfos := ReverseHostedZoneInfos{
+    ReverseHostedZoneInfo: []ReverseHostedZoneInfo{
+      {
+        Network: &net.IPNet{
+          IP: net.IPv4(10,0,0,0),
+          Mask: net.IPv4Mask(255,0,0,0),
+        },
+        NetworkCIDR: "10.0.0.0/8",
+        HostedZoneID: "REV123",
+        HostedZoneName: "10.in-addr.arpa.",
+      },
+    },
+  }
+  current := []*route53.ResourceRecordSet{
+    { Name: aws.String("example.com."), Type: aws.String(route53.RRTypeNs), TTL: aws.Int64(172800),
+      ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("ns-1.awsdns-00.com.")}} },
+    newAddressResourceRecordSet(net.ParseIP("10.0.0.1"), "old.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("10.0.0.2"), "web1.example.com."),
+    newAddressResourceRecordSet(net.ParseIP("10.0.0.3"), "web2.example.com."),
+  }
+  reverse := map[string][]*route53.ResourceRecordSet{
+    "REV123": {
+      newPtrResourceRecordSet(net.ParseIP("10.0.0.1"), "old.example.com."),
+      newPtrResourceRecordSet(net.ParseIP("10.0.0.2"), "web1.example.com."),
+    },
+  }
+  desired, err := DesiredResourceRecordSets(DesiredState{
+    Hosts: []DesiredHost{
+      { Name: "web1", IP: "10.0.0.12" },
+      { Name: "web2", IP: "10.0.0.3" },
+      { Name: "web3", IP: "10.0.0.4" },
+    },
+  }, "example.com.")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  patterns := []struct{
+    prune bool
+    expected []string
+  }{
+    {
+      prune: true,
+      expected: []string{
+        "@@ step 1: example.com. (ABC123) @@",
+        "- old.example.com. 600 A 10.0.0.1",
+        "~ web1.example.com. 600 A 10.0.0.12",
+        "+ web3.example.com. 600 A 10.0.0.4",
+        "@@ step 2: 10.in-addr.arpa. (REV123) @@",
+        "- 1.0.0.10.in-addr.arpa. 600 PTR old.example.com.",
+        "- 2.0.0.10.in-addr.arpa. 600 PTR web1.example.com.",
+        "~ 12.0.0.10.in-addr.arpa. 600 PTR web1.example.com.",
+        "~ 3.0.0.10.in-addr.arpa. 600 PTR web2.example.com.",
+        "~ 4.0.0.10.in-addr.arpa. 600 PTR web3.example.com.",
+        "",
+      },
+    },
+    {
+      prune: false,
+      expected: []string{
+        "@@ step 1: example.com. (ABC123) @@",
+        "~ web1.example.com. 600 A 10.0.0.12",
+        "+ web3.example.com. 600 A 10.0.0.4",
+        "@@ step 2: 10.in-addr.arpa. (REV123) @@",
+        "- 2.0.0.10.in-addr.arpa. 600 PTR web1.example.com.",
+        "~ 12.0.0.10.in-addr.arpa. 600 PTR web1.example.com.",
+        "~ 3.0.0.10.in-addr.arpa. 600 PTR web2.example.com.",
+        "~ 4.0.0.10.in-addr.arpa. 600 PTR web3.example.com.",
+        "",
+      },
+    },
+  }
+
+  for idx, p := range patterns {
+    plan, err := buildSyncPlan("ABC123", "example.com.", desired, current, reverse, rInfos, p.prune, false)
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+      continue
+    }
+    var out strings.Builder
+    err = PrintChangePlan(&out, plan, "text")
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+    }
+    expected := strings.Join(p.expected, "\n")
+    if out.String() != expected {
+      t.Errorf("unexpected plan (%d): expected\n%s\nactual\n%s", idx, expected, out.String())
+    }
+  }
+}
+
+func TestBuildSyncPlanPtrConflict(t *testing.T) {
+  rInfos := ReverseHostedZoneInfos{
+    ReverseHostedZoneInfo: []ReverseHostedZoneInfo{
+      {
+        Network: &net.IPNet{
+          IP: net.IPv4(10,0,0,0),
+          Mask: net.IPv4Mask(255,0,0,0),
+        },
+        NetworkCIDR: "10.0.0.0/8",
+        HostedZoneID: "REV123",
+        HostedZoneName: "10.in-addr.arpa.",
+      },
+    },
+  }
+  current := []*route53.ResourceRecordSet{
+    newAddressResourceRecordSet(net.ParseIP("10.0.0.5"), "api.example.com."),
+  }
+  reverse := map[string][]*route53.ResourceRecordSet{
+    "REV123": {
+      newPtrResourceRecordSet(net.ParseIP("10.0.0.5"), "api.example.com."),
+    },
+  }
+  desired, err := DesiredResourceRecordSets(DesiredState{
+    Hosts: []DesiredHost{
+      { Name: "web1", IP: "10.0.0.5" },
+    },
+  }, "example.com.")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  patterns := []struct{
+    prune bool
+    replacePtr bool
+    expectedError string
+    expectedPtr string
+  }{
+    // api is kept, so its PTR record is not taken over
+    {
+      prune: false,
+      expectedError: "PTR record 5.0.0.10.in-addr.arpa. points at api.example.com., use --replace-ptr to point it at web1.example.com.",
+    },
+    {
+      prune: false,
+      replacePtr: true,
+      expectedPtr: "~ 5.0.0.10.in-addr.arpa. 600 PTR web1.example.com.",
+    },
+    // api is removed by the same sync
+    {
+      prune: true,
+      expectedPtr: "~ 5.0.0.10.in-addr.arpa. 600 PTR web1.example.com.",
+    },
+  }
+
+  for idx, p := range patterns {
+    plan, err := buildSyncPlan("ABC123", "example.com.", desired, current, reverse, rInfos, p.prune, p.replacePtr)
+    if len(p.expectedError) > 0 {
+      if err == nil || err.Error() != p.expectedError {
+        t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+      continue
+    }
+    var out strings.Builder
+    err = PrintChangePlan(&out, plan, "text")
+    if err != nil {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+    }
+    if !strings.Contains(out.String(), p.expectedPtr + "\n") {
+      t.Errorf("pattern %d: want %s, actual\n%s", idx, p.expectedPtr, out.String())
+    }
+  }
+}
+
+func TestBuildSyncPlanWithoutReverseZone(t *testing.T) {
+  rInfos := ReverseHostedZoneInfos{
+    ReverseHostedZoneInfo: []ReverseHostedZoneInfo{
+      {
+        Network: &net.IPNet{
+          IP: net.IPv4(10,0,0,0),
+          Mask: net.IPv4Mask(255,0,0,0),
+        },
+        NetworkCIDR: "10.0.0.0/8",
+        HostedZoneID: "REV123",
+        HostedZoneName: "10.in-addr.arpa.",
+      },
+    },
+  }
+  current := []*route53.ResourceRecordSet{
+    newAddressResourceRecordSet(net.ParseIP("192.168.0.1"), "old.example.com."),
+  }
+  reverse := map[string][]*route53.ResourceRecordSet{"REV123": nil}
+
+  // every address without a reverse hosted zone is listed
+  desired, err := DesiredResourceRecordSets(DesiredState{
+    Hosts: []DesiredHost{
+      { Name: "web1", IP: "10.0.0.1" },
+      { Name: "web2", IP: "192.168.0.2" },
+      { Name: "web3", IP: "2001:db8::3" },
+    },
+  }, "example.com.")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  _, err = buildSyncPlan("ABC123", "example.com.", desired, current, reverse, rInfos, true, false)
+  expectedError := "no reverse hosted zone for 192.168.0.2, 2001:db8::3"
+  if err == nil || err.Error() != expectedError {
+    t.Errorf("unexpected error: expected error %v, actual error %v", expectedError, err)
+  }
+
+  // a removed address without a reverse hosted zone has no PTR record
+  desired, err = DesiredResourceRecordSets(DesiredState{
+    Hosts: []DesiredHost{
+      { Name: "web1", IP: "10.0.0.1" },
+    },
+  }, "example.com.")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  plan, err := buildSyncPlan("ABC123", "example.com.", desired, current, reverse, rInfos, true, false)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  var out strings.Builder
+  err = PrintChangePlan(&out, plan, "text")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  expected := strings.Join([]string{
+    "@@ step 1: example.com. (ABC123) @@",
+    "- old.example.com. 600 A 192.168.0.1",
+    "+ web1.example.com. 600 A 10.0.0.1",
+    "@@ step 2: 10.in-addr.arpa. (REV123) @@",
+    "~ 1.0.0.10.in-addr.arpa. 600 PTR web1.example.com.",
+    "",
+  }, "\n")
+  if out.String() != expected {
+    t.Errorf("unexpected plan: expected\n%s\nactual\n%s", expected, out.String())
+  }
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+  "fmt"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// Interrupted reports whether the command which applied entry died before it
+// finished.
+func (entry *JournalEntry) Interrupted() bool {
+  if entry.Status == JournalPending {
+    return true
+  }
+  for _, step := range entry.Steps {
+    if step.Status == JournalApplying || step.Status == JournalRollingBack {
+      return true
+    }
+  }
+  return false
+}
+
+// LatestInterruptedEntry returns the newest interrupted entry of the journal
+// in dir.
+func LatestInterruptedEntry(dir string) (entry *JournalEntry, err error) {
+  entries, err := ListJournalEntries(dir)
+  if err != nil {
+    return nil, err
+  }
+  for i := len(entries) - 1; i >= 0; i-- {
+    if entries[i].Interrupted() {
+      return entries[i], nil
+    }
+  }
+  return nil, fmt.Errorf("no interrupted journal entry")
+}
+
+// LoadInterruptedEntry reads the entry id from the journal in dir, or the
+// newest interrupted entry when id is empty.
+func LoadInterruptedEntry(dir string, id string) (entry *JournalEntry, err error) {
+  if len(dir) == 0 {
+    return nil, fmt.Errorf("the journal is disabled")
+  }
+  if len(id) == 0 {
+    return LatestInterruptedEntry(dir)
+  }
+  return LoadJournalEntry(dir, id)
+}
+
+// Resume finishes an interrupted entry: the steps which were in flight are
+// settled and the remaining steps are applied. When a step fails, the
+// applied steps are rolled back as ApplyChangePlan does.
+func (client *AWSClientImpl) Resume(entry *JournalEntry) (err error) {
+  if !entry.Interrupted() {
+    return fmt.Errorf("journal entry %s is not interrupted (%s)", entry.ID, entry.Status)
+  }
+  err = client.settleJournalSteps(entry)
+  if err != nil {
+    return err
+  }
+  for _, step := range entry.Steps {
+    if step.Status == JournalRolledBack {
+      return fmt.Errorf("journal entry %s was being rolled back, abort it instead", entry.ID)
+    }
+  }
+  if client.dryRun {
+    return PrintChangePlan(client.out, pendingPlan(entry), client.planFormat)
+  }
+
+  err = client.applyChangeSteps(journalChangeSteps(entry), entry)
+  if err != nil {
+    return err
+  }
+  if len(entry.UndoOf) > 0 {
+    undone, err := LoadJournalEntry(client.journalDir, entry.UndoOf)
+    if err != nil {
+      return err
+    }
+    return markUndone(undone)
+  }
+  return nil
+}
+
+// Abort compensates an interrupted entry: the steps which were in flight are
+// settled and the applied steps are rolled back in reverse order.
+func (client *AWSClientImpl) Abort(entry *JournalEntry) (err error) {
+  if !entry.Interrupted() {
+    return fmt.Errorf("journal entry %s is not interrupted (%s)", entry.ID, entry.Status)
+  }
+  err = client.settleJournalSteps(entry)
+  if err != nil {
+    return err
+  }
+  if client.dryRun {
+    plan := &ChangePlan{}
+    applied := entry.AppliedSteps()
+    for i := len(applied) - 1; i >= 0; i-- {
+      plan.Steps = append(plan.Steps, entry.Steps[applied[i]].Inverse)
+    }
+    return PrintChangePlan(client.out, plan, client.planFormat)
+  }
+
+  err = client.rollbackChangeSteps(journalChangeSteps(entry), entry)
+  if err != nil {
+    entry.update(-1, JournalFailed)
+    return fmt.Errorf("journal entry %s: rollback failed: %v", entry.ID, err)
+  }
+  return entry.update(-1, JournalRolledBack)
+}
+
+// settleJournalSteps decides the status of the steps which were in flight
+// when the command died. A step with a change ID was accepted by Route53
+// and is waited for. A step without one may or may not have been accepted,
+// which is told from the current record sets.
+func (client *AWSClientImpl) settleJournalSteps(entry *JournalEntry) (err error) {
+  for idx, step := range entry.Steps {
+    var changeID string
+    var change *ChangeStep
+    var done, undone string
+    switch step.Status {
+    case JournalApplying:
+      changeID, change, done, undone = step.ChangeID, step.Step, JournalApplied, JournalPending
+    case JournalRollingBack:
+      changeID, change, done, undone = step.RollbackChangeID, step.Inverse, JournalRolledBack, JournalApplied
+    default:
+      continue
+    }
+
+    if len(changeID) > 0 {
+      err = client.r53.WaitUntilResourceRecordSetsChangedWithContext(client.ctx, &route53.GetChangeInput{Id: aws.String(changeID)})
+      if err != nil {
+        return err
+      }
+      err = entry.update(idx, done)
+      if err != nil {
+        return err
+      }
+      continue
+    }
+
+    applied, err := client.changeStepApplied(change)
+    if err != nil {
+      return fmt.Errorf("journal entry %s: step %d: %v", entry.ID, idx+1, err)
+    }
+    status := undone
+    if applied {
+      status = done
+    }
+    err = entry.update(idx, status)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// changeStepApplied reports whether the record sets of the hosted zone are
+// in the state the step leaves them in. Change batches are atomic, so a
+// step whose changes are partly in effect was changed by someone else.
+func (client *AWSClientImpl) changeStepApplied(step *ChangeStep) (applied bool, err error) {
+  inEffect := 0
+  for _, change := range step.Changes {
+    rrset := change.ResourceRecordSet
+    rrsets, err := client.FindResourceRecordSets(aws.StringValue(rrset.Name), aws.StringValue(rrset.Type), aws.StringValue(rrset.SetIdentifier), step.HostedZoneID)
+    if err != nil && !IsRecordSetNotFound(err) {
+      return false, err
+    }
+    exists := len(rrsets) == 1 && EqualResourceRecordSet(rrsets[0], rrset)
+    if exists == (aws.StringValue(change.Action) != route53.ChangeActionDelete) {
+      inEffect++
+    }
+  }
+  switch inEffect {
+  case 0:
+    return false, nil
+  case len(step.Changes):
+    return true, nil
+  default:
+    return false, fmt.Errorf("%d of %d changes are in effect, the records were changed by someone else", inEffect, len(step.Changes))
+  }
+}
+
+func journalChangeSteps(entry *JournalEntry) (steps []*ChangeStep) {
+  for _, step := range entry.Steps {
+    steps = append(steps, step.Step)
+  }
+  return steps
+}
+
+func pendingPlan(entry *JournalEntry) *ChangePlan {
+  plan := &ChangePlan{}
+  for _, step := range entry.Steps {
+    if step.Status != JournalApplied {
+      plan.Steps = append(plan.Steps, step.Step)
+    }
+  }
+  return plan
+}
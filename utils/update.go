@@ -0,0 +1,142 @@
+package utils
+
+import (
+  "fmt"
+  "net"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// PlanUpdateAResourceRecordSet builds the plan which points the address
+// record of hostname at ip with an UPSERT, and moves the PTR record from the
+// previous address to ip. The routing policy of previous is kept unless
+// policy overrides it. When both PTR records live in the same reverse
+// hosted zone they are changed in a single change batch. A PTR record of ip
+// which points at another host is refused unless replacePtr is set.
+func (client *AWSClientImpl) PlanUpdateAResourceRecordSet(previous *route53.ResourceRecordSet, ip net.IP, ttl int64, policy RoutingPolicy, replacePtr bool, hostedZoneID string, rInfos ReverseHostedZoneInfos) (plan *ChangePlan, err error) {
+  hostname := aws.StringValue(previous.Name)
+  if aws.StringValue(previous.Type) != AddressRecordType(ip) {
+    return nil, fmt.Errorf("%s is a %s record, can not update it with %s", hostname, aws.StringValue(previous.Type), ip.String())
+  }
+
+  rrset := newAddressResourceRecordSet(ip, hostname)
+  rrset.TTL = previous.TTL
+  if ttl > 0 {
+    rrset.TTL = aws.Int64(ttl)
+  }
+  copyRoutingPolicy(rrset, previous)
+  policy.Apply(rrset)
+
+  newInfo, err := GetReverseHostedZoneInfo(ip, rInfos)
+  if err != nil {
+    return nil, err
+  }
+
+  forward := &ChangeStep{HostedZoneID: hostedZoneID}
+  forward.AppendChange(route53.ChangeActionUpsert, rrset, previous)
+
+  // the step of the new PTR record comes first, and the old PTR record is
+  // deleted in the same batch when it lives in the same reverse hosted zone
+  reverse := &changeSteps{}
+  newStep := reverse.step(newInfo.HostedZoneID, newInfo.HostedZoneName)
+  for _, rr := range previous.ResourceRecords {
+    oldIP := net.ParseIP(aws.StringValue(rr.Value))
+    if oldIP == nil || oldIP.Equal(ip) {
+      continue
+    }
+    oldInfo, err := GetReverseHostedZoneInfo(oldIP, rInfos)
+    if err != nil {
+      // the previous address has no PTR record to clean up
+      continue
+    }
+    oldPtr, err := client.getResourceRecordSet(oldInfo.PtrRecordName(oldIP), route53.RRTypePtr, oldInfo.HostedZoneID)
+    if err != nil {
+      return nil, err
+    }
+    if oldPtr == nil || !ptrPointsAt(oldPtr, hostname) {
+      continue
+    }
+    reverse.step(oldInfo.HostedZoneID, oldInfo.HostedZoneName).AppendChange(route53.ChangeActionDelete, oldPtr, nil)
+    err = client.planRemoveParentCname(reverse, oldInfo, oldIP)
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  ptr := newInfo.ptrResourceRecordSet(ip, hostname)
+  ptr.TTL = rrset.TTL
+  previousPtr, err := client.getResourceRecordSet(aws.StringValue(ptr.Name), route53.RRTypePtr, newInfo.HostedZoneID)
+  if err != nil {
+    return nil, err
+  }
+  if previousPtr != nil && !ptrPointsAt(previousPtr, hostname) && !replacePtr {
+    return nil, fmt.Errorf("PTR record %s points at %s, use --replace-ptr to point it at %s", aws.StringValue(ptr.Name), strings.Join(ptrValues(previousPtr), " "), hostname)
+  }
+  if previousPtr == nil || !EqualResourceRecordSet(previousPtr, ptr) {
+    newStep.AppendChange(route53.ChangeActionUpsert, ptr, previousPtr)
+  }
+  err = client.planParentCname(reverse, newInfo, ip)
+  if err != nil {
+    return nil, err
+  }
+
+  plan = &ChangePlan{}
+  plan.AddChangeStep(forward)
+  reverse.addTo(plan)
+  return plan, nil
+}
+
+// PlanUpdateCnameResourceRecordSet builds the plan which points the CNAME
+// record at cnameHostname with an UPSERT, keeping its routing policy unless
+// policy overrides it.
+func (client *AWSClientImpl) PlanUpdateCnameResourceRecordSet(previous *route53.ResourceRecordSet, cnameHostname string, ttl int64, policy RoutingPolicy, hostedZoneID string) (plan *ChangePlan, err error) {
+  if aws.StringValue(previous.Type) != route53.RRTypeCname {
+    return nil, fmt.Errorf("%s is a %s record, can not update it with a CNAME", aws.StringValue(previous.Name), aws.StringValue(previous.Type))
+  }
+  rrset := &route53.ResourceRecordSet{
+    Name: previous.Name,
+    ResourceRecords: []*route53.ResourceRecord{
+      {
+        Value: aws.String(cnameHostname),
+      },
+    },
+    TTL: previous.TTL,
+    Type: aws.String(route53.RRTypeCname),
+  }
+  if ttl > 0 {
+    rrset.TTL = aws.Int64(ttl)
+  }
+  copyRoutingPolicy(rrset, previous)
+  policy.Apply(rrset)
+
+  step := &ChangeStep{HostedZoneID: hostedZoneID}
+  step.AppendChange(route53.ChangeActionUpsert, rrset, previous)
+  plan = &ChangePlan{}
+  plan.AddChangeStep(step)
+  return plan, nil
+}
+
+func ptrPointsAt(ptr *route53.ResourceRecordSet, hostname string) bool {
+  for _, rr := range ptr.ResourceRecords {
+    if strings.EqualFold(strings.TrimSuffix(aws.StringValue(rr.Value), "."), strings.TrimSuffix(hostname, ".")) {
+      return true
+    }
+  }
+  return false
+}
+
+// getResourceRecordSet returns the record set with the name and type and
+// without a set identifier, or nil when the hosted zone has no such record
+// set.
+func (client *AWSClientImpl) getResourceRecordSet(name string, rrType string, hostedZoneID string) (rrset *route53.ResourceRecordSet, err error) {
+  rrsets, err := client.FindResourceRecordSets(name, rrType, "", hostedZoneID)
+  if IsRecordSetNotFound(err) {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  return findResourceRecordSet(rrsets, name, rrType), nil
+}
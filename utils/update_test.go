@@ -0,0 +1,67 @@
+package utils
+
+import (
+  "net"
+  "strings"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestPlanUpdateCnameResourceRecordSet(t *testing.T) {
+  previous := &route53.ResourceRecordSet{
+    Name: aws.String("www.example.com."),
+    ResourceRecords: []*route53.ResourceRecord{
+      {
+        Value: aws.String("w1.example.com."),
+      },
+    },
+    TTL: aws.Int64(300),
+    Type: aws.String(route53.RRTypeCname),
+  }
+
+  awsClient := &AWSClientImpl{}
+  plan, err := awsClient.PlanUpdateCnameResourceRecordSet(previous, "w2.example.com.", 0, RoutingPolicy{}, "ABC123")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(plan.Steps) != 1 {
+    t.Fatalf("unexpected steps: %v", plan.Steps)
+  }
+
+  var out strings.Builder
+  err = PrintChangePlan(&out, plan, "text")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  expected := "@@ step 1: ABC123 @@\n~ www.example.com. 300 CNAME w2.example.com.\n"
+  if out.String() != expected {
+    t.Errorf("unexpected plan: expected %q, actual %q", expected, out.String())
+  }
+
+  inverse, err := plan.Steps[0].Inverse()
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if aws.StringValue(inverse.Changes[0].Action) != route53.ChangeActionUpsert || inverse.Changes[0].ResourceRecordSet != previous {
+    t.Errorf("unexpected inverse: %v", inverse)
+  }
+}
+
+func TestPlanUpdateTypeMismatch(t *testing.T) {
+  previous := newAddressResourceRecordSet(net.ParseIP("10.0.1.15"), "www.example.com.")
+
+  awsClient := &AWSClientImpl{}
+  _, err := awsClient.PlanUpdateAResourceRecordSet(previous, net.ParseIP("2001:db8::15"), 0, RoutingPolicy{}, false, "ABC123", ReverseHostedZoneInfos{})
+  expected := "www.example.com. is a A record, can not update it with 2001:db8::15"
+  if err == nil || err.Error() != expected {
+    t.Errorf("unexpected error: expected %s, actual %v", expected, err)
+  }
+
+  _, err = awsClient.PlanUpdateCnameResourceRecordSet(previous, "w2.example.com.", 0, RoutingPolicy{}, "ABC123")
+  expected = "www.example.com. is a A record, can not update it with a CNAME"
+  if err == nil || err.Error() != expected {
+    t.Errorf("unexpected error: expected %s, actual %v", expected, err)
+  }
+}
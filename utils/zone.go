@@ -0,0 +1,283 @@
+package utils
+
+import (
+  "fmt"
+  "io"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// HostedZone is a hosted zone as zone list and zone show print it. The name
+// servers and VPCs are only filled in by GetHostedZone.
+type HostedZone struct {
+  ID string `json:"id" yaml:"id"`
+  Name string `json:"name" yaml:"name"`
+  Private bool `json:"private" yaml:"private"`
+  RecordSetCount int64 `json:"recordSetCount" yaml:"recordSetCount"`
+  Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+  CallerReference string `json:"callerReference" yaml:"callerReference"`
+  DelegationSetID string `json:"delegationSetId,omitempty" yaml:"delegationSetId,omitempty"`
+  NameServers []string `json:"nameServers,omitempty" yaml:"nameServers,omitempty"`
+  VPCs []HostedZoneVPC `json:"vpcs,omitempty" yaml:"vpcs,omitempty"`
+}
+
+// HostedZoneVPC is a VPC a private hosted zone is associated with.
+type HostedZoneVPC struct {
+  ID string `json:"id" yaml:"id"`
+  Region string `json:"region" yaml:"region"`
+}
+
+// HostedZoneNotFoundError is returned by GetHostedZoneID when there is no
+// hosted zone with the name.
+type HostedZoneNotFoundError struct {
+  Name string
+  // Next is the name of the hosted zone listed instead, if any.
+  Next string
+}
+
+func (e *HostedZoneNotFoundError) Error() string {
+  if len(e.Next) > 0 {
+    return fmt.Sprintf("unexpected HostedZone Name: expected %s, actual %s", e.Name, e.Next)
+  }
+  return fmt.Sprintf("HostedZone not found: %s", e.Name)
+}
+
+// IsHostedZoneNotFound reports whether err is a HostedZoneNotFoundError.
+func IsHostedZoneNotFound(err error) bool {
+  _, ok := err.(*HostedZoneNotFoundError)
+  return ok
+}
+
+// CreateHostedZoneOptions are the settings of a new hosted zone. The zone is
+// private when VPCID is set.
+type CreateHostedZoneOptions struct {
+  Name string
+  // CallerReference makes the creation idempotent, a new one is generated
+  // when it is empty.
+  CallerReference string
+  Comment string
+  VPCID string
+  VPCRegion string
+  DelegationSetID string
+}
+
+func newHostedZone(zone *route53.HostedZone) HostedZone {
+  parts := strings.Split(aws.StringValue(zone.Id), "/")
+  hz := HostedZone{
+    ID: parts[len(parts)-1],
+    Name: aws.StringValue(zone.Name),
+    RecordSetCount: aws.Int64Value(zone.ResourceRecordSetCount),
+    CallerReference: aws.StringValue(zone.CallerReference),
+  }
+  if zone.Config != nil {
+    hz.Private = aws.BoolValue(zone.Config.PrivateZone)
+    hz.Comment = aws.StringValue(zone.Config.Comment)
+  }
+  return hz
+}
+
+// setDelegation fills in the name servers and VPCs of hz.
+func (hz *HostedZone) setDelegation(set *route53.DelegationSet, vpcs []*route53.VPC) {
+  if set != nil {
+    parts := strings.Split(aws.StringValue(set.Id), "/")
+    hz.DelegationSetID = parts[len(parts)-1]
+    hz.NameServers = aws.StringValueSlice(set.NameServers)
+  }
+  for _, vpc := range vpcs {
+    hz.VPCs = append(hz.VPCs, HostedZoneVPC{ID: aws.StringValue(vpc.VPCId), Region: aws.StringValue(vpc.VPCRegion)})
+  }
+}
+
+// HostedZoneViews returns zones, as returned by ListHostedZones, in the
+// form zone list prints them.
+func HostedZoneViews(zones []*route53.HostedZone) (views []HostedZone) {
+  for _, zone := range zones {
+    views = append(views, newHostedZone(zone))
+  }
+  return views
+}
+
+// GetHostedZone returns the hosted zone with its name servers, or its VPCs
+// when it is private.
+func (client *AWSClientImpl) GetHostedZone(hostedZoneID string) (zone HostedZone, err error) {
+  resp, err := client.r53.GetHostedZoneWithContext(client.ctx, &route53.GetHostedZoneInput{Id: aws.String(hostedZoneID)})
+  if err != nil {
+    return zone, canceledError(client.ctx, err)
+  }
+  zone = newHostedZone(resp.HostedZone)
+  zone.setDelegation(resp.DelegationSet, resp.VPCs)
+  return zone, nil
+}
+
+// CreateHostedZone creates a hosted zone and waits until it is in sync,
+// unless noWait is set. With dryRun, it only prints what it would create.
+func (client *AWSClientImpl) CreateHostedZone(opts CreateHostedZoneOptions) (zone HostedZone, err error) {
+  if len(opts.VPCID) == 0 && len(opts.VPCRegion) > 0 {
+    return zone, fmt.Errorf("vpc-region requires vpc-id")
+  }
+  if len(opts.VPCID) > 0 && len(opts.DelegationSetID) > 0 {
+    return zone, fmt.Errorf("a private hosted zone can not use a delegation set")
+  }
+  callerReference := opts.CallerReference
+  if len(callerReference) == 0 {
+    callerReference = "cli-tool-example-" + newJournalID(time.Now())
+  }
+  input := &route53.CreateHostedZoneInput{
+    Name: aws.String(Fqdn("@", opts.Name)),
+    CallerReference: aws.String(callerReference),
+  }
+  if len(opts.Comment) > 0 {
+    input.HostedZoneConfig = &route53.HostedZoneConfig{Comment: aws.String(opts.Comment)}
+  }
+  if len(opts.VPCID) > 0 {
+    input.VPC = &route53.VPC{VPCId: aws.String(opts.VPCID)}
+    if len(opts.VPCRegion) > 0 {
+      input.VPC.VPCRegion = aws.String(opts.VPCRegion)
+    }
+  }
+  if len(opts.DelegationSetID) > 0 {
+    input.DelegationSetId = aws.String(opts.DelegationSetID)
+  }
+  err = input.Validate()
+  if err != nil {
+    return zone, err
+  }
+
+  if client.dryRun {
+    _, err = fmt.Fprintf(client.out, "create hosted zone %s\n", aws.StringValue(input.Name))
+    return zone, err
+  }
+  resp, err := client.r53.CreateHostedZoneWithContext(client.ctx, input)
+  if err != nil {
+    return zone, canceledError(client.ctx, err)
+  }
+  zone = newHostedZone(resp.HostedZone)
+  var vpcs []*route53.VPC
+  if resp.VPC != nil {
+    vpcs = append(vpcs, resp.VPC)
+  }
+  zone.setDelegation(resp.DelegationSet, vpcs)
+  err = client.waitChange(aws.StringValue(resp.ChangeInfo.Id))
+  if err != nil {
+    return zone, fmt.Errorf("hosted zone %s (%s) is created: %v", zone.Name, zone.ID, err)
+  }
+  return zone, nil
+}
+
+// DeleteHostedZone deletes a hosted zone. A zone with record sets other
+// than its apex SOA and NS is refused, unless force is set, in which case
+// they are deleted first with a plan, so that an interrupted deletion can
+// be undone from the journal.
+func (client *AWSClientImpl) DeleteHostedZone(hostedZoneID string, force bool) (err error) {
+  zone, err := client.GetHostedZone(hostedZoneID)
+  if err != nil {
+    return err
+  }
+  rrsets, err := client.ListAllResourceRecords(zone.ID)
+  if err != nil {
+    return canceledError(client.ctx, err)
+  }
+  var records []*route53.ResourceRecordSet
+  for _, rrset := range rrsets {
+    rrType := aws.StringValue(rrset.Type)
+    if compareHostedZoneName(aws.StringValue(rrset.Name), zone.Name) && (rrType == route53.RRTypeSoa || rrType == route53.RRTypeNs) {
+      continue
+    }
+    if rrset.AliasTarget != nil {
+      // an alias may point at another record set of the zone
+      records = append([]*route53.ResourceRecordSet{rrset}, records...)
+    } else {
+      records = append(records, rrset)
+    }
+  }
+
+  if len(records) > 0 {
+    if !force {
+      return fmt.Errorf("hosted zone %s is not empty: %d record sets, use --force to delete them", zone.Name, len(records))
+    }
+    step := &ChangeStep{HostedZoneID: zone.ID, HostedZoneName: zone.Name}
+    for _, rrset := range records {
+      step.AppendChange(route53.ChangeActionDelete, rrset, nil)
+    }
+    plan := &ChangePlan{}
+    plan.AddChangeStep(step)
+    err = client.ApplyChangePlan(plan)
+    if err != nil {
+      return err
+    }
+  }
+
+  if client.dryRun {
+    _, err = fmt.Fprintf(client.out, "delete hosted zone %s (%s)\n", zone.Name, zone.ID)
+    return err
+  }
+  resp, err := client.r53.DeleteHostedZoneWithContext(client.ctx, &route53.DeleteHostedZoneInput{Id: aws.String(zone.ID)})
+  if err != nil {
+    return canceledError(client.ctx, err)
+  }
+  return client.waitChange(aws.StringValue(resp.ChangeInfo.Id))
+}
+
+// WriteHostedZones writes zones to w as text, json or yaml.
+func WriteHostedZones(w io.Writer, zones []HostedZone, format string) (err error) {
+  switch format {
+  case "", "text":
+    for _, zone := range zones {
+      visibility := "public"
+      if zone.Private {
+        visibility = "private"
+      }
+      _, err = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", zone.ID, zone.Name, visibility, zone.RecordSetCount, zone.Comment)
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  case "json":
+    if zones == nil {
+      zones = []HostedZone{}
+    }
+    return WriteJSON(w, zones)
+  case "yaml":
+    return WriteYAML(w, zones)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
+
+// WriteHostedZone writes the details of zone to w as text, json or yaml.
+func WriteHostedZone(w io.Writer, zone HostedZone, format string) (err error) {
+  switch format {
+  case "", "text":
+    lines := []string{
+      "id\t" + zone.ID,
+      "name\t" + zone.Name,
+      fmt.Sprintf("private\t%t", zone.Private),
+      fmt.Sprintf("record sets\t%d", zone.RecordSetCount),
+      "caller reference\t" + zone.CallerReference,
+    }
+    if len(zone.Comment) > 0 {
+      lines = append(lines, "comment\t"+zone.Comment)
+    }
+    if len(zone.DelegationSetID) > 0 {
+      lines = append(lines, "delegation set\t"+zone.DelegationSetID)
+    }
+    for _, nameServer := range zone.NameServers {
+      lines = append(lines, "name server\t"+nameServer)
+    }
+    for _, vpc := range zone.VPCs {
+      lines = append(lines, "vpc\t"+vpc.ID+"\t"+vpc.Region)
+    }
+    _, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+    return err
+  case "json":
+    return WriteJSON(w, zone)
+  case "yaml":
+    return WriteYAML(w, zone)
+  default:
+    return fmt.Errorf("unknown output format: %s", format)
+  }
+}
@@ -0,0 +1,431 @@
+package utils
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/route53"
+)
+
+// route53Types are the record types a Route53 hosted zone can hold.
+var route53Types = map[string]bool{
+  route53.RRTypeSoa: true,
+  route53.RRTypeA: true,
+  route53.RRTypeTxt: true,
+  route53.RRTypeNs: true,
+  route53.RRTypeCname: true,
+  route53.RRTypeMx: true,
+  route53.RRTypeNaptr: true,
+  route53.RRTypePtr: true,
+  route53.RRTypeSrv: true,
+  route53.RRTypeSpf: true,
+  route53.RRTypeAaaa: true,
+  route53.RRTypeCaa: true,
+}
+
+// WriteZoneFile writes rrsets as an RFC 1035 master file. Alias records
+// and records with a routing policy can not be expressed in a master file
+// and are written as comments.
+func WriteZoneFile(w io.Writer, zoneName string, rrsets []*route53.ResourceRecordSet) (err error) {
+  _, err = fmt.Fprintf(w, "$ORIGIN %s\n", Fqdn("@", zoneName))
+  if err != nil {
+    return err
+  }
+  for _, rrset := range rrsets {
+    name := aws.StringValue(rrset.Name)
+    rrType := aws.StringValue(rrset.Type)
+    if rrset.AliasTarget != nil {
+      _, err = fmt.Fprintf(w, "; %s\tALIAS\t%s\t%s\t%s\n", name, rrType,
+        aws.StringValue(rrset.AliasTarget.DNSName), aws.StringValue(rrset.AliasTarget.HostedZoneId))
+      if err != nil {
+        return err
+      }
+      continue
+    }
+    prefix := ""
+    if rrset.SetIdentifier != nil {
+      _, err = fmt.Fprintf(w, "; set identifier %s\n", aws.StringValue(rrset.SetIdentifier))
+      if err != nil {
+        return err
+      }
+      prefix = "; "
+    }
+    for _, rr := range rrset.ResourceRecords {
+      _, err = fmt.Fprintf(w, "%s%s\t%d\tIN\t%s\t%s\n", prefix, name, aws.Int64Value(rrset.TTL), rrType, aws.StringValue(rr.Value))
+      if err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
+
+type zoneToken struct {
+  text string
+  quoted bool
+}
+
+// zoneEntry is one logical line of a master file, with the parentheses
+// already joined.
+type zoneEntry struct {
+  line int
+  blankOwner bool
+  tokens []zoneToken
+}
+
+// ParseZoneFile parses an RFC 1035 master file. Names are made absolute with
+// origin unless the file sets $ORIGIN. Records with the same name and type
+// are merged into one record set.
+func ParseZoneFile(r io.Reader, origin string) (rrsets []*route53.ResourceRecordSet, err error) {
+  entries, err := scanZoneEntries(r)
+  if err != nil {
+    return nil, err
+  }
+
+  origin = Fqdn("@", origin)
+  var defaultTTL int64 = -1
+  var lastTTL int64 = -1
+  owner := ""
+  index := map[string]*route53.ResourceRecordSet{}
+
+  for _, entry := range entries {
+    tokens := entry.tokens
+    if !tokens[0].quoted && strings.HasPrefix(tokens[0].text, "$") {
+      switch strings.ToUpper(tokens[0].text) {
+      case "$ORIGIN":
+        if len(tokens) < 2 {
+          return nil, fmt.Errorf("line %d: $ORIGIN without a name", entry.line)
+        }
+        origin = zoneFileName(tokens[1].text, origin)
+      case "$TTL":
+        if len(tokens) < 2 {
+          return nil, fmt.Errorf("line %d: $TTL without a value", entry.line)
+        }
+        defaultTTL, err = ParseZoneFileTTL(tokens[1].text)
+        if err != nil {
+          return nil, fmt.Errorf("line %d: %v", entry.line, err)
+        }
+      default:
+        return nil, fmt.Errorf("line %d: unsupported directive %s", entry.line, tokens[0].text)
+      }
+      continue
+    }
+
+    if !entry.blankOwner {
+      owner = zoneFileName(tokens[0].text, origin)
+      tokens = tokens[1:]
+    } else if len(owner) == 0 {
+      return nil, fmt.Errorf("line %d: no owner name", entry.line)
+    }
+
+    ttl := int64(-1)
+    for len(tokens) > 0 {
+      text := strings.ToUpper(tokens[0].text)
+      if text == "IN" {
+        tokens = tokens[1:]
+        continue
+      }
+      if t, e := ParseZoneFileTTL(tokens[0].text); e == nil && ttl < 0 {
+        ttl = t
+        tokens = tokens[1:]
+        continue
+      }
+      break
+    }
+    if len(tokens) == 0 {
+      return nil, fmt.Errorf("line %d: no record type", entry.line)
+    }
+    rrType := strings.ToUpper(tokens[0].text)
+    rdata := tokens[1:]
+    if len(rdata) == 0 {
+      return nil, fmt.Errorf("line %d: no record data", entry.line)
+    }
+
+    if ttl < 0 {
+      if defaultTTL >= 0 {
+        ttl = defaultTTL
+      } else if lastTTL >= 0 {
+        ttl = lastTTL
+      } else if rrType == route53.RRTypeSoa && len(rdata) == 7 {
+        // RFC 2308: the SOA minimum is the default TTL without $TTL
+        ttl, err = ParseZoneFileTTL(rdata[6].text)
+        if err != nil {
+          return nil, fmt.Errorf("line %d: %v", entry.line, err)
+        }
+      } else {
+        return nil, fmt.Errorf("line %d: no TTL", entry.line)
+      }
+    }
+    lastTTL = ttl
+
+    value := zoneFileRdata(rrType, rdata, origin)
+    key := strings.ToLower(owner) + " " + rrType
+    rrset, ok := index[key]
+    if !ok {
+      rrset = &route53.ResourceRecordSet{
+        Name: aws.String(owner),
+        TTL: aws.Int64(ttl),
+        Type: aws.String(rrType),
+      }
+      index[key] = rrset
+      rrsets = append(rrsets, rrset)
+    } else if ttl < aws.Int64Value(rrset.TTL) {
+      // RFC 2181: all records of a set share a TTL; use the smallest one
+      rrset.TTL = aws.Int64(ttl)
+    }
+    rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(value)})
+  }
+  return rrsets, nil
+}
+
+// FilterImportableRecordSets drops the apex SOA and NS record sets, which
+// Route53 manages itself, and the record types Route53 can not hold.
+// A warning is returned for every dropped record set of an unsupported type.
+func FilterImportableRecordSets(rrsets []*route53.ResourceRecordSet, zoneName string) (kept []*route53.ResourceRecordSet, warnings []string) {
+  apex := strings.ToLower(Fqdn("@", zoneName))
+  for _, rrset := range rrsets {
+    rrType := aws.StringValue(rrset.Type)
+    name := strings.ToLower(aws.StringValue(rrset.Name))
+    if name == apex && (rrType == route53.RRTypeSoa || rrType == route53.RRTypeNs) {
+      continue
+    }
+    if !route53Types[rrType] {
+      warnings = append(warnings, fmt.Sprintf("skip %s %s: unsupported record type", aws.StringValue(rrset.Name), rrType))
+      continue
+    }
+    if rrType == route53.RRTypeSoa {
+      warnings = append(warnings, fmt.Sprintf("skip %s %s: SOA outside the zone apex", aws.StringValue(rrset.Name), rrType))
+      continue
+    }
+    kept = append(kept, rrset)
+  }
+  return kept, warnings
+}
+
+// ParseZoneFileTTL parses a TTL in seconds or with BIND style units
+// such as "1h30m" or "1W".
+func ParseZoneFileTTL(s string) (ttl int64, err error) {
+  if len(s) == 0 {
+    return 0, fmt.Errorf("invalid TTL: %s", s)
+  }
+  if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+    if n < 0 {
+      return 0, fmt.Errorf("invalid TTL: %s", s)
+    }
+    return n, nil
+  }
+  var num int64
+  digits := 0
+  for _, r := range strings.ToLower(s) {
+    if r >= '0' && r <= '9' {
+      num = num*10 + int64(r-'0')
+      digits++
+      continue
+    }
+    if digits == 0 {
+      return 0, fmt.Errorf("invalid TTL: %s", s)
+    }
+    switch r {
+    case 's':
+    case 'm':
+      num *= 60
+    case 'h':
+      num *= 3600
+    case 'd':
+      num *= 86400
+    case 'w':
+      num *= 604800
+    default:
+      return 0, fmt.Errorf("invalid TTL: %s", s)
+    }
+    ttl += num
+    num = 0
+    digits = 0
+  }
+  if digits > 0 {
+    return 0, fmt.Errorf("invalid TTL: %s", s)
+  }
+  return ttl, nil
+}
+
+// zoneFileName makes name absolute relative to origin.
+func zoneFileName(name string, origin string) string {
+  if name == "@" {
+    return origin
+  }
+  if strings.HasSuffix(name, ".") {
+    return name
+  }
+  if origin == "." {
+    return name + "."
+  }
+  return name + "." + origin
+}
+
+// zoneFileRdata converts rdata tokens into the Route53 value format,
+// making domain names in the rdata absolute.
+func zoneFileRdata(rrType string, rdata []zoneToken, origin string) string {
+  var nameIndex = -1
+  switch rrType {
+  case route53.RRTypeCname, route53.RRTypeNs, route53.RRTypePtr:
+    nameIndex = 0
+  case route53.RRTypeMx:
+    nameIndex = 1
+  case route53.RRTypeSrv:
+    nameIndex = 3
+  case route53.RRTypeSoa:
+    nameIndex = 0
+  }
+
+  fields := make([]string, 0, len(rdata))
+  for idx, token := range rdata {
+    text := token.text
+    switch {
+    case token.quoted:
+      text = `"` + text + `"`
+    case rrType == route53.RRTypeTxt || rrType == route53.RRTypeSpf:
+      text = `"` + text + `"`
+    case idx == nameIndex:
+      text = zoneFileName(text, origin)
+    case rrType == route53.RRTypeSoa && idx == 1:
+      text = zoneFileName(text, origin)
+    }
+    fields = append(fields, text)
+  }
+  return strings.Join(fields, " ")
+}
+
+// scanZoneEntries splits a master file into entries, dropping comments and
+// joining lines inside parentheses. Escapes inside quoted strings are kept
+// as they are, since Route53 uses the same presentation format.
+func scanZoneEntries(r io.Reader) (entries []zoneEntry, err error) {
+  scanner := bufio.NewScanner(r)
+  scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+  lineNo := 0
+  depth := 0
+  var current *zoneEntry
+
+  for scanner.Scan() {
+    lineNo++
+    line := scanner.Text()
+
+    if depth == 0 {
+      if current != nil && len(current.tokens) > 0 {
+        entries = append(entries, *current)
+      }
+      current = &zoneEntry{
+        line: lineNo,
+        blankOwner: len(line) > 0 && (line[0] == ' ' || line[0] == '\t'),
+      }
+    }
+
+    i := 0
+    for i < len(line) {
+      ch := line[i]
+      switch {
+      case ch == ';':
+        i = len(line)
+      case ch == ' ' || ch == '\t' || ch == '\r':
+        i++
+      case ch == '(':
+        depth++
+        i++
+      case ch == ')':
+        if depth == 0 {
+          return nil, fmt.Errorf("line %d: unbalanced parenthesis", lineNo)
+        }
+        depth--
+        i++
+      case ch == '"':
+        j := i + 1
+        for j < len(line) && line[j] != '"' {
+          if line[j] == '\\' {
+            j++
+          }
+          j++
+        }
+        if j >= len(line) {
+          return nil, fmt.Errorf("line %d: unterminated string", lineNo)
+        }
+        current.tokens = append(current.tokens, zoneToken{text: line[i+1 : j], quoted: true})
+        i = j + 1
+      default:
+        j := i
+        for j < len(line) && !strings.ContainsRune(" \t\r;()\"", rune(line[j])) {
+          if line[j] == '\\' {
+            j++
+          }
+          j++
+        }
+        if j > len(line) {
+          j = len(line)
+        }
+        current.tokens = append(current.tokens, zoneToken{text: line[i:j]})
+        i = j
+      }
+    }
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+  if depth != 0 {
+    return nil, fmt.Errorf("line %d: unbalanced parenthesis", lineNo)
+  }
+  if current != nil && len(current.tokens) > 0 {
+    entries = append(entries, *current)
+  }
+  return entries, nil
+}
+
+// PlanImportRecordSets builds the plan which writes rrsets into the hosted
+// zone, in as many steps as the limits of a change batch require. Record
+// sets which already exist with the same name, type and set identifier are
+// replaced, identical ones are skipped. A record set without a set
+// identifier can not be imported next to ones with a routing policy.
+func (client *AWSClientImpl) PlanImportRecordSets(hostedZoneID string, zoneName string, rrsets []*route53.ResourceRecordSet) (plan *ChangePlan, err error) {
+  current, err := client.ListAllResourceRecords(hostedZoneID)
+  if err != nil {
+    return nil, err
+  }
+  currentIndex := map[string]*route53.ResourceRecordSet{}
+  routed := map[string]bool{}
+  for _, rrset := range current {
+    currentIndex[importKey(rrset)] = rrset
+    if rrset.SetIdentifier != nil {
+      routed[recordSetKey(rrset)] = true
+    }
+  }
+
+  step := &ChangeStep{HostedZoneID: hostedZoneID, HostedZoneName: zoneName}
+  for _, rrset := range rrsets {
+    if rrset.SetIdentifier == nil && routed[recordSetKey(rrset)] {
+      return nil, fmt.Errorf("%s %s has record sets with a routing policy in the hosted zone", aws.StringValue(rrset.Name), aws.StringValue(rrset.Type))
+    }
+    previous := currentIndex[importKey(rrset)]
+    if previous == nil {
+      step.AppendChange(route53.ChangeActionCreate, rrset, nil)
+    } else if !EqualResourceRecordSet(previous, rrset) {
+      step.AppendChange(route53.ChangeActionUpsert, rrset, previous)
+    }
+  }
+
+  chunks, err := ChunkChangeStep(step)
+  if err != nil {
+    return nil, err
+  }
+  plan = &ChangePlan{}
+  for _, chunk := range chunks {
+    plan.AddChangeStep(chunk)
+  }
+  return plan, nil
+}
+
+// importKey identifies a record set by its name, type and set identifier.
+func importKey(rrset *route53.ResourceRecordSet) string {
+  return recordSetKey(rrset) + " " + aws.StringValue(rrset.SetIdentifier)
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestParseZoneFile(t *testing.T) {
+  zone := `$ORIGIN example.com.
+$TTL 1h
+@	IN	SOA	ns1 hostmaster (
+		2020011301 ; serial
+		7200 3600 1209600 300 )
+	IN	NS	ns1
+	IN	NS	ns2.example.net.
+	IN	MX	10 mail
+www	600	IN	A	10.0.1.15
+	600	IN	A	10.0.1.16
+mail	IN	300	AAAA	2001:db8::25
+alias	IN	CNAME	www
+txt	TXT	"v=spf1 -all" "second; string"
+bare	TXT	hello
+_sip._tcp	SRV	10 60 5060 sip
+sub.example.com.	1d	IN	A	10.0.2.1
+`
+
+  rrsets, err := ParseZoneFile(strings.NewReader(zone), "example.org")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+
+  expected := []string{
+    "example.com. 3600 SOA ns1.example.com. hostmaster.example.com. 2020011301 7200 3600 1209600 300",
+    "example.com. 3600 NS ns1.example.com. ns2.example.net.",
+    "example.com. 3600 MX 10 mail.example.com.",
+    "www.example.com. 600 A 10.0.1.15 10.0.1.16",
+    "mail.example.com. 300 AAAA 2001:db8::25",
+    "alias.example.com. 3600 CNAME www.example.com.",
+    `txt.example.com. 3600 TXT "v=spf1 -all" "second; string"`,
+    `bare.example.com. 3600 TXT "hello"`,
+    "_sip._tcp.example.com. 3600 SRV 10 60 5060 sip.example.com.",
+    "sub.example.com. 86400 A 10.0.2.1",
+  }
+  if len(rrsets) != len(expected) {
+    t.Fatalf("unexpected record sets: expected %d, actual %d", len(expected), len(rrsets))
+  }
+  for idx, rrset := range rrsets {
+    if FormatResourceRecordSet(rrset) != expected[idx] {
+      t.Errorf("unexpected record set (%d): expected %s, actual %s", idx, expected[idx], FormatResourceRecordSet(rrset))
+    }
+  }
+}
+
+func TestParseZoneFileError(t *testing.T) {
+  patterns := []struct{
+    zone string
+    expectedError string
+  }{
+    { "www A 10.0.0.1\n", "line 1: no TTL" },
+    { "$TTL 600\nwww IN SOA ( ns1 hostmaster\n", "line 2: unbalanced parenthesis" },
+    { "$TTL 600\nwww TXT \"unterminated\n", "line 2: unterminated string" },
+    { "$INCLUDE other.zone\n", "line 1: unsupported directive $INCLUDE" },
+    { "$TTL 600\n  A 10.0.0.1\n", "line 2: no owner name" },
+  }
+
+  for idx, p := range patterns {
+    _, err := ParseZoneFile(strings.NewReader(p.zone), "example.com.")
+    if err == nil || err.Error() != p.expectedError {
+      t.Errorf("unexpected error (%d): expected error %v, actual error %v", idx, p.expectedError, err)
+    }
+  }
+}
+
+func TestParseZoneFileTTL(t *testing.T) {
+  patterns := []struct{
+    ttl string
+    expected int64
+    expectedError bool
+  }{
+    { "600", 600, false },
+    { "1h30m", 5400, false },
+    { "1W", 604800, false },
+    { "2d", 172800, false },
+    { "h", 0, true },
+    { "10x", 0, true },
+    { "A", 0, true },
+  }
+
+  for idx, p := range patterns {
+    actual, err := ParseZoneFileTTL(p.ttl)
+    if (err != nil) != p.expectedError {
+      t.Errorf("unexpected error (%d): %v", idx, err)
+    } else if actual != p.expected {
+      t.Errorf("pattern %d: want %d, actual %d", idx, p.expected, actual)
+    }
+  }
+}
+
+func TestFilterImportableRecordSets(t *testing.T) {
+  rrsets := []*route53.ResourceRecordSet{
+    { Name: aws.String("example.com."), Type: aws.String("SOA") },
+    { Name: aws.String("example.com."), Type: aws.String("NS") },
+    { Name: aws.String("sub.example.com."), Type: aws.String("NS") },
+    { Name: aws.String("www.example.com."), Type: aws.String("A") },
+    { Name: aws.String("key.example.com."), Type: aws.String("DNSKEY") },
+  }
+
+  kept, warnings := FilterImportableRecordSets(rrsets, "example.com")
+  if len(kept) != 2 || aws.StringValue(kept[0].Name) != "sub.example.com." || aws.StringValue(kept[1].Name) != "www.example.com." {
+    t.Errorf("unexpected record sets: %v", kept)
+  }
+  if len(warnings) != 1 || warnings[0] != "skip key.example.com. DNSKEY: unsupported record type" {
+    t.Errorf("unexpected warnings: %v", warnings)
+  }
+}
+
+func TestWriteZoneFile(t *testing.T) {
+  rrsets := []*route53.ResourceRecordSet{
+    {
+      Name: aws.String("www.example.com."),
+      ResourceRecords: []*route53.ResourceRecord{
+        { Value: aws.String("10.0.1.15") },
+        { Value: aws.String("10.0.1.16") },
+      },
+      TTL: aws.Int64(600),
+      Type: aws.String(route53.RRTypeA),
+    },
+    {
+      Name: aws.String("example.com."),
+      Type: aws.String(route53.RRTypeA),
+      AliasTarget: &route53.AliasTarget{
+        DNSName: aws.String("d111111abcdef8.cloudfront.net."),
+        HostedZoneId: aws.String("Z2FDTNDATAQYW2"),
+        EvaluateTargetHealth: aws.Bool(false),
+      },
+    },
+  }
+  expected := "$ORIGIN example.com.\n" +
+    "www.example.com.\t600\tIN\tA\t10.0.1.15\n" +
+    "www.example.com.\t600\tIN\tA\t10.0.1.16\n" +
+    "; example.com.\tALIAS\tA\td111111abcdef8.cloudfront.net.\tZ2FDTNDATAQYW2\n"
+
+  var out bytes.Buffer
+  err := WriteZoneFile(&out, "example.com", rrsets)
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if out.String() != expected {
+    t.Errorf("unexpected output: expected\n%s\nactual\n%s", expected, out.String())
+  }
+
+  parsed, err := ParseZoneFile(&out, "example.com")
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if len(parsed) != 1 || !EqualResourceRecordSet(parsed[0], rrsets[0]) {
+    t.Errorf("round trip mismatch: %v", parsed)
+  }
+}
@@ -0,0 +1,309 @@
+package zone
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/nabeo/cli-tool-example/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command cli.Command object list
+var Command = cli.Command{
+  Name: "zone",
+  Usage: "create, delete, delegate and inspect hosted zones",
+  Subcommands: []*cli.Command{
+    {
+      Name: "create",
+      Usage: "create a public hosted zone, or a private one with --vpc-id",
+      Action: doCreate,
+      Flags: []cli.Flag{
+        &cli.StringFlag{
+          Name: "zone",
+          Usage: "HostedZone Name",
+          Aliases: []string{"z"},
+        },
+        &cli.StringFlag{
+          Name: "network",
+          Usage: "create the reverse zone of this network, e.g. 10.1.0.0/16, instead of --zone",
+        },
+        &cli.StringFlag{
+          Name: "caller-reference",
+          Usage: "unique string which makes retrying the creation safe (default: generated)",
+        },
+        &cli.StringFlag{
+          Name: "comment",
+          Usage: "comment of the hosted zone",
+        },
+        &cli.StringFlag{
+          Name: "vpc-id",
+          Usage: "VPC of a private hosted zone",
+        },
+        &cli.StringFlag{
+          Name: "vpc-region",
+          Usage: "region of --vpc-id (default: the region of the client)",
+        },
+        &cli.StringFlag{
+          Name: "delegation-set-id",
+          Usage: "reusable delegation set of a public hosted zone",
+        },
+        &cli.StringFlag{
+          Name: "output",
+          Usage: "output format (text, json or yaml)",
+          Value: "text",
+          Aliases: []string{"o"},
+        },
+      },
+    },
+    {
+      Name: "delegate",
+      Usage: "create a child zone if needed and delegate it with NS records in its parent zone",
+      Action: doDelegate,
+      Flags: []cli.Flag{
+        &cli.StringFlag{
+          Name: "parent",
+          Usage: "HostedZone Name of the parent zone",
+          Required: true,
+          Aliases: []string{"p"},
+        },
+        &cli.StringFlag{
+          Name: "child",
+          Usage: "HostedZone Name of the child zone, a subdomain of the parent",
+          Required: true,
+          Aliases: []string{"c"},
+        },
+        &cli.Int64Flag{
+          Name: "ttl",
+          Usage: "TTL of the NS record set in the parent zone",
+          Value: utils.DefaultDelegationTTL,
+        },
+        &cli.BoolFlag{
+          Name: "check",
+          Usage: "only verify that the NS record set in the parent matches the name servers of the child",
+        },
+        &cli.StringFlag{
+          Name: "caller-reference",
+          Usage: "caller reference of the child zone when it is created (default: generated)",
+        },
+        &cli.StringFlag{
+          Name: "comment",
+          Usage: "comment of the child zone when it is created",
+        },
+        &cli.StringFlag{
+          Name: "delegation-set-id",
+          Usage: "reusable delegation set of the child zone when it is created",
+        },
+        &cli.StringFlag{
+          Name: "output",
+          Usage: "output format (text, json or yaml)",
+          Value: "text",
+          Aliases: []string{"o"},
+        },
+      },
+    },
+    {
+      Name: "delete",
+      Aliases: []string{"del"},
+      Usage: "delete an empty hosted zone",
+      Action: doDelete,
+      Flags: []cli.Flag{
+        &cli.StringFlag{
+          Name: "zone",
+          Usage: "HostedZone Name",
+          Aliases: []string{"z"},
+        },
+        &cli.StringFlag{
+          Name: "id",
+          Usage: "HostedZone ID, for zones which share a name",
+        },
+        &cli.BoolFlag{
+          Name: "force",
+          Usage: "delete the record sets of the zone first",
+        },
+      },
+    },
+    {
+      Name: "list",
+      Aliases: []string{"ls"},
+      Usage: "list the hosted zones",
+      Action: doList,
+      Flags: []cli.Flag{
+        &cli.StringFlag{
+          Name: "output",
+          Usage: "output format (text, json or yaml)",
+          Value: "text",
+          Aliases: []string{"o"},
+        },
+      },
+    },
+    {
+      Name: "show",
+      Usage: "show a hosted zone with its name servers or VPCs",
+      Action: doShow,
+      Flags: []cli.Flag{
+        &cli.StringFlag{
+          Name: "zone",
+          Usage: "HostedZone Name",
+          Aliases: []string{"z"},
+        },
+        &cli.StringFlag{
+          Name: "id",
+          Usage: "HostedZone ID, for zones which share a name",
+        },
+        &cli.StringFlag{
+          Name: "output",
+          Usage: "output format (text, json or yaml)",
+          Value: "text",
+          Aliases: []string{"o"},
+        },
+      },
+    },
+  },
+}
+
+func doCreate(c *cli.Context) (err error) {
+  opts := utils.CreateHostedZoneOptions{
+    Name: c.String("zone"),
+    CallerReference: c.String("caller-reference"),
+    Comment: c.String("comment"),
+    VPCID: c.String("vpc-id"),
+    VPCRegion: c.String("vpc-region"),
+    DelegationSetID: c.String("delegation-set-id"),
+  }
+  switch {
+  case c.IsSet("zone") && c.IsSet("network"):
+    return fmt.Errorf("zone can not be used with network")
+  case c.IsSet("network"):
+    _, network, err := net.ParseCIDR(c.String("network"))
+    if err != nil {
+      return err
+    }
+    opts.Name, err = utils.ReverseZoneName(network)
+    if err != nil {
+      return err
+    }
+  case !c.IsSet("zone"):
+    return fmt.Errorf("either zone or network is required")
+  }
+  if len(opts.VPCID) > 0 && len(opts.VPCRegion) == 0 {
+    opts.VPCRegion = c.String("region")
+  }
+
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zone, err := awsClient.CreateHostedZone(opts)
+  if err != nil {
+    return err
+  }
+  if c.Bool("dry-run") {
+    return nil
+  }
+  return utils.WriteHostedZone(c.App.Writer, zone, c.String("output"))
+}
+
+func doDelegate(c *cli.Context) (err error) {
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+
+  if c.Bool("check") {
+    for _, name := range []string{"caller-reference", "comment", "delegation-set-id"} {
+      if c.IsSet(name) {
+        return fmt.Errorf("check can not be used with %s", name)
+      }
+    }
+    parentID, err := awsClient.GetHostedZoneID(c.String("parent"))
+    if err != nil {
+      return err
+    }
+    childID, err := awsClient.GetHostedZoneID(c.String("child"))
+    if err != nil {
+      return err
+    }
+    d, err := awsClient.CheckDelegation(parentID, childID)
+    if err != nil {
+      return err
+    }
+    err = utils.WriteDelegation(c.App.Writer, d, c.String("output"))
+    if err != nil {
+      return err
+    }
+    if !d.InSync() {
+      return fmt.Errorf("delegation of %s in %s is %s", d.Child, d.Parent, d.Status)
+    }
+    return nil
+  }
+
+  opts := utils.CreateHostedZoneOptions{
+    CallerReference: c.String("caller-reference"),
+    Comment: c.String("comment"),
+    DelegationSetID: c.String("delegation-set-id"),
+  }
+  d, err := awsClient.Delegate(c.String("parent"), c.String("child"), opts, c.Int64("ttl"))
+  if err != nil {
+    return err
+  }
+  if c.Bool("dry-run") {
+    return nil
+  }
+  return utils.WriteDelegation(c.App.Writer, d, c.String("output"))
+}
+
+func doDelete(c *cli.Context) (err error) {
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zoneID, err := hostedZoneID(c, awsClient)
+  if err != nil {
+    return err
+  }
+  return awsClient.DeleteHostedZone(zoneID, c.Bool("force"))
+}
+
+func doList(c *cli.Context) (err error) {
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zones, err := awsClient.ListHostedZones()
+  if err != nil {
+    return err
+  }
+  return utils.WriteHostedZones(c.App.Writer, utils.HostedZoneViews(zones), c.String("output"))
+}
+
+func doShow(c *cli.Context) (err error) {
+  awsClient, err := utils.NewAWSClient(c)
+  if err != nil {
+    return err
+  }
+  zoneID, err := hostedZoneID(c, awsClient)
+  if err != nil {
+    return err
+  }
+  zone, err := awsClient.GetHostedZone(zoneID)
+  if err != nil {
+    return err
+  }
+  return utils.WriteHostedZone(c.App.Writer, zone, c.String("output"))
+}
+
+// hostedZoneID returns the --id of the command, or the ID of the hosted zone
+// named --zone.
+func hostedZoneID(c *cli.Context, awsClient *utils.AWSClientImpl) (zoneID string, err error) {
+  switch {
+  case c.IsSet("id") && c.IsSet("zone"):
+    return "", fmt.Errorf("id can not be used with zone")
+  case c.IsSet("id"):
+    return c.String("id"), nil
+  case c.IsSet("zone"):
+    return awsClient.GetHostedZoneID(c.String("zone"))
+  default:
+    return "", fmt.Errorf("either zone or id is required")
+  }
+}